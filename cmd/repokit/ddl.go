@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Waelson/go-spanner-repo/repokit"
+)
+
+// runDDL implements the ddl command.
+func runDDL(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ddl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	typeName := fs.String("type", "", "struct type to generate the table from (required)")
+	table := fs.String("table", "", "table name (default: the type name)")
+	pk := fs.String("pk", "", "comma-separated primary key columns (default: fields tagged repokit:\"pk\")")
+	interleave := fs.String("interleave", "", "parent table to interleave the table in")
+	cascade := fs.Bool("cascade", false, "delete interleaved rows with their parent (ON DELETE CASCADE)")
+	ttl := fs.String("ttl", "", "row deletion policy as column:days")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *typeName == "" || fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "usage: repokit ddl -type Name [flags] [dir]")
+		fs.PrintDefaults()
+		return 2
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	opts := repokit.TableOptions{InterleaveIn: *interleave, OnDeleteCascade: *cascade}
+	if *pk != "" {
+		opts.PrimaryKey = strings.Split(*pk, ",")
+	}
+	if *ttl != "" {
+		column, days, ok := strings.Cut(*ttl, ":")
+		n, err := strconv.Atoi(days)
+		if !ok || err != nil {
+			fmt.Fprintf(stderr, "repokit ddl: invalid -ttl %q, want column:days\n", *ttl)
+			return 2
+		}
+		opts.RowDeletionPolicy = &repokit.RowDeletionPolicy{Column: column, Days: n}
+	}
+	if *table == "" {
+		*table = *typeName
+	}
+
+	fields, err := structFields(dir, *typeName)
+	if err != nil {
+		fmt.Fprintf(stderr, "repokit ddl: %v\n", err)
+		return 1
+	}
+	schema, err := repokit.NewTableSchema(*table, fields, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "repokit ddl: %v\n", err)
+		return 1
+	}
+	statements, err := schema.DDL()
+	if err != nil {
+		fmt.Fprintf(stderr, "repokit ddl: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, strings.Join(statements, ";\n\n")+";")
+	return 0
+}
+
+// structFields returns the fields of the struct type typeName declared in
+// the non-test Go files of dir.
+func structFields(dir, typeName string) ([]repokit.StructField, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != typeName {
+					continue
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					return nil, fmt.Errorf("type %s is not a struct", typeName)
+				}
+				return astFields(st, importNames(file))
+			}
+		}
+	}
+	return nil, fmt.Errorf("type %s not found in %s", typeName, dir)
+}
+
+// importNames maps the names under which file refers to its imports to the
+// packages' default names, so that aliased imports such as
+// sp "cloud.google.com/go/spanner" resolve to "spanner".
+func importNames(file *ast.File) map[string]string {
+	names := map[string]string{}
+	for _, imp := range file.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		pkg := path.Base(p)
+		local := pkg
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		names[local] = pkg
+	}
+	return names
+}
+
+// astFields converts the exported named fields of st.
+func astFields(st *ast.StructType, imports map[string]string) ([]repokit.StructField, error) {
+	var fields []repokit.StructField
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+			fields = append(fields, repokit.StructField{Name: name.Name, GoType: goType(f.Type, imports), Tag: tag})
+		}
+	}
+	return fields, nil
+}
+
+// goType renders a field type the way repokit.StructField expects it.
+func goType(expr ast.Expr, imports map[string]string) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + goType(e.X, imports)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + goType(e.Elt, imports)
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			if pkg, ok := imports[x.Name]; ok {
+				return pkg + "." + e.Sel.Name
+			}
+		}
+	}
+	return types.ExprString(expr)
+}
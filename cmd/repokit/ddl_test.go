@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const entitySource = `package domain
+
+import (
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+)
+
+type Order struct {
+	CustomerID string        ` + "`spanner:\"customer_id\" repokit:\"pk,size=36\"`" + `
+	OrderID    int64         ` + "`spanner:\"order_id\" repokit:\"pk\"`" + `
+	Note       sp.NullString ` + "`spanner:\"note\"`" + `
+	Tags       []string      ` + "`spanner:\"tags\"`" + `
+	CreatedAt  *time.Time    ` + "`spanner:\"created_at\" repokit:\"commit_ts\"`" + `
+	cache      string
+}
+`
+
+func TestDDLCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "order.go"), []byte(entitySource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"ddl", "-type", "Order", "-table", "Orders", "-interleave", "Customers", "-cascade", "-ttl", "created_at:30", dir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	want := "CREATE TABLE `Orders` (\n" +
+		"\t`customer_id` STRING(36) NOT NULL,\n" +
+		"\t`order_id` INT64 NOT NULL,\n" +
+		"\t`note` STRING(MAX),\n" +
+		"\t`tags` ARRAY<STRING(MAX)>,\n" +
+		"\t`created_at` TIMESTAMP OPTIONS (allow_commit_timestamp = true),\n" +
+		") PRIMARY KEY (`customer_id`, `order_id`),\n" +
+		"  INTERLEAVE IN PARENT `Customers` ON DELETE CASCADE,\n" +
+		"  ROW DELETION POLICY (OLDER_THAN(`created_at`, INTERVAL 30 DAY));\n"
+	if stdout.String() != want {
+		t.Fatalf("output =\n%s\nwant\n%s", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"ddl", "-type", "Missing", dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code for a missing type = %d, want 1", code)
+	}
+	if code := run([]string{"ddl", dir}, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code without -type = %d, want 2", code)
+	}
+}
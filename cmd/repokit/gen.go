@@ -0,0 +1,851 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// generatedHeader starts every file written by the gen command. Files
+// without it are never overwritten.
+const generatedHeader = "// Code generated by repokit gen"
+
+// runGen implements the gen command.
+func runGen(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	out := fs.String("out", ".", "directory to write the generated files to")
+	pkg := fs.String("pkg", "", "package name of the generated files (default: the -out directory name)")
+	tables := fs.String("tables", "", "comma-separated tables to generate (default: all)")
+	names := fs.String("names", "", "comma-separated Table=Type entity names (default: the singular table name)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: repokit gen [flags] schema.sql...")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	g := &generator{typeNames: map[string]string{}}
+	for _, pair := range splitList(*names) {
+		table, typeName, ok := strings.Cut(pair, "=")
+		if !ok || !token.IsIdentifier(typeName) || !token.IsExported(typeName) {
+			fmt.Fprintf(stderr, "repokit gen: invalid -names entry %q, want Table=Type\n", pair)
+			return 2
+		}
+		g.typeNames[strings.ToLower(table)] = typeName
+	}
+	g.pkg = *pkg
+	if g.pkg == "" {
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			fmt.Fprintf(stderr, "repokit gen: %v\n", err)
+			return 1
+		}
+		g.pkg = strings.NewReplacer("-", "_", ".", "_").Replace(filepath.Base(abs))
+	}
+	if !token.IsIdentifier(g.pkg) {
+		fmt.Fprintf(stderr, "repokit gen: invalid package name %q\n", g.pkg)
+		return 2
+	}
+
+	if err := g.parse(fs.Args()); err != nil {
+		fmt.Fprintf(stderr, "repokit gen: %v\n", err)
+		return 1
+	}
+	files, err := g.generate(splitList(*tables))
+	if err != nil {
+		fmt.Fprintf(stderr, "repokit gen: %v\n", err)
+		return 1
+	}
+	for _, f := range files {
+		path := filepath.Join(*out, f.name)
+		written, err := writeGenerated(path, f.content)
+		if err != nil {
+			fmt.Fprintf(stderr, "repokit gen: %v\n", err)
+			return 1
+		}
+		if written {
+			fmt.Fprintln(stdout, path)
+		}
+	}
+	return 0
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// writeGenerated writes content to path unless it already holds it. It
+// refuses to overwrite a file that was not generated. It reports whether
+// the file was written.
+func writeGenerated(path string, content []byte) (bool, error) {
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil && bytes.Equal(existing, content):
+		return false, nil
+	case err == nil && !bytes.HasPrefix(existing, []byte(generatedHeader)):
+		return false, fmt.Errorf("%s exists and was not generated by repokit gen", path)
+	case err != nil && !os.IsNotExist(err):
+		return false, err
+	}
+	return true, os.WriteFile(path, content, 0o644)
+}
+
+// generator holds the parsed DDL of the gen command.
+type generator struct {
+	pkg       string
+	typeNames map[string]string // entity type names by lower-case table name
+	sources   []string          // base names of the DDL files
+	tables    map[string]*spansql.CreateTable
+	indexes   []*spansql.CreateIndex
+}
+
+// parse reads the CREATE TABLE and CREATE INDEX statements of files. Other
+// statements are ignored.
+func (g *generator) parse(files []string) error {
+	g.tables = map[string]*spansql.CreateTable{}
+	for _, name := range files {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		ddl, err := spansql.ParseDDL(name, string(content))
+		if err != nil {
+			return err
+		}
+		g.sources = append(g.sources, filepath.Base(name))
+		for _, stmt := range ddl.List {
+			switch s := stmt.(type) {
+			case *spansql.CreateTable:
+				key := strings.ToLower(string(s.Name))
+				if _, ok := g.tables[key]; ok {
+					return fmt.Errorf("table %s is created more than once", s.Name)
+				}
+				g.tables[key] = s
+			case *spansql.CreateIndex:
+				g.indexes = append(g.indexes, s)
+			}
+		}
+	}
+	return nil
+}
+
+// generatedFile is a file produced by the generator, relative to -out.
+type generatedFile struct {
+	name    string
+	content []byte
+}
+
+// generate returns the files of the selected tables, or of all tables, in
+// table name order.
+func (g *generator) generate(selected []string) ([]generatedFile, error) {
+	var keys []string
+	if len(selected) == 0 {
+		for key := range g.tables {
+			keys = append(keys, key)
+		}
+	}
+	for _, name := range selected {
+		key := strings.ToLower(name)
+		if _, ok := g.tables[key]; !ok {
+			return nil, fmt.Errorf("table %s is not created in %s", name, strings.Join(g.sources, ", "))
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var files []generatedFile
+	for _, key := range keys {
+		t, err := g.table(g.tables[key])
+		if err != nil {
+			return nil, err
+		}
+		base := snakeCase(t.typeName) + "_repository"
+		src, err := g.render(t.source())
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", t.name, err)
+		}
+		test, err := g.render(t.testSource(g))
+		if err != nil {
+			return nil, fmt.Errorf("table %s test: %w", t.name, err)
+		}
+		files = append(files, generatedFile{base + ".go", src}, generatedFile{base + "_test.go", test})
+	}
+	return files, nil
+}
+
+// genTable is a table prepared for code generation.
+type genTable struct {
+	ct       *spansql.CreateTable
+	name     string
+	typeName string
+	varName  string       // prefix of the unexported identifiers
+	columns  []*genColumn // mapped columns, in table order
+	key      []*genColumn // primary key columns, in key order
+}
+
+// genColumn is a column mapped to an entity field.
+type genColumn struct {
+	def       spansql.ColumnDef
+	name      string
+	field     string
+	goType    string
+	param     string // parameter name in key arguments
+	commitTS  bool
+	generated bool
+	tags      []string // repokit tag options
+}
+
+// table prepares ct for code generation.
+func (g *generator) table(ct *spansql.CreateTable) (*genTable, error) {
+	t := &genTable{ct: ct, name: string(ct.Name)}
+	t.typeName = g.typeNames[strings.ToLower(t.name)]
+	if t.typeName == "" {
+		t.typeName = singular(goName(t.name))
+	}
+	t.varName = lowerCamel(t.typeName)
+
+	fields := map[string]string{}
+	byName := map[string]*genColumn{}
+	for _, def := range ct.Columns {
+		if def.Type.Base == spansql.Tokenlist {
+			continue // search columns cannot be read into Go values
+		}
+		goType, err := goColumnType(def)
+		if err != nil {
+			return nil, fmt.Errorf("table %s column %s: %w", t.name, def.Name, err)
+		}
+		c := &genColumn{
+			def:       def,
+			name:      string(def.Name),
+			field:     goName(string(def.Name)),
+			goType:    goType,
+			commitTS:  def.Options.AllowCommitTimestamp != nil && *def.Options.AllowCommitTimestamp,
+			generated: def.Generated != nil,
+		}
+		if other, ok := fields[c.field]; ok {
+			return nil, fmt.Errorf("table %s: columns %s and %s both map to field %s", t.name, other, c.name, c.field)
+		}
+		fields[c.field] = c.name
+		c.param = lowerCamel(c.field)
+		if token.IsKeyword(c.param) || c.param == "ctx" || c.param == "tx" || c.param == "r" {
+			c.param += "_"
+		}
+		t.columns = append(t.columns, c)
+		byName[strings.ToLower(c.name)] = c
+	}
+
+	for _, part := range ct.PrimaryKey {
+		c, ok := byName[strings.ToLower(string(part.Column))]
+		if !ok {
+			return nil, fmt.Errorf("table %s: primary key column %s is not mapped", t.name, part.Column)
+		}
+		c.tags = append(c.tags, "pk")
+		t.key = append(t.key, c)
+	}
+	for _, c := range t.columns {
+		c.tags = append(c.tags, columnTags(c)...)
+	}
+	for _, idx := range g.indexes {
+		if strings.EqualFold(string(idx.Table), t.name) {
+			tagIndex(idx, t.columns, byName)
+		}
+	}
+	return t, nil
+}
+
+// columnTags returns the repokit tag options that make repokit.SchemaOf
+// derive the column's definition from the field.
+func columnTags(c *genColumn) []string {
+	var tags []string
+	nullableGo := strings.HasPrefix(c.goType, "[]") || strings.HasPrefix(c.goType, "spanner.Null")
+	if c.def.NotNull && nullableGo {
+		tags = append(tags, "notnull")
+	}
+	if b := c.def.Type.Base; (b == spansql.String || b == spansql.Bytes) && c.def.Type.Len != spansql.MaxLen {
+		tags = append(tags, "size="+strconv.FormatInt(c.def.Type.Len, 10))
+	}
+	if c.commitTS {
+		tags = append(tags, "commit_ts")
+	}
+	return tags
+}
+
+// tagIndex adds the index or unique tag of idx to its columns, if struct
+// tags can express it: ascending columns in field order, without STORING,
+// NULL_FILTERED or INTERLEAVE IN.
+func tagIndex(idx *spansql.CreateIndex, columns []*genColumn, byName map[string]*genColumn) {
+	if idx.NullFiltered || len(idx.Storing) > 0 || idx.Interleave != "" {
+		return
+	}
+	position := map[*genColumn]int{}
+	for i, c := range columns {
+		position[c] = i
+	}
+	var cols []*genColumn
+	for i, part := range idx.Columns {
+		c, ok := byName[strings.ToLower(string(part.Column))]
+		if !ok || part.Desc || i > 0 && position[c] <= position[cols[i-1]] {
+			return
+		}
+		cols = append(cols, c)
+	}
+	option := "index="
+	if idx.Unique {
+		option = "unique="
+	}
+	for _, c := range cols {
+		c.tags = append(c.tags, option+string(idx.Name))
+	}
+}
+
+// scalarGoTypes maps Spanner types to the Go types of NOT NULL and
+// nullable columns.
+var scalarGoTypes = map[spansql.TypeBase][2]string{
+	spansql.Bool:      {"bool", "spanner.NullBool"},
+	spansql.Int64:     {"int64", "spanner.NullInt64"},
+	spansql.Float64:   {"float64", "spanner.NullFloat64"},
+	spansql.Numeric:   {"big.Rat", "spanner.NullNumeric"},
+	spansql.String:    {"string", "spanner.NullString"},
+	spansql.Bytes:     {"[]byte", "[]byte"},
+	spansql.Date:      {"civil.Date", "spanner.NullDate"},
+	spansql.Timestamp: {"time.Time", "spanner.NullTime"},
+	spansql.JSON:      {"spanner.NullJSON", "spanner.NullJSON"},
+}
+
+// goColumnType returns the Go type of a column. Arrays use slices of the
+// NOT NULL element type.
+func goColumnType(def spansql.ColumnDef) (string, error) {
+	types, ok := scalarGoTypes[def.Type.Base]
+	if !ok {
+		return "", fmt.Errorf("unsupported type %s", def.Type.SQL())
+	}
+	switch {
+	case def.Type.Array:
+		return "[]" + types[0], nil
+	case def.NotNull:
+		return types[0], nil
+	default:
+		return types[1], nil
+	}
+}
+
+// render formats the Go source src of package g.pkg, adding the header and
+// the imports of the packages it uses.
+func (g *generator) render(src string) ([]byte, error) {
+	body := "package " + g.pkg + "\n\n" + src
+	file, err := parser.ParseFile(token.NewFileSet(), "", body, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+	var std, other []string
+	for name, path := range importPaths {
+		switch {
+		case !used[name]:
+		case strings.Contains(path, "."):
+			other = append(other, strconv.Quote(path))
+		default:
+			std = append(std, strconv.Quote(path))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s from %s. DO NOT EDIT.\n\n", generatedHeader, strings.Join(g.sources, ", "))
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", g.pkg)
+	for _, p := range std {
+		fmt.Fprintf(&b, "\t%s\n", p)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		b.WriteString("\n")
+	}
+	for _, p := range other {
+		fmt.Fprintf(&b, "\t%s\n", p)
+	}
+	b.WriteString(")\n\n")
+	b.WriteString(src)
+	return format.Source([]byte(b.String()))
+}
+
+// importPaths lists the packages generated code may use, by name.
+var importPaths = map[string]string{
+	"context":     "context",
+	"reflect":     "reflect",
+	"testing":     "testing",
+	"time":        "time",
+	"big":         "math/big",
+	"civil":       "cloud.google.com/go/civil",
+	"spanner":     "cloud.google.com/go/spanner",
+	"repokit":     "github.com/Waelson/go-spanner-repo/repokit",
+	"repokittest": "github.com/Waelson/go-spanner-repo/repokittest",
+}
+
+// source returns the entity, key, mapping functions and repository of t.
+func (t *genTable) source() string {
+	var b strings.Builder
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&b, format, args...) }
+	entity, key, v := t.typeName, t.typeName+"Key", t.varName
+
+	p("// %s is a row of the %s table.\n", entity, t.name)
+	p("type %s struct {\n", entity)
+	for _, c := range t.columns {
+		tag := fmt.Sprintf("spanner:%q", c.name)
+		if len(c.tags) > 0 {
+			tag += fmt.Sprintf(" repokit:%q", strings.Join(c.tags, ","))
+		}
+		p("\t%s %s `%s`\n", c.field, c.goType, tag)
+	}
+	p("}\n\n")
+
+	p("// %s is the primary key of the %s table.\n", key, t.name)
+	p("type %s struct {\n", key)
+	for _, c := range t.key {
+		p("\t%s %s `spanner:%q`\n", c.field, c.goType, c.name)
+	}
+	p("}\n\n")
+
+	p("// %sCols are the typed columns of the %s table, for criteria,\n", entity, t.name)
+	p("// orderings and column lists.\n")
+	p("var %sCols = struct {\n", entity)
+	for _, c := range t.columns {
+		p("\t%s repokit.Column[%s]\n", c.field, c.goType)
+	}
+	p("}{\n")
+	for _, c := range t.columns {
+		p("\t%s: repokit.NewColumn[%s](%q),\n", c.field, c.goType, c.name)
+	}
+	p("}\n\n")
+
+	var writes []*genColumn
+	for _, c := range t.columns {
+		if !c.generated {
+			writes = append(writes, c)
+		}
+	}
+	p("var (\n")
+	p("\t%sTable = %q\n", v, t.name)
+	p("\t%sPrimaryKey = %s\n", v, stringSlice(t.key))
+	p("\t// %sColumns are the columns read by %sRowMapper, in order.\n", v, v)
+	p("\t%sColumns = %s\n", v, stringSlice(t.columns))
+	if len(writes) != len(t.columns) {
+		p("\t// %sWriteColumns are the columns written by %sMutationBuilder.\n", v, v)
+		p("\t%sWriteColumns = %s\n", v, stringSlice(writes))
+	}
+	p(")\n\n")
+
+	p("// %sRowMapper converts a row of %sColumns into %s.\n", v, v, a(entity))
+	p("func %sRowMapper(row *spanner.Row) (%s, error) {\n", v, entity)
+	p("\tvar e %s\n", entity)
+	var ptrs []string
+	for _, c := range t.columns {
+		ptrs = append(ptrs, "&e."+c.field)
+	}
+	p("\terr := row.Columns(%s)\n", strings.Join(ptrs, ", "))
+	p("\treturn e, err\n}\n\n")
+
+	p("// %sKeyExtractor returns the primary key of %s.\n", v, a(entity))
+	p("func %sKeyExtractor(e %s) %s {\n", v, entity, key)
+	p("\treturn %s{%s}\n}\n\n", key, keyFields(t.key, "e."))
+
+	writeColumns := v + "Columns"
+	if len(writes) != len(t.columns) {
+		writeColumns = v + "WriteColumns"
+	}
+	var values []string
+	var commitTS []*genColumn
+	for _, c := range writes {
+		if c.commitTS {
+			values = append(values, commitTSVar(c))
+			commitTS = append(commitTS, c)
+			continue
+		}
+		values = append(values, "e."+c.field)
+	}
+	p("// %sMutationBuilder builds a mutation inserting or updating %s.\n", v, a(entity))
+	if len(commitTS) > 0 {
+		p("// A commit timestamp column is set to the commit timestamp while its field\n")
+		p("// is zero and keeps the field's value otherwise, so saving a loaded entity\n")
+		p("// does not overwrite it. Zero the field to set it again.\n")
+	}
+	p("func %sMutationBuilder(e %s) *spanner.Mutation {\n", v, entity)
+	for _, c := range commitTS {
+		zero := "e." + c.field + ".IsZero()"
+		if c.goType == "spanner.NullTime" {
+			zero = "!e." + c.field + ".Valid"
+		}
+		p("\tvar %s interface{} = e.%s\n", commitTSVar(c), c.field)
+		p("\tif %s {\n\t\t%s = spanner.CommitTimestamp\n\t}\n", zero, commitTSVar(c))
+	}
+	p("\treturn spanner.InsertOrUpdate(%sTable, %s, []interface{}{%s})\n}\n\n", v, writeColumns, strings.Join(values, ", "))
+
+	repo := entity + "Repository"
+	base := fmt.Sprintf("repokit.SpannerRepository[%s, %s]", entity, key)
+	builder := fmt.Sprintf("repokit.SpannerRepositoryBuilder[%s, %s]", entity, key)
+	params, args := keyParams(t.key), keyFields(t.key, "")
+
+	p("// %s stores %s entities in the %s table.\n", repo, entity, t.name)
+	p("type %s struct {\n\tbase *%s\n}\n\n", repo, base)
+	p("// New%s creates %s. The configure functions can set further\n", repo, a(repo))
+	p("// builder options, such as a cache or call defaults, before it is built.\n")
+	p("func New%s(client *spanner.Client, configure ...func(*%s)) (*%s, error) {\n", repo, builder, repo)
+	p("\tb := repokit.NewSpannerRepositoryBuilder[%s, %s]().\n", entity, key)
+	p("\t\tWithClient(client).\n")
+	p("\t\tWithTableName(%sTable).\n", v)
+	p("\t\tWithPrimaryKeys(%sPrimaryKey).\n", v)
+	p("\t\tWithColumns(%sColumns).\n", v)
+	p("\t\tWithRowMapper(%sRowMapper).\n", v)
+	p("\t\tWithMutation(%sMutationBuilder).\n", v)
+	p("\t\tWithKeyExtractor(%sKeyExtractor)", v)
+	if il := t.ct.Interleave; il != nil {
+		p(".\n\t\tWithInterleave(%q, %t)", string(il.Parent), il.OnDelete == spansql.CascadeOnDelete)
+	}
+	if rdp := t.ct.RowDeletionPolicy; rdp != nil {
+		p(".\n\t\tWithRowDeletionPolicy(%q, %d)", string(rdp.Column), rdp.NumDays)
+	}
+	p("\n\tfor _, fn := range configure {\n\t\tfn(b)\n\t}\n")
+	p("\tbase, err := b.Build()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	p("\treturn &%s{base: base}, nil\n}\n\n", repo)
+
+	p("// Base returns the underlying repository, for the operations %s does not wrap.\n", repo)
+	p("func (r *%s) Base() *%s {\n\treturn r.base\n}\n\n", repo, base)
+
+	p("// FindByID fetches %s by its primary key.\n", a(entity))
+	p("func (r *%s) FindByID(ctx context.Context, %s) (%s, bool, error) {\n", repo, params, entity)
+	p("\treturn r.base.FindByID(ctx, %s{%s}, nil)\n}\n\n", key, args)
+
+	p("// Save inserts or updates %s.\n", a(entity))
+	p("func (r *%s) Save(ctx context.Context, e %s) error {\n\treturn r.base.Save(ctx, e)\n}\n\n", repo, entity)
+	p("// Update modifies an existing %s.\n", entity)
+	p("func (r *%s) Update(ctx context.Context, e %s) error {\n\treturn r.base.Update(ctx, e)\n}\n\n", repo, entity)
+	p("// Delete removes %s by its primary key.\n", a(entity))
+	p("func (r *%s) Delete(ctx context.Context, %s) error {\n", repo, params)
+	p("\treturn r.base.Delete(ctx, %s{%s})\n}\n\n", key, args)
+
+	p("// SaveTx inserts or updates %s within tx.\n", a(entity))
+	p("func (r *%s) SaveTx(tx repokit.Transaction, e %s) error {\n\treturn r.base.SaveTx(tx, e)\n}\n\n", repo, entity)
+	p("// UpdateTx modifies an existing %s within tx.\n", entity)
+	p("func (r *%s) UpdateTx(tx repokit.Transaction, e %s) error {\n\treturn r.base.UpdateTx(tx, e)\n}\n\n", repo, entity)
+	p("// DeleteTx removes %s by its primary key within tx.\n", a(entity))
+	p("func (r *%s) DeleteTx(tx repokit.Transaction, %s) error {\n", repo, params)
+	p("\treturn r.base.DeleteTx(tx, %s{%s})\n}\n", key, args)
+	return b.String()
+}
+
+// testSource returns a test saving, reading and deleting an entity of t on
+// spannertest.
+func (t *genTable) testSource(g *generator) string {
+	var b strings.Builder
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&b, format, args...) }
+	v := t.varName
+
+	// Ancestors are created, and get a row, before the table.
+	var ancestors []*spansql.CreateTable
+	for il := t.ct.Interleave; il != nil; {
+		parent, ok := g.tables[strings.ToLower(string(il.Parent))]
+		if !ok {
+			break
+		}
+		ancestors = append([]*spansql.CreateTable{parent}, ancestors...)
+		il = parent.Interleave
+	}
+	// spannertest cannot read NUMERIC and JSON columns, nor write them
+	// into the ancestor rows.
+	skip := ""
+	unsupported := func(ct *spansql.CreateTable, defs []spansql.ColumnDef) {
+		for _, def := range defs {
+			if skip == "" && (def.Type.Base == spansql.Numeric || def.Type.Base == spansql.JSON) {
+				skip = fmt.Sprintf("spannertest does not support %s columns (%s.%s)", def.Type.Base.SQL(), ct.Name, def.Name)
+			}
+		}
+	}
+	for _, ct := range ancestors {
+		unsupported(ct, requiredColumns(ct))
+	}
+	unsupported(t.ct, t.ct.Columns)
+
+	p("// %sTestSchema creates the %s table", v, t.name)
+	if len(ancestors) > 0 {
+		p(" and its ancestors")
+	}
+	p(".\nvar %sTestSchema = []string{\n", v)
+	for _, ct := range append(ancestors, t.ct) {
+		p("\t%s,\n", sqlLiteral(ct.SQL()))
+	}
+	p("}\n\n")
+
+	p("func Test%sRepository(t *testing.T) {\n", t.typeName)
+	if skip != "" {
+		p("\tt.Skip(%q)\n\n", skip)
+	}
+	p("\tctx := context.Background()\n")
+	p("\tclient := repokittest.NewSpannertestClientWithSchema(t, %sTestSchema)\n", v)
+	p("\trepo, err := New%sRepository(client)\n", t.typeName)
+	p("\tif err != nil {\n\t\tt.Fatalf(\"build repository: %%v\", err)\n\t}\n\n")
+
+	if len(ancestors) > 0 {
+		p("\tparents := []*spanner.Mutation{\n")
+		for _, ct := range ancestors {
+			var cols, values []string
+			for _, def := range requiredColumns(ct) {
+				cols = append(cols, strconv.Quote(string(def.Name)))
+				if def.Options.AllowCommitTimestamp != nil && *def.Options.AllowCommitTimestamp {
+					values = append(values, "spanner.CommitTimestamp")
+					continue
+				}
+				goType, _ := goColumnType(def)
+				value := sampleValue(goType)
+				if goType == "int64" || goType == "float64" {
+					value = goType + "(" + value + ")"
+				}
+				values = append(values, value)
+			}
+			p("\t\tspanner.InsertOrUpdate(%q, []string{%s}, []interface{}{%s}),\n",
+				string(ct.Name), strings.Join(cols, ", "), strings.Join(values, ", "))
+		}
+		p("\t}\n")
+		p("\tif _, err := client.Apply(ctx, parents); err != nil {\n\t\tt.Fatalf(\"insert parent rows: %%v\", err)\n\t}\n\n")
+	}
+
+	required := map[string]bool{}
+	for _, def := range requiredColumns(t.ct) {
+		required[strings.ToLower(string(def.Name))] = true
+	}
+	p("\tentity := %s{\n", t.typeName)
+	for _, c := range t.columns {
+		if required[strings.ToLower(c.name)] {
+			p("\t\t%s: %s,\n", c.field, sampleValue(c.goType))
+		}
+	}
+	p("\t}\n")
+	var keyArgs []string
+	for _, c := range t.key {
+		keyArgs = append(keyArgs, "entity."+c.field)
+	}
+	args := strings.Join(keyArgs, ", ")
+	p("\tif err := repo.Save(ctx, entity); err != nil {\n\t\tt.Fatalf(\"Save: %%v\", err)\n\t}\n")
+	p("\tgot, found, err := repo.FindByID(ctx, %s)\n", args)
+	p("\tif err != nil || !found {\n\t\tt.Fatalf(\"FindByID = %%v, %%v; want the saved entity\", found, err)\n\t}\n")
+	p("\tif !reflect.DeepEqual(%sKeyExtractor(got), %sKeyExtractor(entity)) {\n", v, v)
+	p("\t\tt.Fatalf(\"FindByID returned key %%+v, want %%+v\", %sKeyExtractor(got), %sKeyExtractor(entity))\n\t}\n", v, v)
+	// A commit timestamp column written with a value keeps it.
+	for _, c := range t.columns {
+		if c.commitTS && c.goType == "time.Time" && required[strings.ToLower(c.name)] {
+			p("\tif !got.%s.Equal(entity.%s) {\n", c.field, c.field)
+			p("\t\tt.Fatalf(\"%s = %%v, want the saved %%v\", got.%s, entity.%s)\n\t}\n", c.field, c.field, c.field)
+		}
+	}
+	p("\tif err := repo.Delete(ctx, %s); err != nil {\n\t\tt.Fatalf(\"Delete: %%v\", err)\n\t}\n", args)
+	p("\tif _, found, err := repo.FindByID(ctx, %s); err != nil || found {\n", args)
+	p("\t\tt.Fatalf(\"FindByID after Delete = %%v, %%v; want not found\", found, err)\n\t}\n}\n")
+	return b.String()
+}
+
+// commitTSVar returns the name of the local variable holding the value the
+// mutation builder writes to commit timestamp column c.
+func commitTSVar(c *genColumn) string {
+	if c.param == "e" {
+		return "e_"
+	}
+	return c.param
+}
+
+// requiredColumns returns the primary key and NOT NULL columns of ct that
+// can be written, in table order.
+func requiredColumns(ct *spansql.CreateTable) []spansql.ColumnDef {
+	key := map[string]bool{}
+	for _, part := range ct.PrimaryKey {
+		key[strings.ToLower(string(part.Column))] = true
+	}
+	var defs []spansql.ColumnDef
+	for _, def := range ct.Columns {
+		if def.Generated == nil && def.Type.Base != spansql.Tokenlist && (def.NotNull || key[strings.ToLower(string(def.Name))]) {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// sampleValues are non-zero Go expressions of each generated Go type.
+var sampleValues = map[string]string{
+	"bool":                "true",
+	"int64":               "1",
+	"float64":             "1.5",
+	"string":              `"a"`,
+	"[]byte":              `[]byte("a")`,
+	"big.Rat":             "*big.NewRat(3, 2)",
+	"civil.Date":          "civil.Date{Year: 2024, Month: 1, Day: 2}",
+	"time.Time":           "time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)",
+	"spanner.NullBool":    "spanner.NullBool{Bool: true, Valid: true}",
+	"spanner.NullInt64":   "spanner.NullInt64{Int64: 1, Valid: true}",
+	"spanner.NullFloat64": "spanner.NullFloat64{Float64: 1.5, Valid: true}",
+	"spanner.NullNumeric": "spanner.NullNumeric{Numeric: *big.NewRat(3, 2), Valid: true}",
+	"spanner.NullString":  `spanner.NullString{StringVal: "a", Valid: true}`,
+	"spanner.NullDate":    "spanner.NullDate{Date: civil.Date{Year: 2024, Month: 1, Day: 2}, Valid: true}",
+	"spanner.NullTime":    "spanner.NullTime{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true}",
+	"spanner.NullJSON":    `spanner.NullJSON{Value: map[string]interface{}{"k": "v"}, Valid: true}`,
+}
+
+// sampleValue returns a non-zero Go expression of goType.
+func sampleValue(goType string) string {
+	if v, ok := sampleValues[goType]; ok {
+		return v
+	}
+	elem := strings.TrimPrefix(goType, "[]")
+	return goType + "{" + sampleValue(elem) + "}"
+}
+
+// sqlLiteral quotes sql as a Go string literal, raw when possible.
+func sqlLiteral(sql string) string {
+	if strings.Contains(sql, "`") {
+		return strconv.Quote(sql)
+	}
+	return "`" + sql + "`"
+}
+
+// a prefixes name with its indefinite article.
+func a(name string) string {
+	if strings.ContainsRune("AEIOU", rune(name[0])) {
+		return "an " + name
+	}
+	return "a " + name
+}
+
+// stringSlice returns a []string literal of the column names.
+func stringSlice(columns []*genColumn) string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = strconv.Quote(c.name)
+	}
+	return "[]string{" + strings.Join(names, ", ") + "}"
+}
+
+// keyParams returns the parameter list of the key columns.
+func keyParams(key []*genColumn) string {
+	params := make([]string, len(key))
+	for i, c := range key {
+		params[i] = c.param + " " + c.goType
+	}
+	return strings.Join(params, ", ")
+}
+
+// keyFields returns the fields of a key literal, taking the values from the
+// entity fields prefixed by prefix or, when prefix is empty, from the key
+// parameters.
+func keyFields(key []*genColumn, prefix string) string {
+	fields := make([]string, len(key))
+	for i, c := range key {
+		value := c.param
+		if prefix != "" {
+			value = prefix + c.field
+		}
+		fields[i] = c.field + ": " + value
+	}
+	return strings.Join(fields, ", ")
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// goName converts a table or column name to an exported Go name:
+// customer_id becomes CustomerID and orderItems becomes OrderItems.
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(part); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		s = "X" + s
+	}
+	return s
+}
+
+// singular returns the singular of an English plural name, if it looks like one.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "ss"), strings.HasSuffix(name, "us"):
+		return name
+	case strings.HasSuffix(name, "s") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// lowerCamel lowers the leading upper-case run of an exported name:
+// CustomerID becomes customerID, ID becomes id and URLPath becomes urlPath.
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) && unicode.IsLower(runes[n]) {
+		n-- // the last upper-case letter starts the next word
+	}
+	if n == 0 {
+		n = 1
+	}
+	for i := 0; i < n && i < len(runes); i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// snakeCase converts an exported Go name to snake_case file name form.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
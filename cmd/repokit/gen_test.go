@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+const exampleDir = "../../examples/generated"
+
+func TestGenCommandMatchesExample(t *testing.T) {
+	out := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	args := []string{"gen", "-out", out, "-pkg", "generated", filepath.Join(exampleDir, "schema.sql")}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	files, err := filepath.Glob(filepath.Join(out, "*.go"))
+	if err != nil || len(files) != 4 {
+		t.Fatalf("generated %v, %v; want 4 files", files, err)
+	}
+	for _, name := range files {
+		got, _ := os.ReadFile(name)
+		want, err := os.ReadFile(filepath.Join(exampleDir, filepath.Base(name)))
+		if err != nil {
+			t.Fatalf("read example: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from examples/generated; run go generate there", filepath.Base(name))
+		}
+	}
+
+	// Regenerating writes nothing.
+	stdout.Reset()
+	if code := run(args, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Fatalf("second run: exit code %d, wrote %q", code, stdout.String())
+	}
+
+	// Hand-written files are never overwritten.
+	if err := os.WriteFile(filepath.Join(out, "order_repository.go"), []byte("package generated\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run(args, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code over a hand-written file = %d, want 1", code)
+	}
+}
+
+// TestGenRoundTrip checks that the ddl command derives the original table
+// from a generated entity.
+func TestGenRoundTrip(t *testing.T) {
+	const schema = `CREATE TABLE Invoices (
+	invoice_id BYTES(16) NOT NULL,
+	type STRING(8) NOT NULL,
+	amount NUMERIC NOT NULL,
+	details JSON,
+	lines ARRAY<INT64>,
+	codes ARRAY<STRING(4)> NOT NULL,
+	issued DATE NOT NULL,
+	updated_at TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (invoice_id);
+CREATE INDEX InvoicesByType ON Invoices (type, issued);
+CREATE INDEX InvoicesByIssuedDesc ON Invoices (issued DESC);`
+	dir := t.TempDir()
+	ddlFile := filepath.Join(dir, "schema.sql")
+	if err := os.WriteFile(ddlFile, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"gen", "-out", dir, "-pkg", "billing", "-names", "Invoices=Bill", ddlFile}, &stdout, &stderr); code != 0 {
+		t.Fatalf("gen exit code %d: %s", code, stderr.String())
+	}
+
+	src, err := os.ReadFile(filepath.Join(dir, "bill_repository.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type Bill struct",
+		"Amount    big.Rat",
+		`repokit.NewColumn[civil.Date]("issued")`,
+		`spanner:"type" repokit:"size=8,index=InvoicesByType"`,
+		"func (r *BillRepository) FindByID(ctx context.Context, invoiceID []byte) (Bill, bool, error)",
+		"var updatedAt interface{} = e.UpdatedAt\n\tif !e.UpdatedAt.Valid {\n\t\tupdatedAt = spanner.CommitTimestamp\n\t}",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source lacks %q:\n%s", want, src)
+		}
+	}
+	test, _ := os.ReadFile(filepath.Join(dir, "bill_repository_test.go"))
+	if !strings.Contains(string(test), `t.Skip("spannertest does not support NUMERIC columns (Invoices.amount)")`) {
+		t.Errorf("generated test is not skipped:\n%s", test)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"ddl", "-type", "Bill", "-table", "Invoices", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("ddl exit code %d: %s", code, stderr.String())
+	}
+	want, err := spansql.ParseDDL("want", strings.Replace(schema, "\nCREATE INDEX InvoicesByIssuedDesc ON Invoices (issued DESC);", "", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := spansql.ParseDDL("got", stdout.String())
+	if err != nil {
+		t.Fatalf("parse round-tripped DDL: %v\n%s", err, stdout.String())
+	}
+	if len(got.List) != len(want.List) {
+		t.Fatalf("round trip = %d statements, want %d", len(got.List), len(want.List))
+	}
+	for i := range want.List {
+		if g, w := got.List[i].SQL(), want.List[i].SQL(); g != w {
+			t.Errorf("round trip statement %d =\n%s\nwant\n%s", i, g, w)
+		}
+	}
+}
+
+func TestGenNames(t *testing.T) {
+	for in, want := range map[string][2]string{
+		"Orders":        {"Order", "order"},
+		"tb_users":      {"TbUser", "tbUser"},
+		"categories":    {"Category", "category"},
+		"addresses":     {"Address", "address"},
+		"url_redirects": {"URLRedirect", "urlRedirect"},
+		"Status":        {"Status", "status"},
+		"Address":       {"Address", "address"},
+	} {
+		typeName := singular(goName(in))
+		if got := [2]string{typeName, lowerCamel(typeName)}; got != want {
+			t.Errorf("names of %s = %v, want %v", in, got, want)
+		}
+	}
+	if got := snakeCase("URLRedirect"); got != "url_redirect" {
+		t.Errorf("snakeCase = %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+// Code generated by repokit gen from schema.sql. DO NOT EDIT.
+
+package generated
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/Waelson/go-spanner-repo/repokit"
+)
+
+// Customer is a row of the Customers table.
+type Customer struct {
+	CustomerID  string             `spanner:"customer_id" repokit:"pk,size=36"`
+	Email       string             `spanner:"email" repokit:"unique=CustomersByEmail"`
+	DisplayName spanner.NullString `spanner:"display_name"`
+	BirthDate   spanner.NullDate   `spanner:"birth_date"`
+	CreatedAt   time.Time          `spanner:"created_at" repokit:"commit_ts"`
+}
+
+// CustomerKey is the primary key of the Customers table.
+type CustomerKey struct {
+	CustomerID string `spanner:"customer_id"`
+}
+
+// CustomerCols are the typed columns of the Customers table, for criteria,
+// orderings and column lists.
+var CustomerCols = struct {
+	CustomerID  repokit.Column[string]
+	Email       repokit.Column[string]
+	DisplayName repokit.Column[spanner.NullString]
+	BirthDate   repokit.Column[spanner.NullDate]
+	CreatedAt   repokit.Column[time.Time]
+}{
+	CustomerID:  repokit.NewColumn[string]("customer_id"),
+	Email:       repokit.NewColumn[string]("email"),
+	DisplayName: repokit.NewColumn[spanner.NullString]("display_name"),
+	BirthDate:   repokit.NewColumn[spanner.NullDate]("birth_date"),
+	CreatedAt:   repokit.NewColumn[time.Time]("created_at"),
+}
+
+var (
+	customerTable      = "Customers"
+	customerPrimaryKey = []string{"customer_id"}
+	// customerColumns are the columns read by customerRowMapper, in order.
+	customerColumns = []string{"customer_id", "email", "display_name", "birth_date", "created_at"}
+)
+
+// customerRowMapper converts a row of customerColumns into a Customer.
+func customerRowMapper(row *spanner.Row) (Customer, error) {
+	var e Customer
+	err := row.Columns(&e.CustomerID, &e.Email, &e.DisplayName, &e.BirthDate, &e.CreatedAt)
+	return e, err
+}
+
+// customerKeyExtractor returns the primary key of a Customer.
+func customerKeyExtractor(e Customer) CustomerKey {
+	return CustomerKey{CustomerID: e.CustomerID}
+}
+
+// customerMutationBuilder builds a mutation inserting or updating a Customer.
+// A commit timestamp column is set to the commit timestamp while its field
+// is zero and keeps the field's value otherwise, so saving a loaded entity
+// does not overwrite it. Zero the field to set it again.
+func customerMutationBuilder(e Customer) *spanner.Mutation {
+	var createdAt interface{} = e.CreatedAt
+	if e.CreatedAt.IsZero() {
+		createdAt = spanner.CommitTimestamp
+	}
+	return spanner.InsertOrUpdate(customerTable, customerColumns, []interface{}{e.CustomerID, e.Email, e.DisplayName, e.BirthDate, createdAt})
+}
+
+// CustomerRepository stores Customer entities in the Customers table.
+type CustomerRepository struct {
+	base *repokit.SpannerRepository[Customer, CustomerKey]
+}
+
+// NewCustomerRepository creates a CustomerRepository. The configure functions can set further
+// builder options, such as a cache or call defaults, before it is built.
+func NewCustomerRepository(client *spanner.Client, configure ...func(*repokit.SpannerRepositoryBuilder[Customer, CustomerKey])) (*CustomerRepository, error) {
+	b := repokit.NewSpannerRepositoryBuilder[Customer, CustomerKey]().
+		WithClient(client).
+		WithTableName(customerTable).
+		WithPrimaryKeys(customerPrimaryKey).
+		WithColumns(customerColumns).
+		WithRowMapper(customerRowMapper).
+		WithMutation(customerMutationBuilder).
+		WithKeyExtractor(customerKeyExtractor)
+	for _, fn := range configure {
+		fn(b)
+	}
+	base, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &CustomerRepository{base: base}, nil
+}
+
+// Base returns the underlying repository, for the operations CustomerRepository does not wrap.
+func (r *CustomerRepository) Base() *repokit.SpannerRepository[Customer, CustomerKey] {
+	return r.base
+}
+
+// FindByID fetches a Customer by its primary key.
+func (r *CustomerRepository) FindByID(ctx context.Context, customerID string) (Customer, bool, error) {
+	return r.base.FindByID(ctx, CustomerKey{CustomerID: customerID}, nil)
+}
+
+// Save inserts or updates a Customer.
+func (r *CustomerRepository) Save(ctx context.Context, e Customer) error {
+	return r.base.Save(ctx, e)
+}
+
+// Update modifies an existing Customer.
+func (r *CustomerRepository) Update(ctx context.Context, e Customer) error {
+	return r.base.Update(ctx, e)
+}
+
+// Delete removes a Customer by its primary key.
+func (r *CustomerRepository) Delete(ctx context.Context, customerID string) error {
+	return r.base.Delete(ctx, CustomerKey{CustomerID: customerID})
+}
+
+// SaveTx inserts or updates a Customer within tx.
+func (r *CustomerRepository) SaveTx(tx repokit.Transaction, e Customer) error {
+	return r.base.SaveTx(tx, e)
+}
+
+// UpdateTx modifies an existing Customer within tx.
+func (r *CustomerRepository) UpdateTx(tx repokit.Transaction, e Customer) error {
+	return r.base.UpdateTx(tx, e)
+}
+
+// DeleteTx removes a Customer by its primary key within tx.
+func (r *CustomerRepository) DeleteTx(tx repokit.Transaction, customerID string) error {
+	return r.base.DeleteTx(tx, CustomerKey{CustomerID: customerID})
+}
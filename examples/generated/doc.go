@@ -0,0 +1,5 @@
+// Package generated holds the repositories generated by repokit gen from
+// schema.sql.
+package generated
+
+//go:generate go run ../../cmd/repokit gen -out . schema.sql
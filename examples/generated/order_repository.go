@@ -0,0 +1,147 @@
+// Code generated by repokit gen from schema.sql. DO NOT EDIT.
+
+package generated
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/Waelson/go-spanner-repo/repokit"
+)
+
+// Order is a row of the Orders table.
+type Order struct {
+	CustomerID string            `spanner:"customer_id" repokit:"pk,size=36"`
+	OrderID    int64             `spanner:"order_id" repokit:"pk"`
+	Status     string            `spanner:"status" repokit:"size=16,index=OrdersByStatus"`
+	TotalCents spanner.NullInt64 `spanner:"total_cents"`
+	Tags       []string          `spanner:"tags"`
+	Paid       bool              `spanner:"paid"`
+	CreatedAt  time.Time         `spanner:"created_at" repokit:"commit_ts,index=OrdersByStatus"`
+}
+
+// OrderKey is the primary key of the Orders table.
+type OrderKey struct {
+	CustomerID string `spanner:"customer_id"`
+	OrderID    int64  `spanner:"order_id"`
+}
+
+// OrderCols are the typed columns of the Orders table, for criteria,
+// orderings and column lists.
+var OrderCols = struct {
+	CustomerID repokit.Column[string]
+	OrderID    repokit.Column[int64]
+	Status     repokit.Column[string]
+	TotalCents repokit.Column[spanner.NullInt64]
+	Tags       repokit.Column[[]string]
+	Paid       repokit.Column[bool]
+	CreatedAt  repokit.Column[time.Time]
+}{
+	CustomerID: repokit.NewColumn[string]("customer_id"),
+	OrderID:    repokit.NewColumn[int64]("order_id"),
+	Status:     repokit.NewColumn[string]("status"),
+	TotalCents: repokit.NewColumn[spanner.NullInt64]("total_cents"),
+	Tags:       repokit.NewColumn[[]string]("tags"),
+	Paid:       repokit.NewColumn[bool]("paid"),
+	CreatedAt:  repokit.NewColumn[time.Time]("created_at"),
+}
+
+var (
+	orderTable      = "Orders"
+	orderPrimaryKey = []string{"customer_id", "order_id"}
+	// orderColumns are the columns read by orderRowMapper, in order.
+	orderColumns = []string{"customer_id", "order_id", "status", "total_cents", "tags", "paid", "created_at"}
+)
+
+// orderRowMapper converts a row of orderColumns into an Order.
+func orderRowMapper(row *spanner.Row) (Order, error) {
+	var e Order
+	err := row.Columns(&e.CustomerID, &e.OrderID, &e.Status, &e.TotalCents, &e.Tags, &e.Paid, &e.CreatedAt)
+	return e, err
+}
+
+// orderKeyExtractor returns the primary key of an Order.
+func orderKeyExtractor(e Order) OrderKey {
+	return OrderKey{CustomerID: e.CustomerID, OrderID: e.OrderID}
+}
+
+// orderMutationBuilder builds a mutation inserting or updating an Order.
+// A commit timestamp column is set to the commit timestamp while its field
+// is zero and keeps the field's value otherwise, so saving a loaded entity
+// does not overwrite it. Zero the field to set it again.
+func orderMutationBuilder(e Order) *spanner.Mutation {
+	var createdAt interface{} = e.CreatedAt
+	if e.CreatedAt.IsZero() {
+		createdAt = spanner.CommitTimestamp
+	}
+	return spanner.InsertOrUpdate(orderTable, orderColumns, []interface{}{e.CustomerID, e.OrderID, e.Status, e.TotalCents, e.Tags, e.Paid, createdAt})
+}
+
+// OrderRepository stores Order entities in the Orders table.
+type OrderRepository struct {
+	base *repokit.SpannerRepository[Order, OrderKey]
+}
+
+// NewOrderRepository creates an OrderRepository. The configure functions can set further
+// builder options, such as a cache or call defaults, before it is built.
+func NewOrderRepository(client *spanner.Client, configure ...func(*repokit.SpannerRepositoryBuilder[Order, OrderKey])) (*OrderRepository, error) {
+	b := repokit.NewSpannerRepositoryBuilder[Order, OrderKey]().
+		WithClient(client).
+		WithTableName(orderTable).
+		WithPrimaryKeys(orderPrimaryKey).
+		WithColumns(orderColumns).
+		WithRowMapper(orderRowMapper).
+		WithMutation(orderMutationBuilder).
+		WithKeyExtractor(orderKeyExtractor).
+		WithInterleave("Customers", true).
+		WithRowDeletionPolicy("created_at", 365)
+	for _, fn := range configure {
+		fn(b)
+	}
+	base, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &OrderRepository{base: base}, nil
+}
+
+// Base returns the underlying repository, for the operations OrderRepository does not wrap.
+func (r *OrderRepository) Base() *repokit.SpannerRepository[Order, OrderKey] {
+	return r.base
+}
+
+// FindByID fetches an Order by its primary key.
+func (r *OrderRepository) FindByID(ctx context.Context, customerID string, orderID int64) (Order, bool, error) {
+	return r.base.FindByID(ctx, OrderKey{CustomerID: customerID, OrderID: orderID}, nil)
+}
+
+// Save inserts or updates an Order.
+func (r *OrderRepository) Save(ctx context.Context, e Order) error {
+	return r.base.Save(ctx, e)
+}
+
+// Update modifies an existing Order.
+func (r *OrderRepository) Update(ctx context.Context, e Order) error {
+	return r.base.Update(ctx, e)
+}
+
+// Delete removes an Order by its primary key.
+func (r *OrderRepository) Delete(ctx context.Context, customerID string, orderID int64) error {
+	return r.base.Delete(ctx, OrderKey{CustomerID: customerID, OrderID: orderID})
+}
+
+// SaveTx inserts or updates an Order within tx.
+func (r *OrderRepository) SaveTx(tx repokit.Transaction, e Order) error {
+	return r.base.SaveTx(tx, e)
+}
+
+// UpdateTx modifies an existing Order within tx.
+func (r *OrderRepository) UpdateTx(tx repokit.Transaction, e Order) error {
+	return r.base.UpdateTx(tx, e)
+}
+
+// DeleteTx removes an Order by its primary key within tx.
+func (r *OrderRepository) DeleteTx(tx repokit.Transaction, customerID string, orderID int64) error {
+	return r.base.DeleteTx(tx, OrderKey{CustomerID: customerID, OrderID: orderID})
+}
@@ -0,0 +1,23 @@
+CREATE TABLE Customers (
+	customer_id STRING(36) NOT NULL,
+	email STRING(MAX) NOT NULL,
+	display_name STRING(MAX),
+	birth_date DATE,
+	created_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (customer_id);
+
+CREATE UNIQUE INDEX CustomersByEmail ON Customers (email);
+
+CREATE TABLE Orders (
+	customer_id STRING(36) NOT NULL,
+	order_id INT64 NOT NULL,
+	status STRING(16) NOT NULL,
+	total_cents INT64,
+	tags ARRAY<STRING(MAX)>,
+	paid BOOL NOT NULL,
+	created_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (customer_id, order_id),
+  INTERLEAVE IN PARENT Customers ON DELETE CASCADE,
+  ROW DELETION POLICY (OLDER_THAN(created_at, INTERVAL 365 DAY));
+
+CREATE INDEX OrdersByStatus ON Orders (status, created_at);
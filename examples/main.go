@@ -31,7 +31,10 @@ func main() {
 	}
 
 	// Initialize repository (non-transactional)
-	userRepository := repository.NewUserNoTxRepository(spannerClient)
+	userRepository, err := repository.NewUserNoTxRepository(spannerClient)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Create new user
 	user := domain.User{
@@ -79,7 +82,10 @@ func main() {
 	txManager := repokit.NewSpannerTransactionManager(spannerClient)
 
 	// Transactional repository version
-	userTxRepository := repository.NewUserTxRepository(spannerClient)
+	userTxRepository, err := repository.NewUserTxRepository(spannerClient)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Users to be inserted within the same transaction
 	userTx1 := domain.User{
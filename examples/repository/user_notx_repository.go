@@ -20,7 +20,7 @@ type UserKey struct {
 
 // userNoTxRepository is a Spanner-backed implementation of UserNoTxRepository.
 type userNoTxRepository struct {
-	base *repokit.SpannerRepository[domain.User]
+	base *repokit.SpannerRepository[domain.User, UserKey]
 }
 
 // UserNoTxRepository defines operations for managing User entities in Spanner.
@@ -42,7 +42,7 @@ type UserNoTxRepository interface {
 // FindByID retrieves a user by ID from Spanner.
 func (u *userNoTxRepository) FindByID(ctx context.Context, userID string) (domain.User, bool, error) {
 	key := UserKey{ID: userID}
-	return u.base.FindByID(ctx, key, columns)
+	return u.base.FindByID(ctx, key, nil)
 }
 
 // Save inserts or updates a user in Spanner.
@@ -69,19 +69,29 @@ func userRowMapper(row *spanner.Row) (domain.User, error) {
 	return u, err
 }
 
+// userKeyExtractor returns the primary key of a User.
+func userKeyExtractor(u domain.User) UserKey {
+	return UserKey{ID: u.UserID}
+}
+
 // userMutationBuilder builds a mutation for inserting or updating a User.
 func userMutationBuilder(u domain.User) *spanner.Mutation {
 	return spanner.InsertOrUpdate(userTable, columns, []interface{}{u.UserID, u.Email})
 }
 
 // NewUserNoTxRepository creates a new UserNoTxRepository backed by Spanner.
-func NewUserNoTxRepository(client *spanner.Client) UserNoTxRepository {
-	base := repokit.NewBaseRepository[domain.User](
-		client,
-		userTable,  // Table name
-		primaryKey, // Primary key columns
-		userRowMapper,
-		userMutationBuilder,
-	)
-	return &userNoTxRepository{base: base}
+func NewUserNoTxRepository(client *spanner.Client) (UserNoTxRepository, error) {
+	base, err := repokit.NewSpannerRepositoryBuilder[domain.User, UserKey]().
+		WithClient(client).
+		WithTableName(userTable).    // Table name
+		WithPrimaryKeys(primaryKey). // Primary key columns
+		WithColumns(columns).        // Columns in userRowMapper order
+		WithRowMapper(userRowMapper).
+		WithMutation(userMutationBuilder).
+		WithKeyExtractor(userKeyExtractor).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	return &userNoTxRepository{base: base}, nil
 }
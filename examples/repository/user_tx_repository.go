@@ -9,7 +9,7 @@ import (
 
 // userTxRepository provides transactional operations for the User entity.
 type userTxRepository struct {
-	base *repokit.SpannerRepository[domain.User]
+	base *repokit.SpannerRepository[domain.User, UserKey]
 }
 
 // UserTxRepository defines the transactional operations available for User.
@@ -31,7 +31,7 @@ func (u *userTxRepository) SaveTx(ctx context.Context, tx repokit.Transaction, u
 }
 
 func (u *userTxRepository) DeleteTx(ctx context.Context, tx repokit.Transaction, userID string) error {
-	return u.base.DeleteTx(tx, userID)
+	return u.base.DeleteTx(tx, UserKey{ID: userID})
 }
 
 func (u *userTxRepository) UpdateTx(ctx context.Context, tx repokit.Transaction, user domain.User) error {
@@ -40,13 +40,18 @@ func (u *userTxRepository) UpdateTx(ctx context.Context, tx repokit.Transaction,
 
 // NewUserTxRepository creates a new transactional User repository backed by Spanner.
 // It requires a spanner.Client and internally uses SpannerRepository for persistence.
-func NewUserTxRepository(client *spanner.Client) UserTxRepository {
-	base := repokit.NewBaseRepository[domain.User](
-		client,
-		userTable,  // Table name
-		primaryKey, // Primary key columns
-		userRowMapper,
-		userMutationBuilder,
-	)
-	return &userTxRepository{base: base}
+func NewUserTxRepository(client *spanner.Client) (UserTxRepository, error) {
+	base, err := repokit.NewSpannerRepositoryBuilder[domain.User, UserKey]().
+		WithClient(client).
+		WithTableName(userTable).    // Table name
+		WithPrimaryKeys(primaryKey). // Primary key columns
+		WithColumns(columns).        // Columns in userRowMapper order
+		WithRowMapper(userRowMapper).
+		WithMutation(userMutationBuilder).
+		WithKeyExtractor(userKeyExtractor).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	return &userTxRepository{base: base}, nil
 }
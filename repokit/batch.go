@@ -0,0 +1,128 @@
+package repokit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultBatchTimeout bounds a batched read when the repository has no
+// default timeout, since no caller's context can cancel it.
+const defaultBatchTimeout = 30 * time.Second
+
+// batcher coalesces concurrent FindByID calls into FindByIDs reads. Calls
+// for the same key share one pending load through singleflight; distinct
+// keys requested within the window are read together.
+type batcher[T any, K any] struct {
+	repo     *SpannerRepository[T, K]
+	window   time.Duration
+	maxBatch int
+	loads    singleflight.Group
+
+	mu      sync.Mutex
+	pending *batch[K, T] // batch collecting keys, nil when none is open
+}
+
+// batch is a set of keys read with a single FindByIDs call.
+type batch[K any, T any] struct {
+	keys    []K
+	parent  trace.Span // span of the first caller, parent of the read
+	timer   *time.Timer
+	done    chan struct{} // closed once results and err are set
+	results map[string]T
+	err     error
+}
+
+// newBatcher creates a batcher reading through repo.
+func newBatcher[T any, K any](repo *SpannerRepository[T, K], window time.Duration, maxBatch int) *batcher[T, K] {
+	return &batcher[T, K]{repo: repo, window: window, maxBatch: maxBatch}
+}
+
+// load returns the entity identified by key, reading it in the next batch.
+// It returns early with ctx's error if ctx is done first; the batch read
+// itself is not canceled, since other callers may be waiting for it.
+func (b *batcher[T, K]) load(ctx context.Context, key K) (T, bool, error) {
+	id := b.repo.keyString(key)
+	ch := b.loads.DoChan(id, func() (interface{}, error) {
+		bt := b.enqueue(ctx, key)
+		<-bt.done
+		if bt.err != nil {
+			return nil, bt.err
+		}
+		entity, found := bt.results[id]
+		return CacheEntry[T]{Entity: entity, Found: found}, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, false, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			var zero T
+			return zero, false, res.Err
+		}
+		entry := res.Val.(CacheEntry[T])
+		return entry.Entity, entry.Found, nil
+	}
+}
+
+// enqueue adds key to the open batch, opening one if needed with the span
+// of ctx as the read's parent, and returns it. A batch is read when its
+// window elapses or when it reaches maxBatch keys.
+func (b *batcher[T, K]) enqueue(ctx context.Context, key K) *batch[K, T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bt := b.pending
+	if bt == nil {
+		bt = &batch[K, T]{parent: trace.SpanFromContext(ctx), done: make(chan struct{})}
+		bt.timer = time.AfterFunc(b.window, func() { b.flush(bt) })
+		b.pending = bt
+	}
+	bt.keys = append(bt.keys, key)
+	if b.maxBatch > 0 && len(bt.keys) >= b.maxBatch {
+		bt.timer.Stop()
+		b.pending = nil
+		go b.run(bt)
+	}
+	return bt
+}
+
+// flush reads bt when its window elapses, unless it was already dispatched
+// because it was full.
+func (b *batcher[T, K]) flush(bt *batch[K, T]) {
+	b.mu.Lock()
+	if b.pending != bt {
+		b.mu.Unlock()
+		return
+	}
+	b.pending = nil
+	b.mu.Unlock()
+	b.run(bt)
+}
+
+// run reads the keys of bt and wakes up its callers. The read is not
+// canceled by any caller, but is bounded by the repository's default timeout
+// or defaultBatchTimeout.
+func (b *batcher[T, K]) run(bt *batch[K, T]) {
+	defer close(bt.done)
+
+	ctx := trace.ContextWithSpan(context.Background(), bt.parent)
+	var opts []CallOption
+	if b.repo.defaults.timeout <= 0 {
+		opts = append(opts, WithTimeout(defaultBatchTimeout))
+	}
+	entities, err := b.repo.FindByIDs(ctx, bt.keys, nil, opts...)
+	if err != nil {
+		bt.err = err
+		return
+	}
+	bt.results = make(map[string]T, len(entities))
+	for _, e := range entities {
+		bt.results[b.repo.keyString(b.repo.keyExtractor(e))] = e
+	}
+}
@@ -0,0 +1,191 @@
+package repokit
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheEntry is a cached read of one primary key. Found is false for a
+// negative entry, recording that no row had the key.
+type CacheEntry[T any] struct {
+	Entity T
+	Found  bool
+}
+
+// CacheStats reports the effectiveness of a cache.
+type CacheStats struct {
+	// Hits counts lookups served from the cache, NegativeHits included.
+	Hits uint64
+	// NegativeHits counts lookups served by a negative entry.
+	NegativeHits uint64
+	// Misses counts lookups that had to read from Spanner.
+	Misses uint64
+	// Evictions counts entries dropped to make room for new ones.
+	Evictions uint64
+	// Entries is the number of entries currently cached.
+	Entries int
+}
+
+// Cache stores the entities read by primary key by a SpannerRepository.
+// Keys are opaque strings derived from the table name and the primary key
+// values. Implementations must be safe for concurrent use; LRUCache is an
+// in-process implementation.
+type Cache[T any] interface {
+	// Get returns the entry cached under key, if any.
+	Get(key string) (CacheEntry[T], bool)
+	// Set caches entry under key. Implementations may ignore negative entries.
+	Set(key string, entry CacheEntry[T])
+	// Delete drops the entry cached under key, if any.
+	Delete(key string)
+	// Stats returns the cache's counters.
+	Stats() CacheStats
+}
+
+// cacheVersions counts the invalidations of cache keys, so that a read which
+// overlapped a write of its key is not cached. Keys share a fixed number of
+// counters; a collision only makes a read skip the cache.
+type cacheVersions [64]atomic.Uint64
+
+// of returns the counter of key.
+func (v *cacheVersions) of(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &v[h.Sum32()%uint32(len(v))]
+}
+
+// LRUCache is an in-process Cache bounded in size, evicting the least
+// recently used entry when full. Entries expire after a TTL.
+type LRUCache[T any] struct {
+	mu          sync.Mutex
+	maxEntries  int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	order       *list.List // front is the most recently used
+	stats       CacheStats
+	now         func() time.Time
+}
+
+// lruItem is the value of an LRUCache list element.
+type lruItem[T any] struct {
+	key     string
+	entry   CacheEntry[T]
+	expires time.Time
+}
+
+var _ Cache[struct{}] = (*LRUCache[struct{}])(nil)
+
+// LRUCacheOption configures an LRUCache.
+type LRUCacheOption func(*lruCacheConfig)
+
+// lruCacheConfig holds the optional settings of an LRUCache.
+type lruCacheConfig struct {
+	negativeTTL time.Duration
+}
+
+// WithNegativeCaching caches the absence of a row for ttl, so repeated
+// lookups of a missing key do not reach Spanner. It is disabled by default.
+func WithNegativeCaching(ttl time.Duration) LRUCacheOption {
+	return func(c *lruCacheConfig) {
+		c.negativeTTL = ttl
+	}
+}
+
+// NewLRUCache creates an LRUCache holding at most maxEntries entries for ttl
+// each. A maxEntries or ttl of zero or less means no limit.
+func NewLRUCache[T any](maxEntries int, ttl time.Duration, opts ...LRUCacheOption) *LRUCache[T] {
+	var cfg lruCacheConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &LRUCache[T]{
+		maxEntries:  maxEntries,
+		ttl:         ttl,
+		negativeTTL: cfg.negativeTTL,
+		entries:     map[string]*list.Element{},
+		order:       list.New(),
+		now:         time.Now,
+	}
+}
+
+// Get returns the entry cached under key, if any and not expired.
+func (c *LRUCache[T]) Get(key string) (CacheEntry[T], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok {
+		item := el.Value.(*lruItem[T])
+		if item.expires.IsZero() || c.now().Before(item.expires) {
+			c.order.MoveToFront(el)
+			c.stats.Hits++
+			if !item.entry.Found {
+				c.stats.NegativeHits++
+			}
+			return item.entry, true
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	return CacheEntry[T]{}, false
+}
+
+// Set caches entry under key. Negative entries are ignored unless negative
+// caching is enabled.
+func (c *LRUCache[T]) Set(key string, entry CacheEntry[T]) {
+	ttl := c.ttl
+	if !entry.Found {
+		if c.negativeTTL <= 0 {
+			return
+		}
+		ttl = c.negativeTTL
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		item := el.Value.(*lruItem[T])
+		item.entry, item.expires = entry, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruItem[T]{key: key, entry: entry, expires: expires})
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete drops the entry cached under key, if any.
+func (c *LRUCache[T]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Stats returns the cache's counters.
+func (c *LRUCache[T]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// remove unlinks el. The caller holds c.mu.
+func (c *LRUCache[T]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruItem[T]).key)
+}
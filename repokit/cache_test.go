@@ -0,0 +1,86 @@
+package repokit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewLRUCache[string](2, time.Minute, WithNegativeCaching(time.Second))
+	c.now = func() time.Time { return now }
+
+	c.Set("a", CacheEntry[string]{Entity: "A", Found: true})
+	c.Set("b", CacheEntry[string]{Entity: "B", Found: true})
+	if got, ok := c.Get("a"); !ok || got.Entity != "A" {
+		t.Fatalf("Get(a) = %+v, %v, want A", got, ok)
+	}
+
+	// b is now the least recently used entry and is evicted.
+	c.Set("missing", CacheEntry[string]{})
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Get(b) hit after eviction")
+	}
+	if got, ok := c.Get("missing"); !ok || got.Found {
+		t.Fatalf("Get(missing) = %+v, %v, want a negative entry", got, ok)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("negative entry outlived its TTL")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get(a) missed before its TTL")
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("entry outlived its TTL")
+	}
+
+	c.Set("c", CacheEntry[string]{Entity: "C", Found: true})
+	c.Delete("c")
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("Get(c) hit after Delete")
+	}
+
+	want := CacheStats{Hits: 3, NegativeHits: 1, Misses: 4, Evictions: 1, Entries: 0}
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLRUCacheIgnoresNegativeEntriesByDefault(t *testing.T) {
+	c := NewLRUCache[string](0, 0)
+	c.Set("missing", CacheEntry[string]{})
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("negative entry cached without WithNegativeCaching")
+	}
+}
+
+func TestCacheReadAfterInvalidation(t *testing.T) {
+	keys, err := newKeyCodec[int64]([]string{"id"})
+	if err != nil {
+		t.Fatalf("newKeyCodec: %v", err)
+	}
+	cache := NewLRUCache[string](10, time.Minute, WithNegativeCaching(time.Minute))
+	repo := &SpannerRepository[string, int64]{tableName: "Users", keys: keys, cache: cache, versions: &cacheVersions{}}
+
+	// A read that started before a write of its key is not cached.
+	version := repo.cacheVersion(1)
+	repo.invalidate(1)
+	repo.cacheRead(1, version, CacheEntry[string]{Entity: "old", Found: true})
+	if _, ok := repo.cached(1); ok {
+		t.Fatal("read overlapping a write was cached")
+	}
+
+	repo.cacheRead(1, repo.cacheVersion(1), CacheEntry[string]{})
+	if entry, ok := repo.cached(1); !ok || entry.Found {
+		t.Fatalf("cached(1) = %+v, %v, want a negative entry", entry, ok)
+	}
+	// The key returned by SaveReturningKey drops the cached absence.
+	returned := int64(1)
+	repo.invalidateReturnedKey(&returned)
+	if _, ok := repo.cached(1); ok {
+		t.Fatal("negative entry survived SaveReturningKey")
+	}
+}
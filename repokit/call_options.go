@@ -0,0 +1,110 @@
+package repokit
+
+import (
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// CallOption configures a single repository call or transaction.
+// Options that do not apply to a call (for example a transaction tag on a
+// read, or any option on MemoryRepository) are ignored.
+type CallOption func(*callOptions)
+
+// callOptions holds the resolved options of a call.
+type callOptions struct {
+	requestTag     string
+	transactionTag string
+	priority       sppb.RequestOptions_Priority
+	timeout        time.Duration
+	retry          RetryPolicy
+}
+
+// WithRequestTag sets the request tag reported in Spanner's query and read
+// statistics (SPANNER_SYS.QUERY_STATS_*, READ_STATS_*). By default repository
+// calls are tagged "table=<table>,op=<operation>".
+func WithRequestTag(tag string) CallOption {
+	return func(o *callOptions) {
+		o.requestTag = tag
+	}
+}
+
+// WithTransactionTag sets the transaction tag reported in Spanner's
+// transaction and lock statistics (SPANNER_SYS.TXN_STATS_*, LOCK_STATS_*).
+// It applies to RunInTransaction and to the repository methods that commit
+// their own transaction (Save, Update, Delete, SaveReturningKey), which are
+// tagged "table=<table>,op=<operation>" by default.
+func WithTransactionTag(tag string) CallOption {
+	return func(o *callOptions) {
+		o.transactionTag = tag
+	}
+}
+
+// WithPriority sets the RPC priority of the call. Set on RunInTransaction, it
+// applies to the commit and is the default for the reads made inside the
+// transaction.
+func WithPriority(priority sppb.RequestOptions_Priority) CallOption {
+	return func(o *callOptions) {
+		o.priority = priority
+	}
+}
+
+// WithTimeout bounds the call, including repokit's own retries, to d.
+// On RunInTransaction it is the total budget of the transaction, covering
+// every attempt Spanner makes after an abort. It overrides the repository or
+// transaction manager default.
+func WithTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithRetryPolicy overrides the repository's default retry policy for the
+// call. It has no effect on calls made inside a transaction.
+func WithRetryPolicy(policy RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retry = policy
+	}
+}
+
+// defaultTag derives a request or transaction tag from the table and operation.
+func defaultTag(table, operation string) string {
+	if table == "" {
+		return ""
+	}
+	return "table=" + table + ",op=" + operation
+}
+
+// resolveCallOptions applies opts over base, the defaults of the repository or
+// transaction, with tags derived from the table and operation.
+func resolveCallOptions(table, operation string, base callOptions, opts []CallOption) callOptions {
+	o := base
+	if tag := defaultTag(table, operation); tag != "" {
+		o.requestTag, o.transactionTag = tag, tag
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// queryOptions returns the options of a SQL query.
+func (o callOptions) queryOptions() spanner.QueryOptions {
+	return spanner.QueryOptions{RequestTag: o.requestTag, Priority: o.priority}
+}
+
+// readOptions returns the options of a read.
+func (o callOptions) readOptions() *spanner.ReadOptions {
+	return &spanner.ReadOptions{RequestTag: o.requestTag, Priority: o.priority}
+}
+
+// applyOptions returns the options of a blind write made with Client.Apply.
+func (o callOptions) applyOptions() []spanner.ApplyOption {
+	return []spanner.ApplyOption{spanner.TransactionTag(o.transactionTag), spanner.Priority(o.priority)}
+}
+
+// transactionOptions returns the options of a read-write transaction.
+func (o callOptions) transactionOptions() spanner.TransactionOptions {
+	return spanner.TransactionOptions{TransactionTag: o.transactionTag, CommitPriority: o.priority}
+}
@@ -0,0 +1,41 @@
+package repokit
+
+import (
+	"testing"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+func TestResolveCallOptions(t *testing.T) {
+	got := resolveCallOptions("Orders", "FindByID", callOptions{}, nil)
+	if got.requestTag != "table=Orders,op=FindByID" || got.transactionTag != "table=Orders,op=FindByID" {
+		t.Fatalf("default tags = %+v, want derived from table and operation", got)
+	}
+
+	got = resolveCallOptions("Orders", "Save", callOptions{}, []CallOption{
+		WithRequestTag("checkout"),
+		WithTransactionTag("checkout-txn"),
+		WithPriority(sppb.RequestOptions_PRIORITY_LOW),
+	})
+	if got.requestTag != "checkout" || got.transactionTag != "checkout-txn" || got.priority != sppb.RequestOptions_PRIORITY_LOW {
+		t.Fatalf("explicit options = %+v, want them to override the defaults", got)
+	}
+
+	tx := resolveCallOptions("", "RunInTransaction", callOptions{}, []CallOption{WithPriority(sppb.RequestOptions_PRIORITY_HIGH)})
+	if tx.requestTag != "" || tx.transactionTag != "" {
+		t.Fatalf("transaction manager tags = %+v, want none without a table", tx)
+	}
+	inherited := callOptions{priority: tx.priority, timeout: time.Second}
+	got = resolveCallOptions("Orders", "CountTx", inherited, nil)
+	if got.priority != sppb.RequestOptions_PRIORITY_HIGH || got.requestTag != "table=Orders,op=CountTx" || got.timeout != time.Second {
+		t.Fatalf("call inside transaction = %+v, want the inherited defaults and its own tag", got)
+	}
+	got = resolveCallOptions("Orders", "CountTx", inherited, []CallOption{
+		WithPriority(sppb.RequestOptions_PRIORITY_MEDIUM),
+		WithTimeout(time.Millisecond),
+	})
+	if got.priority != sppb.RequestOptions_PRIORITY_MEDIUM || got.timeout != time.Millisecond {
+		t.Fatalf("call inside transaction = %+v, want the per-call overrides", got)
+	}
+}
@@ -0,0 +1,829 @@
+package repokit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"golang.org/x/sync/errgroup"
+)
+
+// ModType is the kind of change described by a change stream record.
+type ModType string
+
+// Mod types reported by change streams.
+const (
+	ModInsert ModType = "INSERT"
+	ModUpdate ModType = "UPDATE"
+	ModDelete ModType = "DELETE"
+)
+
+// Change is a change to one row, decoded from a change stream data change
+// record. Before and After are mapped with the repository's row mapper from
+// the key columns and the values captured by the stream: depending on its
+// value_capture_type they may hold only the changed columns, so the mapper
+// should look columns up by name (row.ToStruct) rather than by position.
+type Change[T any] struct {
+	// Table is the changed table.
+	Table string
+	// ModType tells whether the row was inserted, updated or deleted.
+	ModType ModType
+	// CommitTimestamp is the commit timestamp of the change's transaction.
+	CommitTimestamp time.Time
+	// TransactionID identifies the change's transaction; together with
+	// RecordSequence it identifies the change for deduplication.
+	TransactionID string
+	// RecordSequence orders the records of a transaction.
+	RecordSequence string
+	// TransactionTag is the tag of the change's transaction, if any.
+	TransactionTag string
+	// Before is the row before the change. It is nil for inserts and for
+	// streams that do not capture old values.
+	Before *T
+	// After is the row after the change. It is nil for deletes.
+	After *T
+}
+
+// Partition states stored in the checkpoint table.
+const (
+	partitionCreated  = "CREATED"
+	partitionRunning  = "RUNNING"
+	partitionFinished = "FINISHED"
+)
+
+// ChangeStreamCheckpointSchema returns the DDL statement creating a table
+// that stores change stream checkpoints. One table can serve several
+// consumers and streams. table must be a valid identifier, as for
+// WithCheckpoints.
+func ChangeStreamCheckpointSchema(table string) ([]string, error) {
+	quoted, err := quoteIdentifier(table)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint table: %w", err)
+	}
+	return []string{fmt.Sprintf(`CREATE TABLE %s (
+	consumer STRING(MAX) NOT NULL,
+	partition_token STRING(MAX) NOT NULL,
+	parent_tokens ARRAY<STRING(MAX)>,
+	start_timestamp TIMESTAMP NOT NULL,
+	watermark TIMESTAMP NOT NULL,
+	state STRING(16) NOT NULL,
+	updated_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (consumer, partition_token)`, quoted)}, nil
+}
+
+// ChangeStreamOption configures a ChangeStreamReader.
+type ChangeStreamOption func(*changeStreamConfig)
+
+// changeStreamConfig holds the settings of a ChangeStreamReader.
+type changeStreamConfig struct {
+	start              time.Time
+	end                time.Time
+	heartbeat          time.Duration
+	checkpointTable    string
+	consumer           string
+	checkpointInterval time.Duration
+}
+
+// WithChangeStreamStart sets where a reader without checkpoints starts
+// reading. The default is the time Read is called.
+func WithChangeStreamStart(t time.Time) ChangeStreamOption {
+	return func(c *changeStreamConfig) {
+		c.start = t
+	}
+}
+
+// WithChangeStreamEnd makes Read return once every change committed before
+// t has been handled. By default Read runs until its context is done.
+func WithChangeStreamEnd(t time.Time) ChangeStreamOption {
+	return func(c *changeStreamConfig) {
+		c.end = t
+	}
+}
+
+// WithHeartbeat sets how often Spanner reports progress on a partition that
+// has no changes. The default is 10 seconds.
+func WithHeartbeat(d time.Duration) ChangeStreamOption {
+	return func(c *changeStreamConfig) {
+		c.heartbeat = d
+	}
+}
+
+// WithCheckpoints persists the progress of every partition in table, created
+// with ChangeStreamCheckpointSchema, under the consumer name, so a later Read
+// with the same consumer resumes where this one stopped. Without it, every
+// Read starts over from WithChangeStreamStart.
+func WithCheckpoints(table, consumer string) ChangeStreamOption {
+	return func(c *changeStreamConfig) {
+		c.checkpointTable = table
+		c.consumer = consumer
+	}
+}
+
+// WithCheckpointInterval sets how often the progress of a partition is
+// saved. Changes handled since the last checkpoint are delivered again after
+// a restart. The default is one second.
+func WithCheckpointInterval(d time.Duration) ChangeStreamOption {
+	return func(c *changeStreamConfig) {
+		c.checkpointInterval = d
+	}
+}
+
+// ChangeStreamReader reads a change stream watching a repository's table and
+// decodes its data change records into Change values. It queries the
+// stream's READ_<stream> function for every partition, follows child
+// partitions as Spanner splits and merges them, and starts a child only once
+// all its parents are finished, so the changes of a row are delivered in
+// commit order. Delivery is at-least-once.
+type ChangeStreamReader[T any, K any] struct {
+	repo   *SpannerRepository[T, K]
+	stream string
+	cfg    changeStreamConfig
+	store  checkpointStore
+	// query reads a partition, calling fn with the records of each row. It
+	// is a field so tests can replace Spanner.
+	query func(ctx context.Context, p partitionCheckpoint, fn func([]*changeRecord) error) error
+}
+
+// NewChangeStreamReader creates a reader of stream, a change stream that
+// watches the table of repo.
+func NewChangeStreamReader[T any, K any](repo *SpannerRepository[T, K], stream string, opts ...ChangeStreamOption) (*ChangeStreamReader[T, K], error) {
+	if err := validateIdentifier(stream); err != nil {
+		return nil, fmt.Errorf("change stream: %w", err)
+	}
+	cfg := changeStreamConfig{heartbeat: 10 * time.Second, checkpointInterval: time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &ChangeStreamReader[T, K]{repo: repo, stream: stream, cfg: cfg, store: &memoryCheckpoints{}}
+	if cfg.checkpointTable != "" {
+		if err := validateIdentifier(cfg.checkpointTable); err != nil {
+			return nil, fmt.Errorf("checkpoint table: %w", err)
+		}
+		if cfg.consumer == "" {
+			return nil, fmt.Errorf("checkpoint consumer name is required")
+		}
+		r.store = &spannerCheckpoints{client: repo.client, table: cfg.checkpointTable, consumer: cfg.consumer}
+	}
+	r.query = r.queryPartition
+	return r, nil
+}
+
+// Read delivers the changes of the stream to handle until ctx is done, the
+// end timestamp is reached or handle returns an error, which Read returns.
+// Partitions are read concurrently, so handle must be safe for concurrent
+// use; the changes of a given row are never delivered concurrently.
+func (r *ChangeStreamReader[T, K]) Read(ctx context.Context, handle func(ctx context.Context, change Change[T]) error) error {
+	saved, err := r.store.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	run := &changeStreamRun[T, K]{
+		reader:     r,
+		handle:     handle,
+		group:      g,
+		partitions: make(map[string]*partitionCheckpoint, len(saved)),
+		started:    map[string]bool{},
+	}
+	for i := range saved {
+		run.partitions[saved[i].Token] = &saved[i]
+	}
+	if len(saved) == 0 {
+		start := r.cfg.start
+		if start.IsZero() {
+			start = time.Now()
+		}
+		// The initial query, without a partition token, returns the
+		// partitions to read from start.
+		root := &partitionCheckpoint{Start: start, Watermark: start, State: partitionCreated}
+		if err := r.store.save(ctx, *root); err != nil {
+			return err
+		}
+		run.partitions[""] = root
+	}
+
+	run.mu.Lock()
+	run.scheduleLocked(ctx)
+	run.mu.Unlock()
+	return g.Wait()
+}
+
+// queryPartition runs the change stream query of p from its watermark.
+func (r *ChangeStreamReader[T, K]) queryPartition(ctx context.Context, p partitionCheckpoint, fn func([]*changeRecord) error) error {
+	token := spanner.NullString{StringVal: p.Token, Valid: p.Token != ""}
+	end := spanner.NullTime{Time: r.cfg.end, Valid: !r.cfg.end.IsZero()}
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT ChangeRecord FROM READ_%s (
+	start_timestamp => @start_timestamp,
+	end_timestamp => @end_timestamp,
+	partition_token => @partition_token,
+	heartbeat_milliseconds => @heartbeat_milliseconds)`, r.stream),
+		Params: map[string]interface{}{
+			"start_timestamp":        p.Watermark,
+			"end_timestamp":          end,
+			"partition_token":        token,
+			"heartbeat_milliseconds": r.cfg.heartbeat.Milliseconds(),
+		},
+	}
+	return r.repo.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		records, err := decodeChangeRecords(row)
+		if err != nil {
+			return err
+		}
+		return fn(records)
+	})
+}
+
+// changeStreamRun is the state of one Read call.
+type changeStreamRun[T any, K any] struct {
+	reader *ChangeStreamReader[T, K]
+	handle func(ctx context.Context, change Change[T]) error
+	group  *errgroup.Group
+
+	mu         sync.Mutex
+	partitions map[string]*partitionCheckpoint
+	started    map[string]bool
+}
+
+// scheduleLocked starts reading every partition whose parents are finished.
+// The caller holds s.mu.
+func (s *changeStreamRun[T, K]) scheduleLocked(ctx context.Context) {
+	for token, p := range s.partitions {
+		if p.State == partitionFinished || s.started[token] || !s.parentsFinishedLocked(p) {
+			continue
+		}
+		s.started[token] = true
+		p := *p
+		s.group.Go(func() error { return s.readPartition(ctx, p) })
+	}
+}
+
+// parentsFinishedLocked reports whether every known parent of p is finished.
+// Parents missing from the checkpoints were finished by an earlier run.
+func (s *changeStreamRun[T, K]) parentsFinishedLocked(p *partitionCheckpoint) bool {
+	for _, parent := range p.Parents {
+		if pp, ok := s.partitions[parent]; ok && pp.State != partitionFinished {
+			return false
+		}
+	}
+	return true
+}
+
+// readPartition reads p to its end, then marks it finished and starts the
+// children that were waiting for it. The checkpoint of a partition that
+// split or merged is deleted instead, since its children are checkpointed
+// and treat a missing parent as finished.
+func (s *changeStreamRun[T, K]) readPartition(ctx context.Context, p partitionCheckpoint) error {
+	store := s.reader.store
+	p.State = partitionRunning
+	if err := store.save(ctx, p); err != nil {
+		return err
+	}
+	lastSaved := time.Now()
+	advance := func(watermark time.Time) error {
+		if watermark.After(p.Watermark) {
+			p.Watermark = watermark
+		}
+		if time.Since(lastSaved) < s.reader.cfg.checkpointInterval {
+			return nil
+		}
+		lastSaved = time.Now()
+		return store.save(ctx, p)
+	}
+
+	hasChildren := false
+	err := s.reader.query(ctx, p, func(records []*changeRecord) error {
+		for _, record := range records {
+			for _, dc := range record.DataChangeRecord {
+				if err := s.handleDataChange(ctx, dc); err != nil {
+					return err
+				}
+				if err := advance(dc.CommitTimestamp); err != nil {
+					return err
+				}
+			}
+			for _, hb := range record.HeartbeatRecord {
+				if err := advance(hb.Timestamp); err != nil {
+					return err
+				}
+			}
+			for _, cp := range record.ChildPartitionsRecord {
+				if err := s.addChildren(ctx, cp); err != nil {
+					return err
+				}
+				hasChildren = hasChildren || len(cp.ChildPartitions) > 0
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read change stream %s partition %q: %w", s.reader.stream, p.Token, err)
+	}
+
+	p.State = partitionFinished
+	if hasChildren {
+		err = store.delete(ctx, p.Token)
+	} else {
+		err = store.save(ctx, p)
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitions[p.Token].State = partitionFinished
+	s.scheduleLocked(ctx)
+	return nil
+}
+
+// addChildren records the child partitions announced by cp. A child of
+// several parents is announced by each of them and recorded once.
+func (s *changeStreamRun[T, K]) addChildren(ctx context.Context, cp *childPartitionsRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, child := range cp.ChildPartitions {
+		if _, ok := s.partitions[child.Token]; ok {
+			continue
+		}
+		p := &partitionCheckpoint{
+			Token:     child.Token,
+			Parents:   child.ParentPartitionTokens,
+			Start:     cp.StartTimestamp,
+			Watermark: cp.StartTimestamp,
+			State:     partitionCreated,
+		}
+		if err := s.reader.store.save(ctx, *p); err != nil {
+			return err
+		}
+		s.partitions[child.Token] = p
+	}
+	// Children are started once their parents, including the one reading
+	// cp, are finished.
+	return nil
+}
+
+// handleDataChange decodes the mods of dc and hands them to the handler.
+// Records of other tables watched by the same stream are skipped.
+func (s *changeStreamRun[T, K]) handleDataChange(ctx context.Context, dc *dataChangeRecord) error {
+	if dc.TableName != s.reader.repo.tableName {
+		return nil
+	}
+	changes, err := decodeChanges(dc, func(row *spanner.Row) (T, error) {
+		return s.reader.repo.loadRow(ctx, row)
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := s.handle(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeRecord is a row of a change stream query. Each of its fields holds
+// zero or more records of one kind.
+type changeRecord struct {
+	DataChangeRecord      []*dataChangeRecord      `spanner:"data_change_record"`
+	HeartbeatRecord       []*heartbeatRecord       `spanner:"heartbeat_record"`
+	ChildPartitionsRecord []*childPartitionsRecord `spanner:"child_partitions_record"`
+}
+
+// dataChangeRecord holds the mods of one table made by a transaction.
+type dataChangeRecord struct {
+	CommitTimestamp                      time.Time     `spanner:"commit_timestamp"`
+	RecordSequence                       string        `spanner:"record_sequence"`
+	ServerTransactionID                  string        `spanner:"server_transaction_id"`
+	IsLastRecordInTransactionInPartition bool          `spanner:"is_last_record_in_transaction_in_partition"`
+	TableName                            string        `spanner:"table_name"`
+	ColumnTypes                          []*columnType `spanner:"column_types"`
+	Mods                                 []*mod        `spanner:"mods"`
+	ModType                              string        `spanner:"mod_type"`
+	ValueCaptureType                     string        `spanner:"value_capture_type"`
+	NumberOfRecordsInTransaction         int64         `spanner:"number_of_records_in_transaction"`
+	NumberOfPartitionsInTransaction      int64         `spanner:"number_of_partitions_in_transaction"`
+	TransactionTag                       string        `spanner:"transaction_tag"`
+	IsSystemTransaction                  bool          `spanner:"is_system_transaction"`
+}
+
+// columnType describes a column of a dataChangeRecord.
+type columnType struct {
+	Name            string           `spanner:"name"`
+	Type            spanner.NullJSON `spanner:"type"`
+	IsPrimaryKey    bool             `spanner:"is_primary_key"`
+	OrdinalPosition int64            `spanner:"ordinal_position"`
+}
+
+// mod is the change to one row, as JSON objects keyed by column name.
+type mod struct {
+	Keys      spanner.NullJSON `spanner:"keys"`
+	NewValues spanner.NullJSON `spanner:"new_values"`
+	OldValues spanner.NullJSON `spanner:"old_values"`
+}
+
+// heartbeatRecord reports that a partition has no changes up to Timestamp.
+type heartbeatRecord struct {
+	Timestamp time.Time `spanner:"timestamp"`
+}
+
+// childPartitionsRecord announces the partitions that continue the current
+// one from StartTimestamp.
+type childPartitionsRecord struct {
+	StartTimestamp  time.Time         `spanner:"start_timestamp"`
+	RecordSequence  string            `spanner:"record_sequence"`
+	ChildPartitions []*childPartition `spanner:"child_partitions"`
+}
+
+// childPartition is a partition announced by a childPartitionsRecord.
+type childPartition struct {
+	Token                 string   `spanner:"token"`
+	ParentPartitionTokens []string `spanner:"parent_partition_tokens"`
+}
+
+// decodeChangeRecords decodes a change stream query row. Fields added to the
+// records by later Spanner versions are ignored.
+func decodeChangeRecords(row *spanner.Row) ([]*changeRecord, error) {
+	var wrapper struct {
+		ChangeRecord []*changeRecord `spanner:"ChangeRecord"`
+	}
+	if err := row.ToStructLenient(&wrapper); err != nil {
+		return nil, fmt.Errorf("decode change record: %w", err)
+	}
+	return wrapper.ChangeRecord, nil
+}
+
+// decodeChanges maps the mods of dc into Change values with mapper.
+func decodeChanges[T any](dc *dataChangeRecord, mapper func(*spanner.Row) (T, error)) ([]Change[T], error) {
+	columns := append([]*columnType(nil), dc.ColumnTypes...)
+	sort.Slice(columns, func(i, j int) bool { return columns[i].OrdinalPosition < columns[j].OrdinalPosition })
+	types := make(map[string]spannerType, len(columns))
+	for _, c := range columns {
+		t, err := parseSpannerType(c.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		types[c.Name] = t
+	}
+
+	modType := ModType(dc.ModType)
+	capturesOld := dc.ValueCaptureType == "OLD_AND_NEW_VALUES" || dc.ValueCaptureType == "NEW_ROW_AND_OLD_VALUES"
+	changes := make([]Change[T], len(dc.Mods))
+	for i, m := range dc.Mods {
+		c := Change[T]{
+			Table:           dc.TableName,
+			ModType:         modType,
+			CommitTimestamp: dc.CommitTimestamp,
+			TransactionID:   dc.ServerTransactionID,
+			RecordSequence:  dc.RecordSequence,
+			TransactionTag:  dc.TransactionTag,
+		}
+		image := func(values spanner.NullJSON) (*T, error) {
+			row, err := modRow(columns, types, m.Keys, values)
+			if err != nil {
+				return nil, err
+			}
+			entity, err := mapper(row)
+			if err != nil {
+				return nil, err
+			}
+			return &entity, nil
+		}
+		var err error
+		if modType != ModInsert && capturesOld {
+			if c.Before, err = image(m.OldValues); err != nil {
+				return nil, fmt.Errorf("decode %s old values: %w", dc.TableName, err)
+			}
+		}
+		if modType != ModDelete {
+			if c.After, err = image(m.NewValues); err != nil {
+				return nil, fmt.Errorf("decode %s new values: %w", dc.TableName, err)
+			}
+		}
+		changes[i] = c
+	}
+	return changes, nil
+}
+
+// modRow builds a row from the key columns and values of a mod, in column
+// order. Columns missing from both are left out of the row.
+func modRow(columns []*columnType, types map[string]spannerType, keys, values spanner.NullJSON) (*spanner.Row, error) {
+	fields := map[string]json.RawMessage{}
+	for _, obj := range []spanner.NullJSON{keys, values} {
+		if !obj.Valid {
+			continue
+		}
+		m, err := jsonObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			fields[k] = v
+		}
+	}
+
+	var (
+		names []string
+		vals  []interface{}
+	)
+	for _, c := range columns {
+		raw, ok := fields[c.Name]
+		if !ok {
+			continue
+		}
+		v, err := types[c.Name].decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		names = append(names, c.Name)
+		vals = append(vals, v)
+	}
+	return spanner.NewRow(names, vals)
+}
+
+// jsonObject returns the members of a JSON object column value.
+func jsonObject(v spanner.NullJSON) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v.Value)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("decode mod values: %w", err)
+	}
+	return m, nil
+}
+
+// spannerType is a column type as described by a change stream, e.g.
+// {"code":"ARRAY","array_element_type":{"code":"INT64"}}.
+type spannerType struct {
+	Code             string       `json:"code"`
+	ArrayElementType *spannerType `json:"array_element_type"`
+}
+
+// parseSpannerType decodes a column_types type.
+func parseSpannerType(v spanner.NullJSON) (spannerType, error) {
+	var t spannerType
+	b, err := json.Marshal(v.Value)
+	if err == nil {
+		err = json.Unmarshal(b, &t)
+	}
+	if err != nil {
+		return t, fmt.Errorf("decode column type: %w", err)
+	}
+	if t.Code == "ARRAY" && t.ArrayElementType == nil {
+		return t, fmt.Errorf("array column type without element type")
+	}
+	return t, nil
+}
+
+// errUnsupportedType is returned for column types that cannot be decoded.
+var errUnsupportedType = errors.New("unsupported change stream column type")
+
+// decode converts the JSON value of a column into a Go value Spanner can
+// encode, using Null types so NULL values keep their type.
+func (t spannerType) decode(raw json.RawMessage) (interface{}, error) {
+	if t.Code == "ARRAY" {
+		return t.ArrayElementType.decodeArray(raw)
+	}
+	null := string(raw) == "null"
+	var s string
+	switch t.Code {
+	case "STRING", "INT64", "TIMESTAMP", "DATE", "BYTES", "NUMERIC", "JSON":
+		if !null {
+			// INT64 values may be encoded as JSON numbers or strings.
+			if err := json.Unmarshal(raw, &s); err != nil && t.Code != "INT64" {
+				return nil, err
+			} else if err != nil {
+				s = string(raw)
+			}
+		}
+	}
+
+	switch t.Code {
+	case "STRING":
+		return spanner.NullString{StringVal: s, Valid: !null}, nil
+	case "INT64":
+		if null {
+			return spanner.NullInt64{}, nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		return spanner.NullInt64{Int64: n, Valid: true}, err
+	case "BOOL":
+		var b spanner.NullBool
+		if !null {
+			b.Valid = true
+			if err := json.Unmarshal(raw, &b.Bool); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case "FLOAT64", "FLOAT32":
+		var f float64
+		if !null {
+			if err := json.Unmarshal(raw, &f); err != nil {
+				// NaN and infinities are encoded as strings.
+				if err := json.Unmarshal(raw, &s); err != nil {
+					return nil, err
+				}
+				if f, err = strconv.ParseFloat(s, 64); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if t.Code == "FLOAT32" {
+			return spanner.NullFloat32{Float32: float32(f), Valid: !null}, nil
+		}
+		return spanner.NullFloat64{Float64: f, Valid: !null}, nil
+	case "TIMESTAMP":
+		if null {
+			return spanner.NullTime{}, nil
+		}
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		return spanner.NullTime{Time: ts, Valid: true}, err
+	case "DATE":
+		if null {
+			return spanner.NullDate{}, nil
+		}
+		d, err := civil.ParseDate(s)
+		return spanner.NullDate{Date: d, Valid: true}, err
+	case "BYTES":
+		if null {
+			return []byte(nil), nil
+		}
+		return base64.StdEncoding.DecodeString(s)
+	case "NUMERIC":
+		if null {
+			return spanner.NullNumeric{}, nil
+		}
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid NUMERIC value %q", s)
+		}
+		return spanner.NullNumeric{Numeric: *r, Valid: true}, nil
+	case "JSON":
+		if null {
+			return spanner.NullJSON{}, nil
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, err
+		}
+		return spanner.NullJSON{Value: v, Valid: true}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedType, t.Code)
+}
+
+// decodeArray converts a JSON array of elements of type t into a typed
+// slice. A NULL array decodes to a nil slice.
+func (t spannerType) decodeArray(raw json.RawMessage) (interface{}, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return nil, err
+	}
+	var (
+		out interface{}
+		err error
+	)
+	switch t.Code {
+	case "STRING":
+		out, err = decodeElems[spanner.NullString](t, elems)
+	case "INT64":
+		out, err = decodeElems[spanner.NullInt64](t, elems)
+	case "BOOL":
+		out, err = decodeElems[spanner.NullBool](t, elems)
+	case "FLOAT64":
+		out, err = decodeElems[spanner.NullFloat64](t, elems)
+	case "FLOAT32":
+		out, err = decodeElems[spanner.NullFloat32](t, elems)
+	case "TIMESTAMP":
+		out, err = decodeElems[spanner.NullTime](t, elems)
+	case "DATE":
+		out, err = decodeElems[spanner.NullDate](t, elems)
+	case "BYTES":
+		out, err = decodeElems[[]byte](t, elems)
+	case "NUMERIC":
+		out, err = decodeElems[spanner.NullNumeric](t, elems)
+	case "JSON":
+		out, err = decodeElems[spanner.NullJSON](t, elems)
+	default:
+		return nil, fmt.Errorf("%w: ARRAY<%s>", errUnsupportedType, t.Code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeElems decodes elems as values of type t.
+func decodeElems[E any](t spannerType, elems []json.RawMessage) ([]E, error) {
+	if elems == nil {
+		return nil, nil
+	}
+	out := make([]E, len(elems))
+	for i, raw := range elems {
+		v, err := t.decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("array element %d: %w", i, err)
+		}
+		out[i] = v.(E)
+	}
+	return out, nil
+}
+
+// partitionCheckpoint is the progress of a change stream partition. The
+// initial partition, read without a token, has an empty Token.
+type partitionCheckpoint struct {
+	Token     string
+	Parents   []string
+	Start     time.Time
+	Watermark time.Time // changes committed before it have been handled
+	State     string
+}
+
+// checkpointStore persists partition checkpoints.
+type checkpointStore interface {
+	load(ctx context.Context) ([]partitionCheckpoint, error)
+	save(ctx context.Context, p partitionCheckpoint) error
+	delete(ctx context.Context, token string) error
+}
+
+// memoryCheckpoints keeps checkpoints for the duration of a Read only.
+type memoryCheckpoints struct{}
+
+// load returns no checkpoints.
+func (*memoryCheckpoints) load(context.Context) ([]partitionCheckpoint, error) {
+	return nil, nil
+}
+
+// save does nothing.
+func (*memoryCheckpoints) save(context.Context, partitionCheckpoint) error {
+	return nil
+}
+
+// delete does nothing.
+func (*memoryCheckpoints) delete(context.Context, string) error {
+	return nil
+}
+
+// spannerCheckpoints stores the checkpoints of a consumer in a table created
+// with ChangeStreamCheckpointSchema.
+type spannerCheckpoints struct {
+	client   *spanner.Client
+	table    string
+	consumer string
+}
+
+// checkpointColumns lists the columns of a checkpoint table, in
+// partitionCheckpoint order after the consumer.
+var checkpointColumns = []string{"partition_token", "parent_tokens", "start_timestamp", "watermark", "state"}
+
+// load returns the checkpoints of the consumer.
+func (c *spannerCheckpoints) load(ctx context.Context) ([]partitionCheckpoint, error) {
+	keys := spanner.KeyRange{Start: spanner.Key{c.consumer}, End: spanner.Key{c.consumer}, Kind: spanner.ClosedClosed}
+	var checkpoints []partitionCheckpoint
+	err := c.client.Single().Read(ctx, c.table, keys, checkpointColumns).Do(func(row *spanner.Row) error {
+		var p partitionCheckpoint
+		if err := row.Columns(&p.Token, &p.Parents, &p.Start, &p.Watermark, &p.State); err != nil {
+			return err
+		}
+		checkpoints = append(checkpoints, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load change stream checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// save writes p.
+func (c *spannerCheckpoints) save(ctx context.Context, p partitionCheckpoint) error {
+	m := spanner.InsertOrUpdate(c.table,
+		append([]string{"consumer", "updated_at"}, checkpointColumns...),
+		[]interface{}{c.consumer, spanner.CommitTimestamp, p.Token, p.Parents, p.Start, p.Watermark, p.State})
+	if _, err := c.client.Apply(ctx, []*spanner.Mutation{m}); err != nil {
+		return fmt.Errorf("save change stream checkpoint: %w", err)
+	}
+	return nil
+}
+
+// delete removes the checkpoint of the partition identified by token.
+func (c *spannerCheckpoints) delete(ctx context.Context, token string) error {
+	m := spanner.Delete(c.table, spanner.Key{c.consumer, token})
+	if _, err := c.client.Apply(ctx, []*spanner.Mutation{m}); err != nil {
+		return fmt.Errorf("delete change stream checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package repokit
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/spannertest"
+	"cloud.google.com/go/spanner/spansql"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type item struct {
+	ID   int64    `spanner:"id"`
+	Name string   `spanner:"name"`
+	Tags []string `spanner:"tags"`
+}
+
+func itemMapper(row *spanner.Row) (item, error) {
+	var it item
+	err := row.ToStruct(&it)
+	return it, err
+}
+
+// jsonValue returns a valid NullJSON holding v.
+func jsonValue(v interface{}) spanner.NullJSON {
+	return spanner.NullJSON{Value: v, Valid: true}
+}
+
+func itemColumnTypes() []*columnType {
+	return []*columnType{
+		{Name: "tags", Type: jsonValue(map[string]interface{}{"code": "ARRAY", "array_element_type": map[string]interface{}{"code": "STRING"}}), OrdinalPosition: 3},
+		{Name: "id", Type: jsonValue(map[string]interface{}{"code": "INT64"}), IsPrimaryKey: true, OrdinalPosition: 1},
+		{Name: "name", Type: jsonValue(map[string]interface{}{"code": "STRING"}), OrdinalPosition: 2},
+	}
+}
+
+func TestDecodeChanges(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dc := &dataChangeRecord{
+		CommitTimestamp:     ts,
+		RecordSequence:      "00000001",
+		ServerTransactionID: "tx-1",
+		TableName:           "Items",
+		ColumnTypes:         itemColumnTypes(),
+		Mods: []*mod{{
+			Keys:      jsonValue(map[string]interface{}{"id": "7"}),
+			OldValues: jsonValue(map[string]interface{}{"name": "old", "tags": []interface{}{"a"}}),
+			NewValues: jsonValue(map[string]interface{}{"name": "new", "tags": nil}),
+		}},
+		ModType:          "UPDATE",
+		ValueCaptureType: "OLD_AND_NEW_VALUES",
+	}
+
+	changes, err := decodeChanges(dc, itemMapper)
+	if err != nil {
+		t.Fatalf("decodeChanges: %v", err)
+	}
+	want := Change[item]{
+		Table:           "Items",
+		ModType:         ModUpdate,
+		CommitTimestamp: ts,
+		TransactionID:   "tx-1",
+		RecordSequence:  "00000001",
+		Before:          &item{ID: 7, Name: "old", Tags: []string{"a"}},
+		After:           &item{ID: 7, Name: "new"},
+	}
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0], want) {
+		t.Fatalf("decodeChanges = %+v, want %+v", changes, want)
+	}
+
+	dc.ModType, dc.ValueCaptureType = "DELETE", "NEW_ROW"
+	dc.Mods[0].OldValues, dc.Mods[0].NewValues = jsonValue(map[string]interface{}{}), jsonValue(map[string]interface{}{})
+	changes, err = decodeChanges(dc, itemMapper)
+	if err != nil {
+		t.Fatalf("decodeChanges: %v", err)
+	}
+	if c := changes[0]; c.Before != nil || c.After != nil {
+		t.Fatalf("delete without old values = %+v, want no row images", c)
+	}
+
+	dc.ModType = "INSERT"
+	dc.Mods[0].NewValues = jsonValue(map[string]interface{}{"name": "new"})
+	dc.ColumnTypes[2].Type = jsonValue(map[string]interface{}{"code": "STRUCT"})
+	if _, err := decodeChanges(dc, itemMapper); err == nil {
+		t.Fatal("decodeChanges accepted an unsupported column type")
+	}
+}
+
+func TestDecodeChangeRecords(t *testing.T) {
+	records := []*changeRecord{{
+		HeartbeatRecord: []*heartbeatRecord{{Timestamp: time.Unix(100, 0).UTC()}},
+		ChildPartitionsRecord: []*childPartitionsRecord{{
+			StartTimestamp:  time.Unix(50, 0).UTC(),
+			RecordSequence:  "1",
+			ChildPartitions: []*childPartition{{Token: "child", ParentPartitionTokens: []string{"parent"}}},
+		}},
+	}}
+	row, err := spanner.NewRow([]string{"ChangeRecord"}, []interface{}{records})
+	if err != nil {
+		t.Fatalf("NewRow: %v", err)
+	}
+	got, err := decodeChangeRecords(row)
+	if err != nil {
+		t.Fatalf("decodeChangeRecords: %v", err)
+	}
+	if len(got) != 1 || !got[0].HeartbeatRecord[0].Timestamp.Equal(time.Unix(100, 0)) ||
+		got[0].ChildPartitionsRecord[0].ChildPartitions[0].Token != "child" {
+		t.Fatalf("decodeChangeRecords = %+v", got)
+	}
+}
+
+// newChangeStreamTestRepo returns an Items repository on a spannertest
+// server that also holds a Checkpoints table.
+func newChangeStreamTestRepo(t *testing.T) *SpannerRepository[item, int64] {
+	t.Helper()
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("start spannertest server: %v", err)
+	}
+	srv.SetLogger(func(string, ...interface{}) {})
+	t.Cleanup(srv.Close)
+
+	checkpoints, err := ChangeStreamCheckpointSchema("Checkpoints")
+	if err != nil {
+		t.Fatalf("ChangeStreamCheckpointSchema: %v", err)
+	}
+	schema := append([]string{`CREATE TABLE Items (
+		id INT64 NOT NULL,
+		name STRING(MAX),
+		tags ARRAY<STRING(MAX)>,
+	) PRIMARY KEY (id)`}, checkpoints...)
+	ddl, err := spansql.ParseDDL("schema", strings.Join(schema, ";\n"))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	if err := srv.UpdateDDL(ddl); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial spannertest server: %v", err)
+	}
+	client, err := spanner.NewClientWithConfig(context.Background(), "projects/p/instances/i/databases/d",
+		spanner.ClientConfig{DisableNativeMetrics: true}, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("create spanner client: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	repo, err := NewSpannerRepositoryBuilder[item, int64]().
+		WithClient(client).
+		WithTableName("Items").
+		WithPrimaryKeys([]string{"id"}).
+		WithRowMapper(itemMapper).
+		WithMutation(func(it item) *spanner.Mutation { return spanner.InsertOrUpdate("Items", nil, nil) }).
+		WithKeyExtractor(func(it item) int64 { return it.ID }).
+		Build()
+	if err != nil {
+		t.Fatalf("build repository: %v", err)
+	}
+	return repo
+}
+
+// fakePartitions replays scripted change records per partition token.
+type fakePartitions struct {
+	mu       sync.Mutex
+	script   map[string][]*changeRecord
+	finished map[string]bool
+	queries  []partitionCheckpoint
+	// finishedAtStart records which partitions had finished when each
+	// partition was queried.
+	finishedAtStart map[string][]string
+}
+
+func (f *fakePartitions) query(_ context.Context, p partitionCheckpoint, fn func([]*changeRecord) error) error {
+	f.mu.Lock()
+	f.queries = append(f.queries, p)
+	var done []string
+	for token := range f.finished {
+		done = append(done, token)
+	}
+	f.finishedAtStart[p.Token] = done
+	records := f.script[p.Token]
+	f.mu.Unlock()
+
+	for _, r := range records {
+		if err := fn([]*changeRecord{r}); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	f.finished[p.Token] = true
+	f.mu.Unlock()
+	return nil
+}
+
+func children(start time.Time, parents []string, tokens ...string) *changeRecord {
+	cp := &childPartitionsRecord{StartTimestamp: start}
+	for _, token := range tokens {
+		cp.ChildPartitions = append(cp.ChildPartitions, &childPartition{Token: token, ParentPartitionTokens: parents})
+	}
+	return &changeRecord{ChildPartitionsRecord: []*childPartitionsRecord{cp}}
+}
+
+func insertItem(ts time.Time, id string, name string) *changeRecord {
+	return &changeRecord{DataChangeRecord: []*dataChangeRecord{{
+		CommitTimestamp: ts,
+		TableName:       "Items",
+		ColumnTypes:     itemColumnTypes(),
+		Mods: []*mod{{
+			Keys:      jsonValue(map[string]interface{}{"id": id}),
+			NewValues: jsonValue(map[string]interface{}{"name": name, "tags": []interface{}{}}),
+		}},
+		ModType:          "INSERT",
+		ValueCaptureType: "NEW_ROW",
+	}}}
+}
+
+func TestChangeStreamReaderFollowsPartitions(t *testing.T) {
+	repo := newChangeStreamTestRepo(t)
+	ctx := context.Background()
+	t0 := time.Unix(1000, 0).UTC()
+
+	fake := &fakePartitions{
+		script: map[string][]*changeRecord{
+			"":  {children(t0, nil, "A", "B")},
+			"A": {insertItem(t0.Add(time.Second), "1", "first"), children(t0.Add(2*time.Second), []string{"A", "B"}, "C")},
+			"B": {{HeartbeatRecord: []*heartbeatRecord{{Timestamp: t0.Add(3 * time.Second)}}}, children(t0.Add(2*time.Second), []string{"A", "B"}, "C")},
+			"C": {insertItem(t0.Add(4*time.Second), "2", "second"), {DataChangeRecord: []*dataChangeRecord{{TableName: "Other"}}}},
+		},
+		finished:        map[string]bool{},
+		finishedAtStart: map[string][]string{},
+	}
+	newReader := func() *ChangeStreamReader[item, int64] {
+		r, err := NewChangeStreamReader(repo, "ItemsStream",
+			WithChangeStreamStart(t0), WithCheckpoints("Checkpoints", "indexer"), WithCheckpointInterval(0))
+		if err != nil {
+			t.Fatalf("NewChangeStreamReader: %v", err)
+		}
+		r.query = fake.query
+		return r
+	}
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	handle := func(_ context.Context, c Change[item]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, c.After.Name)
+		return nil
+	}
+	if err := newReader().Read(ctx, handle); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if len(fake.queries) != 4 {
+		t.Fatalf("queried %d partitions, want 4 (each once): %+v", len(fake.queries), fake.queries)
+	}
+	if done := fake.finishedAtStart["C"]; len(done) != 3 {
+		t.Fatalf("C started after %v finished, want after both of its parents", done)
+	}
+	if !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Fatalf("handled %v, want the Items changes in order", got)
+	}
+
+	saved, err := newReader().store.load(ctx)
+	if err != nil {
+		t.Fatalf("load checkpoints: %v", err)
+	}
+	// Partitions that split or merged are deleted once their children are
+	// checkpointed, so only C, which has no children, is left.
+	if len(saved) != 1 || saved[0].Token != "C" || saved[0].State != partitionFinished ||
+		!saved[0].Watermark.Equal(t0.Add(4*time.Second)) {
+		t.Fatalf("checkpoints = %+v, want C finished at its last change", saved)
+	}
+
+	// A restarted reader resumes unfinished partitions from their watermark.
+	resume := partitionCheckpoint{Token: "D", Parents: []string{"C"}, Start: t0, Watermark: t0.Add(5 * time.Second), State: partitionRunning}
+	if err := newReader().store.save(ctx, resume); err != nil {
+		t.Fatalf("save checkpoint: %v", err)
+	}
+	fake.queries = nil
+	if err := newReader().Read(ctx, handle); err != nil {
+		t.Fatalf("Read after restart: %v", err)
+	}
+	if len(fake.queries) != 1 || fake.queries[0].Token != "D" || !fake.queries[0].Watermark.Equal(resume.Watermark) {
+		t.Fatalf("queries after restart = %+v, want D from its watermark", fake.queries)
+	}
+}
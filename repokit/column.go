@@ -0,0 +1,105 @@
+package repokit
+
+// Column is a typed reference to a table column whose values are read into
+// Go values of type V. Conditions built from a Column only accept values of
+// that type, so a misspelled column or a mismatched value fails to compile
+// rather than at query time. `repokit gen` generates the columns of each
+// table, e.g. OrderCols.Status.Eq("PAID").
+type Column[V any] struct {
+	name string
+}
+
+// NewColumn returns the column named name holding values of type V.
+func NewColumn[V any](name string) Column[V] {
+	return Column[V]{name: name}
+}
+
+// Name returns the column name.
+func (c Column[V]) Name() string {
+	return c.name
+}
+
+// String returns the column name.
+func (c Column[V]) String() string {
+	return c.name
+}
+
+// Eq matches rows where the column equals value.
+func (c Column[V]) Eq(value V) Condition {
+	return Eq(c.name, value)
+}
+
+// Ne matches rows where the column is different from value.
+func (c Column[V]) Ne(value V) Condition {
+	return Ne(c.name, value)
+}
+
+// Lt matches rows where the column is lower than value.
+func (c Column[V]) Lt(value V) Condition {
+	return Lt(c.name, value)
+}
+
+// Le matches rows where the column is lower than or equal to value.
+func (c Column[V]) Le(value V) Condition {
+	return Le(c.name, value)
+}
+
+// Gt matches rows where the column is greater than value.
+func (c Column[V]) Gt(value V) Condition {
+	return Gt(c.name, value)
+}
+
+// Ge matches rows where the column is greater than or equal to value.
+func (c Column[V]) Ge(value V) Condition {
+	return Ge(c.name, value)
+}
+
+// Like matches rows where the column matches the SQL LIKE pattern. It is
+// only meaningful for STRING columns.
+func (c Column[V]) Like(pattern string) Condition {
+	return Like(c.name, pattern)
+}
+
+// In matches rows where the column is one of values.
+func (c Column[V]) In(values ...V) Condition {
+	return In(c.name, values)
+}
+
+// IsNull matches rows where the column is NULL.
+func (c Column[V]) IsNull() Condition {
+	return IsNull(c.name)
+}
+
+// IsNotNull matches rows where the column is not NULL.
+func (c Column[V]) IsNotNull() Condition {
+	return IsNotNull(c.name)
+}
+
+// Asc sorts by the column in ascending order.
+func (c Column[V]) Asc() Ordering {
+	return Asc(c.name)
+}
+
+// Desc sorts by the column in descending order.
+func (c Column[V]) Desc() Ordering {
+	return Desc(c.name)
+}
+
+// ColumnRef is implemented by every Column.
+type ColumnRef interface {
+	Name() string
+}
+
+// ColumnNames returns the names of columns, for the APIs taking a column
+// list such as FindByID, FindWhere and Project.
+//
+// Example:
+//
+//	repo.FindWhere(ctx, criteria, repokit.ColumnNames(OrderCols.OrderID, OrderCols.Status))
+func ColumnNames(columns ...ColumnRef) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name()
+	}
+	return names
+}
@@ -0,0 +1,42 @@
+package repokit
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestTypedColumns(t *testing.T) {
+	status := NewColumn[string]("status")
+	amount := NewColumn[int64]("amount")
+	note := NewColumn[spanner.NullString]("order")
+
+	criteria := Where(status.In("PAID", "SHIPPED"), amount.Ge(10), note.IsNull()).
+		OrderBy(amount.Desc(), note.Asc())
+	params := map[string]interface{}{}
+	where, err := criteria.build(params, identifierPolicy{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if want := "`status` IN UNNEST(@p0) AND `amount` >= @p1 AND `order` IS NULL"; where != want {
+		t.Fatalf("build = %q, want %q", where, want)
+	}
+	if !reflect.DeepEqual(params, map[string]interface{}{"p0": []string{"PAID", "SHIPPED"}, "p1": int64(10)}) {
+		t.Fatalf("params = %v", params)
+	}
+	orderBy, err := buildOrderClause(criteria, identifierPolicy{})
+	if err != nil || orderBy != " ORDER BY `amount` DESC, `order`" {
+		t.Fatalf("buildOrderClause = %q, %v", orderBy, err)
+	}
+	if got := criteria.And(status.Ne("NEW")); len(got.Orderings) != 2 || len(got.Conditions) != 4 {
+		t.Fatalf("And dropped orderings: %+v", got)
+	}
+
+	if got := ColumnNames(status, amount); !reflect.DeepEqual(got, []string{"status", "amount"}) {
+		t.Fatalf("ColumnNames = %v", got)
+	}
+	if _, err := buildOrderClause(Criteria{}.OrderBy(Asc("amount; DROP")), identifierPolicy{}); err == nil {
+		t.Fatal("buildOrderClause accepted an invalid identifier")
+	}
+}
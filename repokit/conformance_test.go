@@ -0,0 +1,28 @@
+package repokit_test
+
+import (
+	"testing"
+
+	"github.com/Waelson/go-spanner-repo/repokittest"
+)
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	repokittest.RunRepositorySuite(t, repokittest.MemoryFactory())
+}
+
+func TestSpannerRepositoryConformance(t *testing.T) {
+	repokittest.RunRepositorySuite(t, repokittest.SpannerFactory(repokittest.NewSpannertestClient))
+	repokittest.RunSpannerRepositorySuite(t, repokittest.NewSpannertestClient)
+}
+
+// TestSpannerRepositoryConformanceUnquoted runs the cases spannertest cannot
+// parse, on statements without quoted identifiers.
+func TestSpannerRepositoryConformanceUnquoted(t *testing.T) {
+	repokittest.RunRepositorySuite(t, repokittest.SpannerFactory(repokittest.NewUnquotingSpannertestClient))
+	repokittest.RunSpannerRepositorySuite(t, repokittest.NewUnquotingSpannertestClient)
+}
+
+func TestSpannerRepositoryConformanceEmulator(t *testing.T) {
+	repokittest.RunRepositorySuite(t, repokittest.SpannerFactory(repokittest.NewEmulatorClient))
+	repokittest.RunSpannerRepositorySuite(t, repokittest.NewEmulatorClient)
+}
@@ -0,0 +1,201 @@
+package repokit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operator is a comparison operator used by a Condition.
+type Operator string
+
+const (
+	OpEq        Operator = "="
+	OpNe        Operator = "!="
+	OpLt        Operator = "<"
+	OpLe        Operator = "<="
+	OpGt        Operator = ">"
+	OpGe        Operator = ">="
+	OpLike      Operator = "LIKE"
+	OpIn        Operator = "IN"
+	OpIsNull    Operator = "IS NULL"
+	OpIsNotNull Operator = "IS NOT NULL"
+)
+
+// Condition is a single predicate on a column.
+type Condition struct {
+	Column string
+	Op     Operator
+	Value  interface{}
+}
+
+// Eq matches rows where column equals value.
+func Eq(column string, value interface{}) Condition {
+	return Condition{Column: column, Op: OpEq, Value: value}
+}
+
+// Ne matches rows where column is different from value.
+func Ne(column string, value interface{}) Condition {
+	return Condition{Column: column, Op: OpNe, Value: value}
+}
+
+// Lt matches rows where column is lower than value.
+func Lt(column string, value interface{}) Condition {
+	return Condition{Column: column, Op: OpLt, Value: value}
+}
+
+// Le matches rows where column is lower than or equal to value.
+func Le(column string, value interface{}) Condition {
+	return Condition{Column: column, Op: OpLe, Value: value}
+}
+
+// Gt matches rows where column is greater than value.
+func Gt(column string, value interface{}) Condition {
+	return Condition{Column: column, Op: OpGt, Value: value}
+}
+
+// Ge matches rows where column is greater than or equal to value.
+func Ge(column string, value interface{}) Condition {
+	return Condition{Column: column, Op: OpGe, Value: value}
+}
+
+// Like matches rows where column matches the SQL LIKE pattern.
+func Like(column string, pattern string) Condition {
+	return Condition{Column: column, Op: OpLike, Value: pattern}
+}
+
+// In matches rows where column is one of the values. values must be a slice
+// of a type supported by the Spanner client (e.g. []string, []int64).
+func In(column string, values interface{}) Condition {
+	return Condition{Column: column, Op: OpIn, Value: values}
+}
+
+// IsNull matches rows where column is NULL.
+func IsNull(column string) Condition {
+	return Condition{Column: column, Op: OpIsNull}
+}
+
+// IsNotNull matches rows where column is not NULL.
+func IsNotNull(column string) Condition {
+	return Condition{Column: column, Op: OpIsNotNull}
+}
+
+// Ordering sorts query results by a column.
+type Ordering struct {
+	Column string
+	Desc   bool
+}
+
+// Asc sorts by column in ascending order.
+func Asc(column string) Ordering {
+	return Ordering{Column: column}
+}
+
+// Desc sorts by column in descending order.
+func Desc(column string) Ordering {
+	return Ordering{Column: column, Desc: true}
+}
+
+// Criteria is a set of conditions combined with AND, and the order of the
+// matching rows. The zero value matches every row in the table.
+// Orderings apply to FindWhere, Project and GroupBy; aggregates ignore them.
+type Criteria struct {
+	Conditions []Condition
+	Orderings  []Ordering
+}
+
+// Where creates a Criteria from the given conditions.
+//
+// Example:
+//
+//	criteria := repokit.Where(repokit.Eq("status", "PAID"), repokit.Gt("amount", 100))
+func Where(conditions ...Condition) Criteria {
+	return Criteria{Conditions: conditions}
+}
+
+// And returns a copy of the criteria with the given conditions appended.
+func (c Criteria) And(conditions ...Condition) Criteria {
+	merged := make([]Condition, 0, len(c.Conditions)+len(conditions))
+	merged = append(merged, c.Conditions...)
+	merged = append(merged, conditions...)
+	return Criteria{Conditions: merged, Orderings: c.Orderings}
+}
+
+// OrderBy returns a copy of the criteria with the given orderings appended.
+//
+// Example:
+//
+//	criteria := repokit.Where(repokit.Eq("status", "PAID")).OrderBy(repokit.Desc("amount"))
+func (c Criteria) OrderBy(orderings ...Ordering) Criteria {
+	merged := make([]Ordering, 0, len(c.Orderings)+len(orderings))
+	merged = append(merged, c.Orderings...)
+	merged = append(merged, orderings...)
+	return Criteria{Conditions: c.Conditions, Orderings: merged}
+}
+
+// IsEmpty reports whether the criteria has no conditions.
+func (c Criteria) IsEmpty() bool {
+	return len(c.Conditions) == 0
+}
+
+// addParam registers value in params under a generated name and returns
+// the placeholder to be used in the SQL text.
+func addParam(params map[string]interface{}, value interface{}) string {
+	name := fmt.Sprintf("p%d", len(params))
+	params[name] = value
+	return "@" + name
+}
+
+// build renders the criteria as a boolean SQL expression, registering the
+// condition values in params. Column names are validated and quoted through
+// idents; values are always bound as parameters and never interpolated.
+// It returns an empty string for empty criteria.
+func (c Criteria) build(params map[string]interface{}, idents identifierPolicy) (string, error) {
+	parts := make([]string, 0, len(c.Conditions))
+	for _, cond := range c.Conditions {
+		column, err := idents.column(cond.Column)
+		if err != nil {
+			return "", err
+		}
+		switch cond.Op {
+		case OpEq, OpNe, OpLt, OpLe, OpGt, OpGe, OpLike:
+			parts = append(parts, fmt.Sprintf("%s %s %s", column, cond.Op, addParam(params, cond.Value)))
+		case OpIn:
+			parts = append(parts, fmt.Sprintf("%s IN UNNEST(%s)", column, addParam(params, cond.Value)))
+		case OpIsNull, OpIsNotNull:
+			parts = append(parts, fmt.Sprintf("%s %s", column, cond.Op))
+		default:
+			return "", fmt.Errorf("unsupported operator %q on column %s", cond.Op, cond.Column)
+		}
+	}
+	return strings.Join(parts, " AND "), nil
+}
+
+// buildCriteriaClause renders the criteria as a " WHERE ..." suffix, or an
+// empty string when the criteria has no conditions.
+func buildCriteriaClause(criteria Criteria, params map[string]interface{}, idents identifierPolicy) (string, error) {
+	expr, err := criteria.build(params, idents)
+	if err != nil || expr == "" {
+		return "", err
+	}
+	return " WHERE " + expr, nil
+}
+
+// buildOrderClause renders the orderings of criteria as an " ORDER BY ..."
+// suffix, or an empty string when it has none.
+func buildOrderClause(criteria Criteria, idents identifierPolicy) (string, error) {
+	if len(criteria.Orderings) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(criteria.Orderings))
+	for i, o := range criteria.Orderings {
+		column, err := idents.column(o.Column)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = column
+		if o.Desc {
+			parts[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
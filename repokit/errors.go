@@ -0,0 +1,78 @@
+package repokit
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorClass groups the errors returned by repokit into a small, stable set
+// of categories suitable for metric labels and retry decisions.
+type ErrorClass string
+
+// Error classes returned by ClassifyError.
+const (
+	ErrorClassNone              ErrorClass = ""
+	ErrorClassInvalidArgument   ErrorClass = "invalid_argument"
+	ErrorClassNotFound          ErrorClass = "not_found"
+	ErrorClassAlreadyExists     ErrorClass = "already_exists"
+	ErrorClassPrecondition      ErrorClass = "failed_precondition"
+	ErrorClassAborted           ErrorClass = "aborted"
+	ErrorClassSessionNotFound   ErrorClass = "session_not_found"
+	ErrorClassCanceled          ErrorClass = "canceled"
+	ErrorClassDeadlineExceeded  ErrorClass = "deadline_exceeded"
+	ErrorClassUnavailable       ErrorClass = "unavailable"
+	ErrorClassResourceExhausted ErrorClass = "resource_exhausted"
+	ErrorClassPermission        ErrorClass = "permission_denied"
+	ErrorClassInternal          ErrorClass = "internal"
+)
+
+// ClassifyError returns the ErrorClass of err, or ErrorClassNone if err is nil.
+// Identifier and allowlist errors are classified as invalid arguments and
+// schema drift as a failed precondition; other errors are classified by their
+// gRPC code. Errors without a code are internal.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, ErrInvalidIdentifier), errors.Is(err, ErrColumnNotAllowed):
+		return ErrorClassInvalidArgument
+	case errors.Is(err, ErrSchemaDrift):
+		return ErrorClassPrecondition
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassDeadlineExceeded
+	}
+
+	switch spanner.ErrCode(err) {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return ErrorClassInvalidArgument
+	case codes.NotFound:
+		if strings.Contains(err.Error(), "Session not found") {
+			return ErrorClassSessionNotFound
+		}
+		return ErrorClassNotFound
+	case codes.AlreadyExists:
+		return ErrorClassAlreadyExists
+	case codes.FailedPrecondition:
+		return ErrorClassPrecondition
+	case codes.Aborted:
+		return ErrorClassAborted
+	case codes.Canceled:
+		return ErrorClassCanceled
+	case codes.DeadlineExceeded:
+		return ErrorClassDeadlineExceeded
+	case codes.Unavailable:
+		return ErrorClassUnavailable
+	case codes.ResourceExhausted:
+		return ErrorClassResourceExhausted
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return ErrorClassPermission
+	default:
+		return ErrorClassInternal
+	}
+}
@@ -0,0 +1,35 @@
+package repokit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ErrorClassNone},
+		{fmt.Errorf("table Orders column: %w", ErrInvalidIdentifier), ErrorClassInvalidArgument},
+		{ErrColumnNotAllowed, ErrorClassInvalidArgument},
+		{context.Canceled, ErrorClassCanceled},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), ErrorClassDeadlineExceeded},
+		{status.Error(codes.NotFound, "row not found"), ErrorClassNotFound},
+		{status.Error(codes.NotFound, "Session not found: projects/p/sessions/s"), ErrorClassSessionNotFound},
+		{status.Error(codes.Aborted, "transaction aborted"), ErrorClassAborted},
+		{status.Error(codes.Unavailable, "connection reset"), ErrorClassUnavailable},
+		{status.Error(codes.AlreadyExists, "row exists"), ErrorClassAlreadyExists},
+		{status.Error(codes.Unauthenticated, "no token"), ErrorClassPermission},
+		{fmt.Errorf("invalid transaction type"), ErrorClassInternal},
+	}
+	for _, tc := range tests {
+		if got := ClassifyError(tc.err); got != tc.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package repokit
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner"
+)
+
+// BeforeSaver is implemented by entities that normalize, validate or derive
+// fields before Save, Update, SaveTx and UpdateTx build their mutation.
+// Implement it on the pointer type to modify the entity being written.
+// An error aborts the write.
+type BeforeSaver interface {
+	BeforeSave(ctx context.Context) error
+}
+
+// AfterSaver is implemented by entities notified once Save or Update has
+// applied their mutation, or once SaveTx or UpdateTx has buffered it. An
+// error is returned to the caller, but after Save or Update the write has
+// already committed and is not rolled back; only inside a transaction does
+// it make the transaction roll back, if the caller returns it. Use
+// BeforeSaver to reject a write.
+type AfterSaver interface {
+	AfterSave(ctx context.Context) error
+}
+
+// AfterLoader is implemented by entities that fill derived fields or check
+// invariants once the row mapper has read them. Implement it on the pointer
+// type to modify the entity. An error aborts the read.
+type AfterLoader interface {
+	AfterLoad(ctx context.Context) error
+}
+
+// hooks are the lifecycle hooks of a repository: those of the interfaces T
+// implements first, then those registered on the builder, in order.
+type hooks[T any, K any] struct {
+	beforeSave   []func(ctx context.Context, entity *T) error
+	afterSave    []func(ctx context.Context, entity T) error
+	afterLoad    []func(ctx context.Context, entity *T) error
+	beforeDelete []func(ctx context.Context, key K) error
+}
+
+// entityHook returns entity, or the value it points to, as an H.
+func entityHook[H any, T any](entity *T) (H, bool) {
+	if h, ok := any(entity).(H); ok {
+		return h, true
+	}
+	h, ok := any(*entity).(H)
+	return h, ok
+}
+
+// entityHooks returns the hooks of the lifecycle interfaces T implements,
+// through a value or a pointer receiver.
+func entityHooks[T any, K any]() hooks[T, K] {
+	var h hooks[T, K]
+	var zero T
+	if _, ok := entityHook[BeforeSaver](&zero); ok {
+		h.beforeSave = append(h.beforeSave, func(ctx context.Context, entity *T) error {
+			s, _ := entityHook[BeforeSaver](entity)
+			return s.BeforeSave(ctx)
+		})
+	}
+	if _, ok := entityHook[AfterSaver](&zero); ok {
+		h.afterSave = append(h.afterSave, func(ctx context.Context, entity T) error {
+			s, _ := entityHook[AfterSaver](&entity)
+			return s.AfterSave(ctx)
+		})
+	}
+	if _, ok := entityHook[AfterLoader](&zero); ok {
+		h.afterLoad = append(h.afterLoad, func(ctx context.Context, entity *T) error {
+			l, _ := entityHook[AfterLoader](entity)
+			return l.AfterLoad(ctx)
+		})
+	}
+	return h
+}
+
+// runBeforeSave runs the BeforeSave hooks on entity.
+func (h *hooks[T, K]) runBeforeSave(ctx context.Context, entity *T) error {
+	for _, fn := range h.beforeSave {
+		if err := fn(ctx, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAfterSave runs the AfterSave hooks on entity.
+func (h *hooks[T, K]) runAfterSave(ctx context.Context, entity T) error {
+	for _, fn := range h.afterSave {
+		if err := fn(ctx, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAfterLoad runs the AfterLoad hooks on entity.
+func (h *hooks[T, K]) runAfterLoad(ctx context.Context, entity *T) error {
+	for _, fn := range h.afterLoad {
+		if err := fn(ctx, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBeforeDelete runs the BeforeDelete hooks on key.
+func (h *hooks[T, K]) runBeforeDelete(ctx context.Context, key K) error {
+	for _, fn := range h.beforeDelete {
+		if err := fn(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRow maps row with the row mapper and runs the AfterLoad hooks.
+func (r *SpannerRepository[T, K]) loadRow(ctx context.Context, row *spanner.Row) (T, error) {
+	entity, err := r.rowMapper(row)
+	if err != nil {
+		return entity, err
+	}
+	if err := r.hooks.runAfterLoad(ctx, &entity); err != nil {
+		return entity, err
+	}
+	return entity, nil
+}
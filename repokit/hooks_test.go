@@ -0,0 +1,73 @@
+package repokit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type hookedUser struct {
+	Email  string
+	Loaded bool
+}
+
+func (u *hookedUser) BeforeSave(context.Context) error {
+	if u.Email == "" {
+		return errors.New("email is required")
+	}
+	u.Email = strings.ToLower(u.Email)
+	return nil
+}
+
+func (u *hookedUser) AfterLoad(context.Context) error {
+	u.Loaded = true
+	return nil
+}
+
+type auditedUser struct{ Email string }
+
+func (u auditedUser) AfterSave(context.Context) error {
+	if u.Email == "fail" {
+		return errors.New("audit failed")
+	}
+	return nil
+}
+
+func TestEntityHooks(t *testing.T) {
+	ctx := context.Background()
+
+	h := entityHooks[hookedUser, string]()
+	if len(h.beforeSave) != 1 || len(h.afterLoad) != 1 || len(h.afterSave) != 0 {
+		t.Fatalf("hooks of hookedUser = %d/%d/%d, want BeforeSave and AfterLoad",
+			len(h.beforeSave), len(h.afterSave), len(h.afterLoad))
+	}
+	u := hookedUser{Email: "Ann@Example.com"}
+	if err := h.runBeforeSave(ctx, &u); err != nil || u.Email != "ann@example.com" {
+		t.Fatalf("BeforeSave = (%q, %v), want normalized email", u.Email, err)
+	}
+	if err := h.runBeforeSave(ctx, &hookedUser{}); err == nil {
+		t.Fatal("BeforeSave without email succeeded")
+	}
+	if err := h.runAfterLoad(ctx, &u); err != nil || !u.Loaded {
+		t.Fatalf("AfterLoad = (%+v, %v), want Loaded", u, err)
+	}
+
+	// A pointer entity type uses the methods of the pointer it holds.
+	hp := entityHooks[*hookedUser, string]()
+	p := &hookedUser{Email: "BOB@example.com"}
+	if err := hp.runBeforeSave(ctx, &p); err != nil || p.Email != "bob@example.com" {
+		t.Fatalf("BeforeSave of *hookedUser = (%q, %v), want normalized email", p.Email, err)
+	}
+
+	ha := entityHooks[auditedUser, string]()
+	if len(ha.afterSave) != 1 || len(ha.beforeSave) != 0 {
+		t.Fatalf("hooks of auditedUser = %d/%d, want AfterSave only", len(ha.beforeSave), len(ha.afterSave))
+	}
+	if err := ha.runAfterSave(ctx, auditedUser{Email: "fail"}); err == nil {
+		t.Fatal("AfterSave error was not returned")
+	}
+	if h := entityHooks[int, string](); len(h.beforeSave)+len(h.afterSave)+len(h.afterLoad) != 0 {
+		t.Fatal("int has lifecycle hooks")
+	}
+}
@@ -0,0 +1,146 @@
+package repokit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// keyCodec converts typed keys of type K into Spanner key values in primary
+// key order. K is either a struct with one field per primary key column
+// (matched by `spanner` tag, otherwise by field name, ignoring case as Spanner
+// does; see fieldColumn) or, for
+// single-column primary keys, a scalar type such as string, int64, time.Time
+// or spanner.NullString.
+//
+// The field layout is resolved once, when the repository is built, so a wrong
+// key struct fails at Build time instead of turning into a NULL key value.
+type keyCodec[K any] struct {
+	columns    []string
+	fieldIndex []int // K struct field for each primary key column; nil when K is scalar
+}
+
+// newKeyCodec validates K against the primary key columns and builds a codec.
+func newKeyCodec[K any](primaryKeys []string) (*keyCodec[K], error) {
+	if len(primaryKeys) == 0 {
+		return nil, fmt.Errorf("at least one primary key column is required")
+	}
+
+	t := reflect.TypeOf((*K)(nil)).Elem()
+	if t.Kind() != reflect.Struct || isScalarKeyType(t) {
+		if len(primaryKeys) != 1 {
+			return nil, fmt.Errorf("key type %s is a scalar but the primary key has %d columns", t, len(primaryKeys))
+		}
+		return &keyCodec[K]{columns: primaryKeys}, nil
+	}
+
+	byColumn := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldColumn(t.Field(i))
+		if !ok {
+			continue
+		}
+		if _, dup := byColumn[strings.ToLower(name)]; dup {
+			return nil, fmt.Errorf("key type %s maps column %s more than once", t, name)
+		}
+		byColumn[strings.ToLower(name)] = i
+	}
+
+	index := make([]int, len(primaryKeys))
+	for i, col := range primaryKeys {
+		fi, ok := byColumn[strings.ToLower(col)]
+		if !ok {
+			return nil, fmt.Errorf("key type %s has no field for primary key column %s", t, col)
+		}
+		index[i] = fi
+		delete(byColumn, strings.ToLower(col))
+	}
+	for _, fi := range byColumn {
+		name, _ := fieldColumn(t.Field(fi))
+		return nil, fmt.Errorf("key type %s field %s is not a primary key column", t, name)
+	}
+
+	return &keyCodec[K]{columns: primaryKeys, fieldIndex: index}, nil
+}
+
+// encoderType is the type of spanner.Encoder.
+var encoderType = reflect.TypeOf((*spanner.Encoder)(nil)).Elem()
+
+// isScalarKeyType reports whether the struct type t is a single Spanner value,
+// such as time.Time, civil.Date, big.Rat, spanner.NullString or a custom
+// spanner.Encoder, rather than a key struct with one field per column.
+func isScalarKeyType(t reflect.Type) bool {
+	if t.Implements(encoderType) || reflect.PointerTo(t).Implements(encoderType) {
+		return true
+	}
+	switch t.PkgPath() {
+	case "time", "math/big", "cloud.google.com/go/civil", "cloud.google.com/go/spanner":
+		return true
+	}
+	return false
+}
+
+// values returns the key values in primary key order.
+func (c *keyCodec[K]) values(key K) []interface{} {
+	if c.fieldIndex == nil {
+		return []interface{}{key}
+	}
+	v := reflect.ValueOf(key)
+	values := make([]interface{}, len(c.fieldIndex))
+	for i, fi := range c.fieldIndex {
+		values[i] = v.Field(fi).Interface()
+	}
+	return values
+}
+
+// spannerKey converts key into a spanner.Key.
+func (c *keyCodec[K]) spannerKey(key K) spanner.Key {
+	return spanner.Key(c.values(key))
+}
+
+// criteria returns a Criteria matching exactly the row identified by key.
+func (c *keyCodec[K]) criteria(key K) Criteria {
+	values := c.values(key)
+	conditions := make([]Condition, len(c.columns))
+	for i, col := range c.columns {
+		conditions[i] = Eq(col, values[i])
+	}
+	return Criteria{Conditions: conditions}
+}
+
+// pageToken returns the FindPage token identifying key: the key value itself
+// for single-column keys, or a []interface{} with one value per key column.
+func (c *keyCodec[K]) pageToken(key K) interface{} {
+	values := c.values(key)
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// checkPageSize rejects the page size of a FindPage call unless it is
+// positive, so every implementation fails the same way.
+func checkPageSize(pageSize int) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+	return nil
+}
+
+// pageTokenValues converts a FindPage token back into key values.
+// It returns nil for an empty token, meaning the first page.
+func (c *keyCodec[K]) pageTokenValues(token interface{}) ([]interface{}, error) {
+	if token == nil || token == "" {
+		return nil, nil
+	}
+	if len(c.columns) == 1 {
+		return []interface{}{token}, nil
+	}
+	values, ok := token.([]interface{})
+	if !ok || len(values) != len(c.columns) {
+		return nil, fmt.Errorf("page token must hold %d key values, got: %v", len(c.columns), token)
+	}
+	return values, nil
+}
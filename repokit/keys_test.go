@@ -0,0 +1,79 @@
+package repokit
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+)
+
+type orderKey struct {
+	CustomerID string `spanner:"customer_id"`
+	OrderID    int64  `spanner:"order_id"`
+}
+
+type encodedKey struct{ a, b string }
+
+func (k encodedKey) EncodeSpanner() (interface{}, error) { return k.a + "/" + k.b, nil }
+
+func TestNewKeyCodecScalarStructs(t *testing.T) {
+	day := civil.Date{Year: 2025, Month: 1, Day: 2}
+	checkScalar(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	checkScalar(t, day)
+	checkScalar(t, *big.NewRat(1, 3))
+	checkScalar(t, spanner.NullString{StringVal: "u1", Valid: true})
+	checkScalar(t, spanner.NullInt64{Int64: 7, Valid: true})
+	checkScalar(t, encodedKey{a: "x", b: "y"})
+
+	if _, err := newKeyCodec[time.Time]([]string{"day", "id"}); err == nil {
+		t.Fatal("newKeyCodec[time.Time] accepted a two-column primary key")
+	}
+
+	keys, err := newKeyCodec[orderKey]([]string{"customer_id", "order_id"})
+	if err != nil {
+		t.Fatalf("newKeyCodec[orderKey]: %v", err)
+	}
+	if got, want := keys.values(orderKey{"c1", 2}), []interface{}{"c1", int64(2)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("values = %v, want %v", got, want)
+	}
+}
+
+// checkScalar checks that key is used as the value of a single key column.
+func checkScalar[K any](t *testing.T, key K) {
+	t.Helper()
+	keys, err := newKeyCodec[K]([]string{"id"})
+	if err != nil {
+		t.Fatalf("newKeyCodec[%T]: %v", key, err)
+	}
+	if got := keys.values(key); len(got) != 1 || !reflect.DeepEqual(got[0], key) {
+		t.Fatalf("values(%v) = %v, want the key itself", key, got)
+	}
+}
+
+func TestKeyCodecAndProjectionShareColumnNames(t *testing.T) {
+	type untaggedKey struct {
+		Region string
+		ID     int64
+		Note   string `spanner:"-"`
+	}
+	columns, err := structColumns(reflect.TypeOf(untaggedKey{}))
+	if err != nil {
+		t.Fatalf("structColumns: %v", err)
+	}
+	if !reflect.DeepEqual(columns, []string{"Region", "ID"}) {
+		t.Fatalf("structColumns = %v, want the field names", columns)
+	}
+	// Spanner identifiers are case-insensitive, so both spellings match.
+	for _, primaryKeys := range [][]string{columns, {"region", "id"}} {
+		keys, err := newKeyCodec[untaggedKey](primaryKeys)
+		if err != nil {
+			t.Fatalf("newKeyCodec(%v): %v", primaryKeys, err)
+		}
+		if got := keys.values(untaggedKey{"eu", 7, "x"}); !reflect.DeepEqual(got, []interface{}{"eu", int64(7)}) {
+			t.Fatalf("values = %v, want [eu 7]", got)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package repokit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MemoryRepository is an in-memory implementation of Repository intended for
+// unit tests. It follows the semantics of SpannerRepository:
+//
+//   - entities are kept in primary key order, which drives FindAll, FindByIDs
+//     and FindPage;
+//   - Save is an upsert, while Update fails with codes.NotFound when the row
+//     does not exist, like a SpannerRepository whose WithUpdateMutation
+//     builder returns spanner.Update;
+//   - *Tx writes are buffered and only become visible when the surrounding
+//     MemoryTransactionManager transaction commits.
+//
+// The columns arguments are accepted for interface compatibility and ignored:
+// entities are always returned whole.
+type MemoryRepository[T any, K any] struct {
+	mu           sync.RWMutex
+	keys         *keyCodec[K]
+	keyExtractor func(entity T) K
+	rows         map[string]memoryRow[T]
+}
+
+// memoryRow is an entity stored together with its primary key values.
+type memoryRow[T any] struct {
+	key    []interface{}
+	entity T
+}
+
+// memoryWrite is a write to a repository guarded by mu. apply performs it and
+// returns a function that reverts it; both must be called with mu held.
+type memoryWrite struct {
+	mu    *sync.RWMutex
+	apply func() (undo func(), err error)
+}
+
+// run applies w on its own, holding its lock.
+func (w memoryWrite) run() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.apply()
+	return err
+}
+
+var _ Repository[struct{}, string] = (*MemoryRepository[struct{}, string])(nil)
+
+// NewMemoryRepository creates an empty MemoryRepository. primaryKeys and the
+// key type K are validated the same way SpannerRepositoryBuilder.Build does.
+func NewMemoryRepository[T any, K any](primaryKeys []string, keyExtractor func(entity T) K) (*MemoryRepository[T, K], error) {
+	if keyExtractor == nil {
+		return nil, fmt.Errorf("key extractor is required")
+	}
+	keys, err := newKeyCodec[K](primaryKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryRepository[T, K]{
+		keys:         keys,
+		keyExtractor: keyExtractor,
+		rows:         make(map[string]memoryRow[T]),
+	}, nil
+}
+
+// rowID returns the map key identifying a row.
+func rowID(key []interface{}) string {
+	return spanner.Key(key).String()
+}
+
+// sortedRows returns a snapshot of all rows in primary key order.
+// The caller must hold r.mu.
+func (r *MemoryRepository[T, K]) sortedRows() []memoryRow[T] {
+	rows := make([]memoryRow[T], 0, len(r.rows))
+	for _, row := range r.rows {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return compareKeys(rows[i].key, rows[j].key) < 0
+	})
+	return rows
+}
+
+// FindByID fetches a single entity by its primary key.
+func (r *MemoryRepository[T, K]) FindByID(ctx context.Context, key K, _ []string, _ ...CallOption) (T, bool, error) {
+	var entity T
+	if err := ctx.Err(); err != nil {
+		return entity, false, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	row, ok := r.rows[rowID(r.keys.values(key))]
+	if !ok {
+		return entity, false, nil
+	}
+	return row.entity, true, nil
+}
+
+// FindAll retrieves all entities in primary key order.
+func (r *MemoryRepository[T, K]) FindAll(ctx context.Context, _ []string, _ ...CallOption) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var results []T
+	for _, row := range r.sortedRows() {
+		results = append(results, row.entity)
+	}
+	return results, nil
+}
+
+// FindByIDs fetches the entities matching keys, in primary key order.
+func (r *MemoryRepository[T, K]) FindByIDs(ctx context.Context, keys []K, _ []string, _ ...CallOption) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wanted[rowID(r.keys.values(k))] = true
+	}
+
+	var results []T
+	for _, row := range r.sortedRows() {
+		if wanted[rowID(row.key)] {
+			results = append(results, row.entity)
+		}
+	}
+	return results, nil
+}
+
+// FindPage fetches entities with cursor-based pagination, in primary key order.
+// pageSize and pageToken follow the same rules as SpannerRepository.FindPage.
+func (r *MemoryRepository[T, K]) FindPage(
+	ctx context.Context,
+	pageSize int,
+	pageToken interface{},
+	_ []string,
+	_ ...CallOption,
+) ([]T, interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, nil, err
+	}
+
+	after, err := r.keys.pageTokenValues(pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var results []T
+	var lastKey interface{}
+	for _, row := range r.sortedRows() {
+		if len(results) == pageSize {
+			break
+		}
+		if after != nil && compareKeys(row.key, after) <= 0 {
+			continue
+		}
+		results = append(results, row.entity)
+		lastKey = r.keys.pageToken(r.keyExtractor(row.entity))
+	}
+	return results, lastKey, nil
+}
+
+// Exists checks whether an entity exists by primary key.
+func (r *MemoryRepository[T, K]) Exists(ctx context.Context, key K, _ ...CallOption) (bool, error) {
+	_, found, err := r.FindByID(ctx, key, nil)
+	return found, err
+}
+
+// Save performs an upsert.
+func (r *MemoryRepository[T, K]) Save(ctx context.Context, entity T, _ ...CallOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.writeEntity(entity, false).run()
+}
+
+// Update updates an existing entity.
+func (r *MemoryRepository[T, K]) Update(ctx context.Context, entity T, _ ...CallOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.writeEntity(entity, true).run()
+}
+
+// Delete removes an entity by primary key. Deleting a missing row is not an error.
+func (r *MemoryRepository[T, K]) Delete(ctx context.Context, key K, _ ...CallOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.deleteKey(key).run()
+}
+
+// SaveTx performs an upsert inside a transaction.
+func (r *MemoryRepository[T, K]) SaveTx(tx Transaction, entity T, _ ...CallOption) error {
+	mtx, err := memoryTx(tx)
+	if err != nil {
+		return err
+	}
+	mtx.buffer(r.writeEntity(entity, false))
+	return nil
+}
+
+// UpdateTx updates an entity inside a transaction. A missing row makes the
+// transaction fail at commit time, as it does on Spanner.
+func (r *MemoryRepository[T, K]) UpdateTx(tx Transaction, entity T, _ ...CallOption) error {
+	mtx, err := memoryTx(tx)
+	if err != nil {
+		return err
+	}
+	mtx.buffer(r.writeEntity(entity, true))
+	return nil
+}
+
+// DeleteTx removes an entity inside a transaction.
+func (r *MemoryRepository[T, K]) DeleteTx(tx Transaction, key K, _ ...CallOption) error {
+	mtx, err := memoryTx(tx)
+	if err != nil {
+		return err
+	}
+	mtx.buffer(r.deleteKey(key))
+	return nil
+}
+
+// writeEntity returns a write storing entity. If mustExist is set, the write
+// fails with codes.NotFound when the row does not exist yet.
+func (r *MemoryRepository[T, K]) writeEntity(entity T, mustExist bool) memoryWrite {
+	return memoryWrite{mu: &r.mu, apply: func() (func(), error) {
+		key := r.keys.values(r.keyExtractor(entity))
+		id := rowID(key)
+		previous, existed := r.rows[id]
+		if mustExist && !existed {
+			return nil, status.Errorf(codes.NotFound, "row not found: %s", id)
+		}
+		r.rows[id] = memoryRow[T]{key: key, entity: entity}
+		return r.restore(id, previous, existed), nil
+	}}
+}
+
+// deleteKey returns a write removing the row identified by key.
+func (r *MemoryRepository[T, K]) deleteKey(key K) memoryWrite {
+	return memoryWrite{mu: &r.mu, apply: func() (func(), error) {
+		id := rowID(r.keys.values(key))
+		previous, existed := r.rows[id]
+		delete(r.rows, id)
+		return r.restore(id, previous, existed), nil
+	}}
+}
+
+// restore returns a function putting row id back into its previous state.
+// The caller must hold r.mu.
+func (r *MemoryRepository[T, K]) restore(id string, previous memoryRow[T], existed bool) func() {
+	return func() {
+		if existed {
+			r.rows[id] = previous
+		} else {
+			delete(r.rows, id)
+		}
+	}
+}
+
+// compareKeys compares two primary keys column by column.
+func compareKeys(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareValues orders two key values of the same Go type the way Spanner
+// orders the corresponding column type. Numeric, string and bool values are
+// compared by kind, so named types such as `type UserID string` and every
+// integer and float width are handled; other types fall back to comparing
+// their string representation.
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	case civil.Date:
+		if bv, ok := b.(civil.Date); ok {
+			return av.Compare(bv)
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv)
+		}
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsValid() && vb.IsValid() && va.Kind() == vb.Kind() {
+		switch va.Kind() {
+		case reflect.String:
+			return compareOrdered(va.String(), vb.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareOrdered(va.Int(), vb.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return compareOrdered(va.Uint(), vb.Uint())
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(va.Float(), vb.Float())
+		case reflect.Bool:
+			return compareOrdered(boolToInt(va.Bool()), boolToInt(vb.Bool()))
+		}
+	}
+	return compareOrdered(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// compareOrdered compares two ordered values.
+func compareOrdered[V int | int64 | uint64 | float64 | string](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// boolToInt orders false before true.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package repokit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+type userID string
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		a, b interface{}
+		want int
+	}{
+		{int64(9), int64(10), -1},
+		{int32(9), int32(10), -1},
+		{int8(-1), int8(1), -1},
+		{uint16(10), uint16(9), 1},
+		{float32(1.5), float32(10), -1},
+		{userID("b"), userID("a"), 1},
+		{"x", "x", 0},
+		{false, true, -1},
+		{now, now.Add(time.Second), -1},
+		{civil.Date{Year: 2024, Month: 12, Day: 31}, civil.Date{Year: 2025, Month: 1, Day: 1}, -1},
+		{[]byte{1, 2}, []byte{1}, 1},
+	} {
+		if got := compareValues(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareValues(%v, %v) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestMemoryCommitIsAtomic(t *testing.T) {
+	type row struct{ ID int64 }
+	repo, err := NewMemoryRepository[row, int64]([]string{"id"}, func(r row) int64 { return r.ID })
+	if err != nil {
+		t.Fatalf("NewMemoryRepository: %v", err)
+	}
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			rows, err := repo.FindAll(ctx, nil)
+			if err != nil {
+				t.Errorf("FindAll: %v", err)
+				return
+			}
+			if len(rows) != 0 && len(rows) != 3 {
+				t.Errorf("FindAll saw %d rows, want 0 or 3", len(rows))
+				return
+			}
+		}
+	}()
+
+	// Transactions alternately insert and delete the same three rows.
+	tm := NewMemoryTransactionManager()
+	for i := 0; i < 2000; i++ {
+		err := tm.RunInTransaction(ctx, func(tx Transaction) error {
+			for id := int64(0); id < 3; id++ {
+				var err error
+				if i%2 == 0 {
+					err = repo.SaveTx(tx, row{ID: id})
+				} else {
+					err = repo.DeleteTx(tx, id)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunInTransaction: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,111 @@
+package repokit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+// MemoryTransaction is the Transaction handed to callbacks by a
+// MemoryTransactionManager. Writes made through MemoryRepository *Tx methods
+// are buffered in it and applied atomically on commit.
+type MemoryTransaction struct {
+	ctx    context.Context
+	writes []memoryWrite
+}
+
+// Context returns the context associated with this transaction.
+func (t *MemoryTransaction) Context() context.Context {
+	return t.ctx
+}
+
+// buffer queues a write to be applied on commit.
+func (t *MemoryTransaction) buffer(w memoryWrite) {
+	t.writes = append(t.writes, w)
+}
+
+// memoryCommits serializes commits, so that the repository locks a commit
+// takes are never acquired in conflicting orders.
+var memoryCommits sync.Mutex
+
+// commit applies the buffered writes in order, holding the lock of every
+// repository they touch for the whole batch, so readers see either none or
+// all of them. If one of them fails, the writes already applied are reverted
+// and the error is returned.
+func (t *MemoryTransaction) commit() error {
+	memoryCommits.Lock()
+	defer memoryCommits.Unlock()
+
+	locked := make(map[*sync.RWMutex]bool)
+	for _, w := range t.writes {
+		if !locked[w.mu] {
+			locked[w.mu] = true
+			w.mu.Lock()
+			defer w.mu.Unlock()
+		}
+	}
+
+	undo := make([]func(), 0, len(t.writes))
+	for _, w := range t.writes {
+		u, err := w.apply()
+		if err != nil {
+			for i := len(undo) - 1; i >= 0; i-- {
+				undo[i]()
+			}
+			return err
+		}
+		undo = append(undo, u)
+	}
+	return nil
+}
+
+// memoryTx asserts that tx was created by a MemoryTransactionManager.
+func memoryTx(tx Transaction) (*MemoryTransaction, error) {
+	mtx, ok := tx.(*MemoryTransaction)
+	if !ok {
+		return nil, fmt.Errorf("invalid transaction type")
+	}
+	return mtx, nil
+}
+
+// MemoryTransactionManager is an in-memory TransactionManager intended for
+// unit tests together with MemoryRepository. Transactions are serialized, so
+// they never conflict with each other.
+type MemoryTransactionManager struct {
+	mu sync.Mutex
+}
+
+var _ TransactionManager = (*MemoryTransactionManager)(nil)
+
+// NewMemoryTransactionManager creates a new in-memory transaction manager.
+func NewMemoryTransactionManager() *MemoryTransactionManager {
+	return &MemoryTransactionManager{}
+}
+
+// RunInTransaction executes fn inside an in-memory transaction. If fn returns
+// an error, the buffered writes are discarded; otherwise they are committed.
+// Like the Spanner client, fn is retried when it returns a codes.Aborted error.
+// Call options are ignored.
+func (m *MemoryTransactionManager) RunInTransaction(ctx context.Context, fn func(tx Transaction) error, _ ...CallOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tx := &MemoryTransaction{ctx: ctx}
+		err := fn(tx)
+		if spanner.ErrCode(err) == codes.Aborted {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return tx.commit()
+	}
+}
@@ -0,0 +1,131 @@
+package repokit
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OperationMetrics describes a finished repository method or transaction.
+type OperationMetrics struct {
+	// Table is the repository's table, or empty for transactions.
+	Table string
+	// Operation is the method name, e.g. "FindByIDs" or "RunInTransaction".
+	Operation string
+	// Duration is the wall-clock time spent in the call.
+	Duration time.Duration
+	// Rows is the number of rows returned by a read.
+	Rows int
+	// Mutations is the number of mutations applied or buffered by a write.
+	Mutations int
+	// Attempts is the number of times a transaction ran, or zero.
+	Attempts int
+	// ErrorClass classifies the error returned by the call, if any.
+	ErrorClass ErrorClass
+}
+
+// Metrics receives the measurements of repository and transaction manager
+// calls. Implementations must be safe for concurrent use. NewOTelMetrics
+// provides an OpenTelemetry implementation; other backends such as a
+// Prometheus registry can be plugged in by implementing this interface.
+type Metrics interface {
+	// RecordOperation is called once per call, after it returned.
+	RecordOperation(ctx context.Context, m OperationMetrics)
+}
+
+// noopMetrics discards every measurement.
+type noopMetrics struct{}
+
+// RecordOperation does nothing.
+func (noopMetrics) RecordOperation(context.Context, OperationMetrics) {}
+
+// Metric attribute keys.
+const (
+	attrErrorClass = attribute.Key("repokit.error.class")
+)
+
+// OTelMetrics records repokit measurements with OpenTelemetry instruments:
+//
+//   - repokit.operation.duration: latency histogram in seconds
+//   - repokit.rows.read: rows returned by reads
+//   - repokit.mutations: mutations applied or buffered by writes
+//   - repokit.errors: failed calls, labelled with repokit.error.class
+//   - repokit.transaction.attempts: attempts per transaction
+//
+// Every instrument is labelled with the table and operation.
+type OTelMetrics struct {
+	duration  metric.Float64Histogram
+	rows      metric.Int64Counter
+	mutations metric.Int64Counter
+	errors    metric.Int64Counter
+	attempts  metric.Int64Histogram
+}
+
+var _ Metrics = (*OTelMetrics)(nil)
+
+// NewOTelMetrics creates the repokit instruments on a meter from mp.
+//
+// Example:
+//
+//	metrics, err := repokit.NewOTelMetrics(otel.GetMeterProvider())
+//	repo, err := repokit.NewSpannerRepositoryBuilder[User, UserKey]().
+//	    // ...
+//	    WithMetrics(metrics).
+//	    Build()
+func NewOTelMetrics(mp metric.MeterProvider) (*OTelMetrics, error) {
+	meter := mp.Meter(instrumentationName)
+	m := &OTelMetrics{}
+	var err error
+
+	if m.duration, err = meter.Float64Histogram("repokit.operation.duration",
+		metric.WithDescription("Duration of repository operations and transactions."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if m.rows, err = meter.Int64Counter("repokit.rows.read",
+		metric.WithDescription("Rows returned by repository reads."),
+		metric.WithUnit("{row}")); err != nil {
+		return nil, err
+	}
+	if m.mutations, err = meter.Int64Counter("repokit.mutations",
+		metric.WithDescription("Mutations applied or buffered by repository writes."),
+		metric.WithUnit("{mutation}")); err != nil {
+		return nil, err
+	}
+	if m.errors, err = meter.Int64Counter("repokit.errors",
+		metric.WithDescription("Failed repository operations and transactions, by error class."),
+		metric.WithUnit("{error}")); err != nil {
+		return nil, err
+	}
+	if m.attempts, err = meter.Int64Histogram("repokit.transaction.attempts",
+		metric.WithDescription("Attempts needed to run a transaction, including retries after aborts."),
+		metric.WithUnit("{attempt}")); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RecordOperation records m on the OpenTelemetry instruments.
+func (o *OTelMetrics) RecordOperation(ctx context.Context, m OperationMetrics) {
+	attrs := []attribute.KeyValue{attrOperation.String(m.Operation)}
+	if m.Table != "" {
+		attrs = append(attrs, attrTable.String(m.Table))
+	}
+	set := metric.WithAttributes(attrs...)
+
+	o.duration.Record(ctx, m.Duration.Seconds(), set)
+	if m.Rows > 0 {
+		o.rows.Add(ctx, int64(m.Rows), set)
+	}
+	if m.Mutations > 0 {
+		o.mutations.Add(ctx, int64(m.Mutations), set)
+	}
+	if m.Attempts > 0 {
+		o.attempts.Record(ctx, int64(m.Attempts), set)
+	}
+	if m.ErrorClass != ErrorClassNone {
+		o.errors.Add(ctx, 1, metric.WithAttributes(append(attrs, attrErrorClass.String(string(m.ErrorClass)))...))
+	}
+}
@@ -0,0 +1,695 @@
+package repokit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrMigrationLocked is returned by Migrate when another instance holds the
+// migration lock.
+var ErrMigrationLocked = errors.New("migrations are locked by another instance")
+
+// ErrMigrationInterrupted is returned by Migrate when an instance stopped
+// while applying a migration, before recording it in the history table. The
+// migration's statements may have been applied, in part for DDL and
+// Partitioned DML, so it is neither retried nor skipped automatically.
+var ErrMigrationInterrupted = errors.New("a migration was interrupted before it was recorded")
+
+// MigrationKind tells how the statements of a migration are applied.
+type MigrationKind string
+
+// Migration kinds, chosen by the migration file name.
+const (
+	// MigrationDDL statements are sent in one UpdateDatabaseDdl call.
+	MigrationDDL MigrationKind = "ddl"
+	// MigrationDML statements run in one read-write transaction, together
+	// with the history row, so they are applied exactly once.
+	MigrationDML MigrationKind = "dml"
+	// MigrationPartitionedDML statements run one by one as Partitioned DML,
+	// for backfills too large for a transaction. They must be idempotent: a
+	// failed migration is run again from its first statement.
+	MigrationPartitionedDML MigrationKind = "pdml"
+)
+
+// Migration is a numbered set of statements.
+type Migration struct {
+	Version    int64
+	Name       string
+	Kind       MigrationKind
+	Statements []string
+	// Checksum identifies the statements. Migrate refuses to run if an
+	// applied migration's checksum has changed.
+	Checksum string
+}
+
+// migrationFile matches migration file names: the version, the name and an
+// optional kind suffix.
+var migrationFile = regexp.MustCompile(`^(\d+)_([^.]+?)(\.dml|\.pdml)?\.sql$`)
+
+// LoadMigrations reads the migrations in the root directory of fsys, sorted
+// by version. Files are named <version>_<name>.sql for DDL,
+// <version>_<name>.dml.sql for DML and <version>_<name>.pdml.sql for
+// Partitioned DML, e.g. 0003_backfill_status.pdml.sql. Statements are
+// separated by semicolons; other files are ignored.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	var migrations []Migration
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", e.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+		kind := MigrationDDL
+		if m[3] != "" {
+			kind = MigrationKind(strings.TrimPrefix(m[3], "."))
+		}
+		migration, err := NewMigration(version, m[2], kind, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version %d",
+				migrations[i-1].Name, migrations[i].Name, migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// NewMigration builds a migration from SQL text holding statements separated
+// by semicolons.
+func NewMigration(version int64, name string, kind MigrationKind, sql string) (Migration, error) {
+	switch kind {
+	case MigrationDDL, MigrationDML, MigrationPartitionedDML:
+	default:
+		return Migration{}, fmt.Errorf("unknown migration kind %q", kind)
+	}
+	if version <= 0 {
+		return Migration{}, fmt.Errorf("migration version must be positive, got %d", version)
+	}
+	statements := splitStatements(sql)
+	if len(statements) == 0 {
+		return Migration{}, fmt.Errorf("migration %d_%s has no statements", version, name)
+	}
+	sum := sha256.Sum256([]byte(string(kind) + "\n" + strings.Join(statements, ";\n")))
+	return Migration{
+		Version:    version,
+		Name:       name,
+		Kind:       kind,
+		Statements: statements,
+		Checksum:   hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// splitStatements splits sql on the semicolons outside of quotes and
+// comments. Comments are kept in the statements and blank statements are
+// dropped.
+func splitStatements(sql string) []string {
+	var (
+		statements []string
+		start      int
+	)
+	add := func(s string) {
+		if stripComments(s) != "" {
+			statements = append(statements, strings.TrimSpace(s))
+		}
+	}
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' {
+					i++
+				}
+			}
+		case c == '#' || c == '-' && strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+		case c == ';':
+			add(sql[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(sql) {
+		add(sql[start:])
+	}
+	return statements
+}
+
+// stripComments returns s without line comments and surrounding space. It is
+// only used to detect statements holding nothing but comments.
+func stripComments(s string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "--") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.WriteString(line)
+	}
+	out := b.String()
+	for {
+		i := strings.Index(out, "/*")
+		j := strings.Index(out, "*/")
+		if i < 0 || j < i {
+			return strings.TrimSpace(out)
+		}
+		out = out[:i] + out[j+2:]
+	}
+}
+
+// DDLApplier applies DDL statements to a database. NewAdminDDLApplier
+// returns one for Cloud Spanner and the emulator; tests on spannertest can
+// use a DDLApplierFunc calling spannertest.Server.UpdateDDL.
+type DDLApplier interface {
+	// ApplyDDL applies statements as one batch and waits for completion.
+	ApplyDDL(ctx context.Context, statements []string) error
+}
+
+// DDLApplierFunc adapts a function to the DDLApplier interface.
+type DDLApplierFunc func(ctx context.Context, statements []string) error
+
+// ApplyDDL calls f(ctx, statements).
+func (f DDLApplierFunc) ApplyDDL(ctx context.Context, statements []string) error {
+	return f(ctx, statements)
+}
+
+// NewAdminDDLApplier returns a DDLApplier sending statements to db, a
+// "projects/P/instances/I/databases/D" path, with UpdateDatabaseDdl.
+func NewAdminDDLApplier(admin *database.DatabaseAdminClient, db string) DDLApplier {
+	return DDLApplierFunc(func(ctx context.Context, statements []string) error {
+		op, err := admin.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database:   db,
+			Statements: statements,
+		})
+		if err != nil {
+			return err
+		}
+		return op.Wait(ctx)
+	})
+}
+
+// MigrationSchema returns the DDL statements creating the migration history
+// table and its lock table, named <table>Lock. Migrate creates them when
+// they are missing.
+func MigrationSchema(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE %s (
+	version INT64 NOT NULL,
+	name STRING(MAX) NOT NULL,
+	kind STRING(8) NOT NULL,
+	checksum STRING(64) NOT NULL,
+	applied_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (version)`, table),
+		fmt.Sprintf(`CREATE TABLE %sLock (
+	id INT64 NOT NULL,
+	owner STRING(MAX) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	migrating INT64,
+) PRIMARY KEY (id)`, table),
+	}
+}
+
+// Migrator applies pending migrations in version order and records them in
+// a history table. A lock row keeps concurrent instances from migrating at
+// the same time.
+type Migrator struct {
+	client     *spanner.Client
+	ddl        DDLApplier
+	migrations []Migration
+	table      string
+	lockTable  string
+	lockTTL    time.Duration
+	owner      string
+	dryRun     io.Writer // nil unless WithDryRun is set
+	// partitionedUpdate runs a Partitioned DML statement; replaced in tests
+	// since spannertest does not support Partitioned DML.
+	partitionedUpdate func(ctx context.Context, stmt spanner.Statement) (int64, error)
+}
+
+// MigratorOption configures a Migrator.
+type MigratorOption func(*Migrator)
+
+// WithMigrationTable sets the history table. The default is SchemaMigrations.
+func WithMigrationTable(table string) MigratorOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithMigrationLockTTL sets how long the lock is held without being renewed.
+// The lock is renewed every third of the TTL while a migration runs, and the
+// migration is canceled if the lock cannot be renewed before it expires. The
+// TTL must be positive; the default is 30 minutes.
+func WithMigrationLockTTL(ttl time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		m.lockTTL = ttl
+	}
+}
+
+// WithDryRun makes Migrate write the pending migrations to w instead of
+// applying them. The database is only read.
+func WithDryRun(w io.Writer) MigratorOption {
+	return func(m *Migrator) {
+		m.dryRun = w
+	}
+}
+
+// NewMigrator creates a Migrator applying migrations through client and ddl.
+func NewMigrator(client *spanner.Client, ddl DDLApplier, migrations []Migration, opts ...MigratorOption) (*Migrator, error) {
+	if client == nil {
+		return nil, fmt.Errorf("spanner client is required")
+	}
+	if ddl == nil {
+		return nil, fmt.Errorf("DDL applier is required")
+	}
+	owner, err := newEventID()
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{
+		client:            client,
+		ddl:               ddl,
+		migrations:        append([]Migration(nil), migrations...),
+		table:             "SchemaMigrations",
+		lockTTL:           30 * time.Minute,
+		owner:             owner,
+		partitionedUpdate: client.PartitionedUpdate,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if err := validateIdentifier(m.table); err != nil {
+		return nil, fmt.Errorf("migration table: %w", err)
+	}
+	if m.lockTTL <= 0 {
+		return nil, fmt.Errorf("migration lock TTL must be positive, got %s", m.lockTTL)
+	}
+	m.lockTable = m.table + "Lock"
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return m, nil
+}
+
+// Pending returns the migrations that have not been applied, in order. It
+// fails if an applied migration has been modified or is no longer known.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int64]bool, len(m.migrations))
+	var pending []Migration
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		checksum, ok := applied[mig.Version]
+		switch {
+		case !ok:
+			pending = append(pending, mig)
+		case checksum != mig.Checksum:
+			return nil, fmt.Errorf("migration %d_%s was modified after it was applied", mig.Version, mig.Name)
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("applied migration %d is missing", version)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order and returns the ones it
+// applied. It creates the history and lock tables if needed and returns
+// ErrMigrationLocked if another instance is migrating, or
+// ErrMigrationInterrupted if one stopped or failed during a DDL or
+// Partitioned DML migration. With WithDryRun, it only writes the pending
+// migrations and returns them.
+func (m *Migrator) Migrate(ctx context.Context) ([]Migration, error) {
+	if m.dryRun != nil {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return pending, m.writePlan(pending)
+	}
+
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	// The marker of an interrupted migration is kept until it is resolved.
+	keepMarker := true
+	defer func() { m.unlock(context.WithoutCancel(ctx), keepMarker) }()
+
+	if err := m.checkInterrupted(ctx); err != nil {
+		return nil, err
+	}
+	keepMarker = false
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range pending {
+		if err := m.markMigrating(ctx, mig.Version); err != nil {
+			return done, err
+		}
+		applyCtx, stop := m.keepLock(ctx)
+		err := m.apply(applyCtx, mig)
+		if lost := stop(); lost != nil {
+			err = lost
+		}
+		if err != nil {
+			// A DML migration commits with its history row or not at all,
+			// but DDL and Partitioned DML may be partly applied.
+			keepMarker = mig.Kind != MigrationDML
+			return done, fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// keepLock renews the migration lock every third of its TTL until stop is
+// called, so that a migration may outlast the TTL. If another instance takes
+// the lock, or it cannot be renewed before it expires, the returned context
+// is canceled and stop returns the reason.
+func (m *Migrator) keepLock(ctx context.Context) (context.Context, func() error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		wg   sync.WaitGroup
+		lost error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(max(m.lockTTL/3, time.Nanosecond))
+		defer ticker.Stop()
+		expires := time.Now().Add(m.lockTTL)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			renewed := time.Now()
+			err := m.lock(ctx)
+			switch {
+			case err == nil:
+				expires = renewed.Add(m.lockTTL)
+			case ctx.Err() != nil:
+				return
+			case errors.Is(err, ErrMigrationLocked):
+				lost = fmt.Errorf("migration lock lost: %w", err)
+			case !time.Now().Before(expires):
+				lost = fmt.Errorf("migration lock expired: %w", err)
+			default:
+				continue // retried at the next tick
+			}
+			if lost != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+	return ctx, func() error {
+		cancel()
+		wg.Wait()
+		return lost
+	}
+}
+
+// writePlan writes the statements of pending to the dry-run writer.
+func (m *Migrator) writePlan(pending []Migration) error {
+	if len(pending) == 0 {
+		_, err := fmt.Fprintln(m.dryRun, "-- no pending migrations")
+		return err
+	}
+	for _, mig := range pending {
+		if _, err := fmt.Fprintf(m.dryRun, "-- %d_%s (%s)\n", mig.Version, mig.Name, mig.Kind); err != nil {
+			return err
+		}
+		for _, stmt := range mig.Statements {
+			if _, err := fmt.Fprintf(m.dryRun, "%s;\n", stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply runs the statements of mig and records it in the history table.
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	record := spanner.Insert(m.table,
+		[]string{"version", "name", "kind", "checksum", "applied_at"},
+		[]interface{}{mig.Version, mig.Name, string(mig.Kind), mig.Checksum, spanner.CommitTimestamp})
+
+	switch mig.Kind {
+	case MigrationDDL:
+		if err := m.ddl.ApplyDDL(ctx, mig.Statements); err != nil {
+			return err
+		}
+	case MigrationDML:
+		_, err := m.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			for _, s := range mig.Statements {
+				if _, err := tx.Update(ctx, spanner.NewStatement(s)); err != nil {
+					return err
+				}
+			}
+			return tx.BufferWrite([]*spanner.Mutation{record})
+		})
+		return err
+	case MigrationPartitionedDML:
+		for _, s := range mig.Statements {
+			if _, err := m.partitionedUpdate(ctx, spanner.NewStatement(s)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := m.client.Apply(ctx, []*spanner.Mutation{record})
+	return err
+}
+
+// applied returns the checksums of the applied migrations by version. It
+// returns none if the history table does not exist yet.
+func (m *Migrator) applied(ctx context.Context) (map[int64]string, error) {
+	applied := map[int64]string{}
+	stmt := spanner.NewStatement(fmt.Sprintf("SELECT version, checksum FROM %s", m.table))
+	err := m.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var (
+			version  int64
+			checksum string
+		)
+		if err := row.Columns(&version, &checksum); err != nil {
+			return err
+		}
+		applied[version] = checksum
+		return nil
+	})
+	if isTableNotFound(err) {
+		return applied, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read migration history %s: %w", m.table, err)
+	}
+	return applied, nil
+}
+
+// ensureTables creates the history and lock tables that do not exist.
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	schema := MigrationSchema(m.table)
+	for i, table := range []string{m.table, m.lockTable} {
+		exists, err := m.tableExists(ctx, table)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := m.ddl.ApplyDDL(ctx, schema[i:i+1]); err != nil {
+			// Another instance may have created it in the meantime.
+			if exists, _ := m.tableExists(ctx, table); exists {
+				continue
+			}
+			return fmt.Errorf("create table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// tableExists reports whether table can be queried.
+func (m *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	stmt := spanner.NewStatement(fmt.Sprintf("SELECT 1 FROM %s LIMIT 1", table))
+	err := m.client.Single().Query(ctx, stmt).Do(func(*spanner.Row) error { return nil })
+	switch {
+	case err == nil:
+		return true, nil
+	case isTableNotFound(err):
+		return false, nil
+	default:
+		return false, fmt.Errorf("read table %s: %w", table, err)
+	}
+}
+
+// isTableNotFound reports whether err is the error of a query on a missing
+// table. Cloud Spanner reports it as an invalid argument, spannertest as not
+// found.
+func isTableNotFound(err error) bool {
+	switch spanner.ErrCode(err) {
+	case codes.NotFound:
+		return true
+	case codes.InvalidArgument:
+		return strings.Contains(err.Error(), "Table not found")
+	}
+	return false
+}
+
+// lock acquires or renews the migration lock.
+func (m *Migrator) lock(ctx context.Context) error {
+	return m.writeLock(ctx, nil)
+}
+
+// markMigrating renews the migration lock and records in it that version is
+// being applied. The lock row is deleted once the migration is recorded or
+// known not to have run, so a marker found by the next Migrate means an
+// instance stopped or failed during the migration.
+func (m *Migrator) markMigrating(ctx context.Context, version int64) error {
+	return m.writeLock(ctx, &version)
+}
+
+// checkInterrupted returns an ErrMigrationInterrupted error if the lock row
+// records a migration that is missing from the history table.
+func (m *Migrator) checkInterrupted(ctx context.Context) error {
+	row, err := m.client.Single().ReadRow(ctx, m.lockTable, spanner.Key{1}, []string{"migrating"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read migration lock: %w", err)
+	}
+	var version spanner.NullInt64
+	if err := row.Columns(&version); err != nil {
+		return err
+	}
+	if !version.Valid {
+		return nil
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := applied[version.Int64]; ok {
+		return nil
+	}
+	return fmt.Errorf("%w: migration %d may be partly applied; check the database, record or revert it, then set %s.migrating to NULL",
+		ErrMigrationInterrupted, version.Int64, m.lockTable)
+}
+
+// serverTime returns the current time of the database: the commit timestamp
+// of an empty read-write transaction. Lock expiry is measured with it rather
+// than with the local clock, which may be skewed from other instances'.
+func (m *Migrator) serverTime(ctx context.Context) (time.Time, error) {
+	return m.client.ReadWriteTransaction(ctx, func(context.Context, *spanner.ReadWriteTransaction) error {
+		return nil
+	})
+}
+
+// writeLock acquires or renews the migration lock. If migrating is not nil,
+// it also records that this migration version is being applied; otherwise
+// the recorded version is kept.
+func (m *Migrator) writeLock(ctx context.Context, migrating *int64) error {
+	// The lock is taken after now, so a lock expired at now is expired then.
+	now, err := m.serverTime(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	_, err = m.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		row, err := tx.ReadRow(ctx, m.lockTable, spanner.Key{1}, []string{"owner", "expires_at"})
+		if err != nil && spanner.ErrCode(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var (
+				owner   string
+				expires time.Time
+			)
+			if err := row.Columns(&owner, &expires); err != nil {
+				return err
+			}
+			if owner != m.owner && now.Before(expires) {
+				return fmt.Errorf("%w until %s", ErrMigrationLocked, expires.Format(time.RFC3339))
+			}
+		}
+		columns := []string{"id", "owner", "expires_at"}
+		values := []interface{}{int64(1), m.owner, now.Add(m.lockTTL)}
+		if migrating != nil {
+			columns = append(columns, "migrating")
+			values = append(values, *migrating)
+		}
+		return tx.BufferWrite([]*spanner.Mutation{spanner.InsertOrUpdate(m.lockTable, columns, values)})
+	})
+	if err != nil && !errors.Is(err, ErrMigrationLocked) {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	return err
+}
+
+// unlock releases the migration lock if it is still held by m. It deletes the
+// lock row, or, if keepMarker is set, expires it and keeps the migration
+// recorded in it for checkInterrupted.
+func (m *Migrator) unlock(ctx context.Context, keepMarker bool) {
+	_, _ = m.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		row, err := tx.ReadRow(ctx, m.lockTable, spanner.Key{1}, []string{"owner"})
+		if err != nil {
+			return err
+		}
+		var owner string
+		if err := row.Columns(&owner); err != nil || owner != m.owner {
+			return err
+		}
+		if keepMarker {
+			return tx.BufferWrite([]*spanner.Mutation{spanner.Update(m.lockTable,
+				[]string{"id", "expires_at"}, []interface{}{int64(1), time.Unix(0, 0).UTC()})})
+		}
+		return tx.BufferWrite([]*spanner.Mutation{spanner.Delete(m.lockTable, spanner.Key{1})})
+	})
+}
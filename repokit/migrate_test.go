@@ -0,0 +1,292 @@
+package repokit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/spannertest"
+	"cloud.google.com/go/spanner/spansql"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- leading comment
+CREATE TABLE A (s STRING(MAX)) PRIMARY KEY (s);
+/* a; block */ UPDATE A SET s = 'x;y' WHERE s = "a\"; b";
+# trailing comment only;
+`
+	got := splitStatements(sql)
+	want := []string{
+		"-- leading comment\nCREATE TABLE A (s STRING(MAX)) PRIMARY KEY (s)",
+		`/* a; block */ UPDATE A SET s = 'x;y' WHERE s = "a\"; b"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitStatements = %q, want %q", got, want)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_backfill.pdml.sql": {Data: []byte("UPDATE A SET n = 0 WHERE n IS NULL")},
+		"0001_create.sql":        {Data: []byte("CREATE TABLE A (id INT64) PRIMARY KEY (id);")},
+		"0003_seed.dml.sql":      {Data: []byte("INSERT INTO A (id) VALUES (1); INSERT INTO A (id) VALUES (2)")},
+		"README.md":              {Data: []byte("not a migration")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	var got []string
+	for _, m := range migrations {
+		got = append(got, strings.Join([]string{m.Name, string(m.Kind)}, ":"))
+	}
+	if want := []string{"create:ddl", "backfill:pdml", "seed:dml"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("migrations = %v, want %v", got, want)
+	}
+	if len(migrations[2].Statements) != 2 || migrations[0].Checksum == "" {
+		t.Fatalf("seed migration = %+v", migrations[2])
+	}
+
+	fsys["01_other.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE A")}
+	if _, err := LoadMigrations(fsys); err == nil {
+		t.Fatal("LoadMigrations accepted two migrations with version 1")
+	}
+}
+
+// newMigrationTestClient returns a client on an empty spannertest database
+// and a DDLApplier for it.
+func newMigrationTestClient(t *testing.T) (*spanner.Client, DDLApplier) {
+	t.Helper()
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("start spannertest server: %v", err)
+	}
+	srv.SetLogger(func(string, ...interface{}) {})
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial spannertest server: %v", err)
+	}
+	client, err := spanner.NewClientWithConfig(context.Background(), "projects/p/instances/i/databases/d",
+		spanner.ClientConfig{DisableNativeMetrics: true}, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("create spanner client: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	applier := DDLApplierFunc(func(_ context.Context, statements []string) error {
+		ddl, err := spansql.ParseDDL("migration", strings.Join(statements, ";\n"))
+		if err != nil {
+			return err
+		}
+		return srv.UpdateDDL(ddl)
+	})
+	return client, applier
+}
+
+func mustMigration(t *testing.T, version int64, name string, kind MigrationKind, sql string) Migration {
+	t.Helper()
+	m, err := NewMigration(version, name, kind, sql)
+	if err != nil {
+		t.Fatalf("NewMigration: %v", err)
+	}
+	return m
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	client, applier := newMigrationTestClient(t)
+	migrations := []Migration{
+		mustMigration(t, 1, "create", MigrationDDL, "CREATE TABLE Items (id INT64 NOT NULL, name STRING(MAX), n INT64) PRIMARY KEY (id)"),
+		mustMigration(t, 2, "seed", MigrationDML, "INSERT INTO Items (id, name) VALUES (1, 'a'); INSERT INTO Items (id, name) VALUES (2, 'b')"),
+		mustMigration(t, 3, "backfill", MigrationPartitionedDML, "UPDATE Items SET n = 0 WHERE n IS NULL"),
+	}
+	newMigrator := func(migrations []Migration, opts ...MigratorOption) *Migrator {
+		m, err := NewMigrator(client, applier, migrations, opts...)
+		if err != nil {
+			t.Fatalf("NewMigrator: %v", err)
+		}
+		// spannertest has no Partitioned DML.
+		m.partitionedUpdate = func(ctx context.Context, stmt spanner.Statement) (int64, error) {
+			var n int64
+			_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+				var err error
+				n, err = tx.Update(ctx, stmt)
+				return err
+			})
+			return n, err
+		}
+		return m
+	}
+
+	var plan bytes.Buffer
+	pending, err := newMigrator(migrations, WithDryRun(&plan)).Migrate(ctx)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(pending) != 3 || !strings.Contains(plan.String(), "-- 2_seed (dml)\nINSERT INTO Items") {
+		t.Fatalf("dry run planned %d migrations:\n%s", len(pending), plan.String())
+	}
+
+	applied, err := newMigrator(migrations[:2]).Migrate(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Migrate = %d migrations, %v; want 2", len(applied), err)
+	}
+	applied, err = newMigrator(migrations).Migrate(ctx)
+	if err != nil || len(applied) != 1 || applied[0].Version != 3 {
+		t.Fatalf("second Migrate = %+v, %v; want only the backfill", applied, err)
+	}
+
+	var nulls int64
+	row, err := client.Single().Query(ctx, spanner.NewStatement("SELECT COUNT(*) FROM Items WHERE n IS NULL")).Next()
+	if err != nil || row.Columns(&nulls) != nil || nulls != 0 {
+		t.Fatalf("rows left to backfill = %d, %v", nulls, err)
+	}
+	if pending, err := newMigrator(migrations).Pending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("Pending after Migrate = %v, %v", pending, err)
+	}
+
+	edited := append([]Migration(nil), migrations...)
+	edited[1] = mustMigration(t, 2, "seed", MigrationDML, "INSERT INTO Items (id, name) VALUES (3, 'c')")
+	if _, err := newMigrator(edited).Migrate(ctx); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Fatalf("Migrate with an edited migration = %v, want a checksum error", err)
+	}
+
+	// The lock keeps a second instance out until it expires.
+	holder := newMigrator(migrations, WithMigrationLockTTL(time.Hour))
+	if err := holder.lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, err := newMigrator(migrations).Migrate(ctx); !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("Migrate while locked = %v, want ErrMigrationLocked", err)
+	}
+	holder.unlock(ctx, false)
+	if _, err := newMigrator(migrations).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate after unlock: %v", err)
+	}
+}
+
+func TestMigratorLockRenewal(t *testing.T) {
+	ctx := context.Background()
+	client, applier := newMigrationTestClient(t)
+	// Migrations creating a table whose name starts with Slow run for longer
+	// than the lock TTL; steal, if set, runs while they do.
+	var steal func()
+	slow := DDLApplierFunc(func(ctx context.Context, statements []string) error {
+		if strings.Contains(statements[0], "TABLE Slow") {
+			time.Sleep(150 * time.Millisecond)
+			if steal != nil {
+				steal()
+			}
+			select {
+			case <-time.After(150 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return applier.ApplyDDL(ctx, statements)
+	})
+	newMigrator := func(migrations []Migration) *Migrator {
+		m, err := NewMigrator(client, slow, migrations, WithMigrationLockTTL(60*time.Millisecond))
+		if err != nil {
+			t.Fatalf("NewMigrator: %v", err)
+		}
+		return m
+	}
+	migrations := []Migration{
+		mustMigration(t, 1, "slow", MigrationDDL, "CREATE TABLE SlowItems (id INT64 NOT NULL) PRIMARY KEY (id)"),
+		mustMigration(t, 2, "slower", MigrationDDL, "CREATE TABLE SlowOrders (id INT64 NOT NULL) PRIMARY KEY (id)"),
+	}
+
+	// The lock is renewed while the migration outlasts its TTL.
+	steal = func() {
+		if _, err := newMigrator(nil).Migrate(ctx); !errors.Is(err, ErrMigrationLocked) {
+			t.Errorf("Migrate during a long migration = %v, want ErrMigrationLocked", err)
+		}
+	}
+	if applied, err := newMigrator(migrations[:1]).Migrate(ctx); err != nil || len(applied) != 1 {
+		t.Fatalf("Migrate = %d migrations, %v; want 1", len(applied), err)
+	}
+
+	// A migration whose lock is taken over is canceled and not recorded.
+	steal = func() {
+		_, err := client.Apply(ctx, []*spanner.Mutation{spanner.Update("SchemaMigrationsLock",
+			[]string{"id", "owner", "expires_at"}, []interface{}{int64(1), "intruder", time.Now().Add(time.Hour)})})
+		if err != nil {
+			t.Errorf("steal lock: %v", err)
+		}
+	}
+	if _, err := newMigrator(migrations).Migrate(ctx); !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("Migrate after losing the lock = %v, want ErrMigrationLocked", err)
+	}
+
+	// The next instance finds the interrupted migration once the lock expires.
+	steal = nil
+	if _, err := client.Apply(ctx, []*spanner.Mutation{spanner.Update("SchemaMigrationsLock",
+		[]string{"id", "expires_at"}, []interface{}{int64(1), time.Now().Add(-time.Second)})}); err != nil {
+		t.Fatalf("expire lock: %v", err)
+	}
+	if _, err := newMigrator(migrations).Migrate(ctx); !errors.Is(err, ErrMigrationInterrupted) {
+		t.Fatalf("Migrate after an interrupted migration = %v, want ErrMigrationInterrupted", err)
+	}
+	if _, err := client.Apply(ctx, []*spanner.Mutation{spanner.Delete("SchemaMigrationsLock", spanner.Key{1})}); err != nil {
+		t.Fatalf("clear lock: %v", err)
+	}
+	if applied, err := newMigrator(migrations).Migrate(ctx); err != nil || len(applied) != 1 || applied[0].Version != 2 {
+		t.Fatalf("Migrate after clearing the lock = %+v, %v; want migration 2", applied, err)
+	}
+}
+
+func TestMigratorKeepsMarkerOnFailure(t *testing.T) {
+	ctx := context.Background()
+	client, applier := newMigrationTestClient(t)
+	if _, err := NewMigrator(client, applier, nil, WithMigrationLockTTL(0)); err == nil {
+		t.Fatal("NewMigrator accepted a zero lock TTL")
+	}
+
+	// The DDL applier fails on the second statement, after applying the first.
+	failing := DDLApplierFunc(func(ctx context.Context, statements []string) error {
+		if len(statements) > 1 {
+			if err := applier.ApplyDDL(ctx, statements[:1]); err != nil {
+				return err
+			}
+			return errors.New("second statement failed")
+		}
+		return applier.ApplyDDL(ctx, statements)
+	})
+	newMigrator := func(ddl DDLApplier, migrations []Migration) *Migrator {
+		m, err := NewMigrator(client, ddl, migrations)
+		if err != nil {
+			t.Fatalf("NewMigrator: %v", err)
+		}
+		return m
+	}
+	dml := mustMigration(t, 1, "seed", MigrationDML, "INSERT INTO Missing (id) VALUES (1)")
+	ddl := mustMigration(t, 1, "create", MigrationDDL,
+		"CREATE TABLE A (id INT64 NOT NULL) PRIMARY KEY (id); CREATE TABLE B (id INT64 NOT NULL) PRIMARY KEY (id)")
+
+	// A failed DML migration applied nothing, so it can be fixed and run.
+	if _, err := newMigrator(applier, []Migration{dml}).Migrate(ctx); err == nil {
+		t.Fatal("Migrate of a failing DML migration succeeded")
+	}
+	if _, err := newMigrator(failing, []Migration{ddl}).Migrate(ctx); err == nil || errors.Is(err, ErrMigrationInterrupted) {
+		t.Fatalf("Migrate of a failing DDL migration = %v, want its error", err)
+	}
+	// A failed DDL migration may be partly applied, so it is not run again.
+	if _, err := newMigrator(applier, []Migration{ddl}).Migrate(ctx); !errors.Is(err, ErrMigrationInterrupted) {
+		t.Fatalf("Migrate after a failed DDL migration = %v, want ErrMigrationInterrupted", err)
+	}
+	if _, err := newMigrator(applier, []Migration{ddl}).Migrate(ctx); !errors.Is(err, ErrMigrationInterrupted) {
+		t.Fatalf("second Migrate after a failed DDL migration = %v, want ErrMigrationInterrupted", err)
+	}
+}
@@ -0,0 +1,180 @@
+package repokit
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName identifies repokit to tracer and meter providers.
+const instrumentationName = "github.com/Waelson/go-spanner-repo/repokit"
+
+// Attribute keys set on repokit spans.
+const (
+	attrDBSystem  = attribute.Key("db.system")
+	attrTable     = attribute.Key("db.collection.name")
+	attrOperation = attribute.Key("db.operation.name")
+	attrKeys      = attribute.Key("repokit.keys")
+	attrRows      = attribute.Key("repokit.rows")
+	attrMutations = attribute.Key("repokit.mutations")
+	attrAttempts  = attribute.Key("repokit.transaction.attempts")
+	attrRetries   = attribute.Key("repokit.retries")
+	attrCacheHit  = attribute.Key("repokit.cache.hit")
+	attrBatched   = attribute.Key("repokit.batched")
+)
+
+// newTracer returns repokit's tracer from tp, or a no-op tracer if tp is nil.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+// instruments bundles the observability hooks shared by repositories and
+// transaction managers.
+type instruments struct {
+	tracer  trace.Tracer
+	metrics Metrics
+	queries *queryLogger // nil when query logging is disabled
+}
+
+// newInstruments returns instruments with no-op defaults.
+func newInstruments() instruments {
+	return instruments{tracer: newTracer(nil), metrics: noopMetrics{}}
+}
+
+// operation instruments a single repository or transaction manager call.
+// It is started with startOperation and must be finished with end.
+type operation struct {
+	ctx       context.Context
+	inst      instruments
+	table     string
+	name      string
+	start     time.Time
+	span      trace.Span
+	rows      int
+	mutations int
+	attempts  int
+	stmt      *spanner.Statement
+	opts      callOptions
+	retries   int
+	cancel    context.CancelFunc // releases the call's timeout, if any
+}
+
+// startOperation opens a span named "<name> <table>" (or just name when table
+// is empty) and returns the context carrying it. The call's options are
+// resolved from opts over base; when they set a timeout, the returned context
+// carries the deadline.
+func startOperation(
+	ctx context.Context,
+	inst instruments,
+	table, name string,
+	base callOptions,
+	opts []CallOption,
+) (context.Context, *operation) {
+	spanName := name
+	attrs := []attribute.KeyValue{attrDBSystem.String("spanner"), attrOperation.String(name)}
+	if table != "" {
+		spanName = name + " " + table
+		attrs = append(attrs, attrTable.String(table))
+	}
+	ctx, span := inst.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attrs...))
+	op := &operation{
+		inst:  inst,
+		table: table,
+		name:  name,
+		start: time.Now(),
+		span:  span,
+		opts:  resolveCallOptions(table, name, base, opts),
+	}
+	if op.opts.timeout > 0 {
+		ctx, op.cancel = context.WithTimeout(ctx, op.opts.timeout)
+	}
+	op.ctx = ctx
+	return ctx, op
+}
+
+// setKeys records how many primary keys the call addresses.
+func (o *operation) setKeys(n int) {
+	o.span.SetAttributes(attrKeys.Int(n))
+}
+
+// setRows records how many rows the call returned.
+func (o *operation) setRows(n int) {
+	o.rows = n
+	o.span.SetAttributes(attrRows.Int(n))
+}
+
+// setFound records whether a single-row read found its row.
+func (o *operation) setFound(found bool) {
+	if found {
+		o.setRows(1)
+	} else {
+		o.setRows(0)
+	}
+}
+
+// setCacheHit records whether a read was served from the cache.
+func (o *operation) setCacheHit(hit bool) {
+	o.span.SetAttributes(attrCacheHit.Bool(hit))
+}
+
+// setBatched records that a read was coalesced into a batch.
+func (o *operation) setBatched() {
+	o.span.SetAttributes(attrBatched.Bool(true))
+}
+
+// setMutations records how many mutations the call buffered or applied.
+func (o *operation) setMutations(n int) {
+	o.mutations = n
+	o.span.SetAttributes(attrMutations.Int(n))
+}
+
+// setStatement records the SQL statement run by the call, for the query log.
+func (o *operation) setStatement(stmt spanner.Statement) {
+	o.stmt = &stmt
+}
+
+// attempt records the start of a transaction attempt.
+func (o *operation) attempt() {
+	o.attempts++
+}
+
+// end records err, if any, finishes the span, reports the call's metrics and
+// logs its statement.
+func (o *operation) end(err error) {
+	d := time.Since(o.start)
+	if o.inst.queries != nil && o.stmt != nil {
+		o.inst.queries.log(o.ctx, o.table, o.name, *o.stmt, d, o.rows, err)
+	}
+	o.inst.metrics.RecordOperation(o.ctx, OperationMetrics{
+		Table:      o.table,
+		Operation:  o.name,
+		Duration:   d,
+		Rows:       o.rows,
+		Mutations:  o.mutations,
+		Attempts:   o.attempts,
+		ErrorClass: ClassifyError(err),
+	})
+
+	if o.attempts > 0 {
+		o.span.SetAttributes(attrAttempts.Int(o.attempts))
+	}
+	if o.retries > 0 {
+		o.span.SetAttributes(attrRetries.Int(o.retries))
+	}
+	if err != nil {
+		o.span.RecordError(err)
+		o.span.SetStatus(otelcodes.Error, err.Error())
+	}
+	o.span.End()
+	if o.cancel != nil {
+		o.cancel()
+	}
+}
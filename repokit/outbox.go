@@ -0,0 +1,353 @@
+package repokit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Outbox column names. OutboxSchema creates a table with these columns.
+const (
+	outboxID          = "event_id"
+	outboxTopic       = "topic"
+	outboxKey         = "event_key"
+	outboxPayload     = "payload"
+	outboxCreatedAt   = "created_at"
+	outboxPublishedAt = "published_at"
+)
+
+// outboxColumns lists the columns read by the relay, in OutboxEvent order.
+var outboxColumns = []string{outboxID, outboxTopic, outboxKey, outboxPayload, outboxCreatedAt}
+
+// OutboxSchema returns the DDL statements creating an outbox table and the
+// index the relay uses to find unpublished events. table must be a valid
+// identifier, as for NewOutbox.
+func OutboxSchema(table string) ([]string, error) {
+	quoted, err := quoteIdentifier(table)
+	if err != nil {
+		return nil, fmt.Errorf("outbox table: %w", err)
+	}
+	index, err := quoteIdentifier(table + "ByPublishedAt")
+	if err != nil {
+		return nil, fmt.Errorf("outbox index: %w", err)
+	}
+	return []string{
+		fmt.Sprintf(`CREATE TABLE %s (
+	%s STRING(64) NOT NULL,
+	%s STRING(MAX) NOT NULL,
+	%s STRING(MAX),
+	%s BYTES(MAX) NOT NULL,
+	%s TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+	%s TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (%s)`, quoted, outboxID, outboxTopic, outboxKey, outboxPayload, outboxCreatedAt, outboxPublishedAt, outboxID),
+		fmt.Sprintf(`CREATE INDEX %s ON %s (%s, %s)`, index, quoted, outboxPublishedAt, outboxCreatedAt),
+	}, nil
+}
+
+// OutboxEvent is a domain event stored in an outbox table.
+type OutboxEvent struct {
+	// ID identifies the event. EnqueueTx generates one when empty and sets
+	// it on the event; publishers can use it to drop the duplicates
+	// at-least-once delivery may produce.
+	ID string
+	// Topic is the destination of the event.
+	Topic string
+	// Key is an optional partitioning or ordering key.
+	Key string
+	// Payload is the encoded event.
+	Payload []byte
+	// CreatedAt is the commit timestamp of the transaction that enqueued the
+	// event. It is set by the relay.
+	CreatedAt time.Time
+}
+
+// Outbox writes domain events to an outbox table in the same transaction as
+// the entity changes they describe, so an event is stored if and only if the
+// changes commit. An OutboxRelay then publishes the stored events.
+type Outbox struct {
+	client    *spanner.Client
+	tableName string
+	table     string // quoted table name used in SQL text
+}
+
+// NewOutbox creates an Outbox writing to table, which must have the layout
+// created by OutboxSchema.
+func NewOutbox(client *spanner.Client, table string) (*Outbox, error) {
+	if client == nil {
+		return nil, fmt.Errorf("spanner client is required")
+	}
+	quoted, err := quoteIdentifier(table)
+	if err != nil {
+		return nil, fmt.Errorf("outbox table: %w", err)
+	}
+	return &Outbox{client: client, tableName: table, table: quoted}, nil
+}
+
+// EnqueueTx buffers events inside tx, setting the ID of the events without
+// one. They are written with the transaction's commit timestamp, which orders
+// their publication.
+func (o *Outbox) EnqueueTx(tx Transaction, events ...*OutboxEvent) error {
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return err
+	}
+
+	mutations := make([]*spanner.Mutation, len(events))
+	for i, e := range events {
+		if e == nil {
+			return fmt.Errorf("outbox event is nil")
+		}
+		if e.Topic == "" {
+			return fmt.Errorf("outbox event topic is required")
+		}
+		if e.ID == "" {
+			if e.ID, err = newEventID(); err != nil {
+				return err
+			}
+		}
+		var key spanner.NullString
+		if e.Key != "" {
+			key = spanner.NullString{StringVal: e.Key, Valid: true}
+		}
+		payload := e.Payload
+		if payload == nil {
+			payload = []byte{}
+		}
+		mutations[i] = spanner.Insert(o.tableName,
+			[]string{outboxID, outboxTopic, outboxKey, outboxPayload, outboxCreatedAt},
+			[]interface{}{e.ID, e.Topic, key, payload, spanner.CommitTimestamp})
+	}
+	return stx.ReadWriteTransaction().BufferWrite(mutations)
+}
+
+// newEventID returns a random 128-bit event ID.
+func newEventID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate outbox event id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Publisher delivers outbox events to a message broker.
+type Publisher interface {
+	// Publish delivers events, in order. If it returns an error, none of the
+	// events is marked as sent and all of them are published again later, so
+	// Publish may see an event more than once.
+	Publish(ctx context.Context, events []OutboxEvent) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc func(ctx context.Context, events []OutboxEvent) error
+
+// Publish calls f(ctx, events).
+func (f PublisherFunc) Publish(ctx context.Context, events []OutboxEvent) error {
+	return f(ctx, events)
+}
+
+// OutboxRelay polls an outbox table for unpublished events, hands them to a
+// Publisher in commit timestamp order and marks them as sent. Delivery is
+// at-least-once: an event is published again if the process stops between
+// publishing it and marking it, or if several relays poll the same table.
+type OutboxRelay struct {
+	outbox          *Outbox
+	publisher       Publisher
+	batchSize       int
+	pollInterval    time.Duration
+	retention       time.Duration
+	cleanupInterval time.Duration
+	onError         func(error) // nil when errors are not reported
+}
+
+// OutboxRelayOption configures an OutboxRelay.
+type OutboxRelayOption func(*OutboxRelay)
+
+// WithRelayBatchSize sets how many events are published at a time. The
+// default is 100.
+func WithRelayBatchSize(n int) OutboxRelayOption {
+	return func(r *OutboxRelay) {
+		r.batchSize = n
+	}
+}
+
+// WithRelayPollInterval sets how long Run waits before polling again once
+// the outbox is drained. It must be positive; the default is one second.
+func WithRelayPollInterval(d time.Duration) OutboxRelayOption {
+	return func(r *OutboxRelay) {
+		r.pollInterval = d
+	}
+}
+
+// WithRelayCleanup makes Run delete, every interval, the events published
+// more than retention ago. The defaults are 24 hours and one hour; a negative
+// retention disables the cleanup.
+func WithRelayCleanup(retention, interval time.Duration) OutboxRelayOption {
+	return func(r *OutboxRelay) {
+		r.retention = retention
+		r.cleanupInterval = interval
+	}
+}
+
+// WithRelayErrorHandler makes Run pass the errors of its polls and cleanups
+// to fn, e.g. to log them, instead of dropping them. Run keeps going either
+// way. Errors caused by the end of Run's context are not reported.
+func WithRelayErrorHandler(fn func(error)) OutboxRelayOption {
+	return func(r *OutboxRelay) {
+		r.onError = fn
+	}
+}
+
+// NewOutboxRelay creates a relay publishing the events of outbox to publisher.
+func NewOutboxRelay(outbox *Outbox, publisher Publisher, opts ...OutboxRelayOption) (*OutboxRelay, error) {
+	if outbox == nil {
+		return nil, fmt.Errorf("outbox is required")
+	}
+	if publisher == nil {
+		return nil, fmt.Errorf("publisher is required")
+	}
+	r := &OutboxRelay{
+		outbox:          outbox,
+		publisher:       publisher,
+		batchSize:       100,
+		pollInterval:    time.Second,
+		retention:       24 * time.Hour,
+		cleanupInterval: time.Hour,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.batchSize <= 0 {
+		return nil, fmt.Errorf("outbox relay batch size must be positive, got %d", r.batchSize)
+	}
+	if r.pollInterval <= 0 {
+		return nil, fmt.Errorf("outbox relay poll interval must be positive, got %s", r.pollInterval)
+	}
+	return r, nil
+}
+
+// Run relays events until ctx is done, then returns ctx's error. Failed
+// polls are retried at the next poll interval; see WithRelayErrorHandler to
+// observe them.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	var lastCleanup time.Time
+	for {
+		n, err := r.RelayOnce(ctx)
+		r.report(ctx, err)
+		if r.retention >= 0 && time.Since(lastCleanup) >= r.cleanupInterval {
+			_, cleanupErr := r.Cleanup(ctx)
+			r.report(ctx, cleanupErr)
+			if cleanupErr == nil {
+				lastCleanup = time.Now()
+			}
+		}
+		if err == nil && n == r.batchSize {
+			continue // more events are probably waiting
+		}
+
+		timer := time.NewTimer(r.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// report passes err to the error handler, unless it is nil or ctx is done.
+func (r *OutboxRelay) report(ctx context.Context, err error) {
+	if err != nil && r.onError != nil && ctx.Err() == nil {
+		r.onError(err)
+	}
+}
+
+// RelayOnce publishes the oldest batch of unpublished events and marks them
+// as sent. It returns the number of events published.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	o := r.outbox
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf("SELECT %s, %s, %s, %s, %s FROM %s WHERE %s IS NULL ORDER BY %s, %s LIMIT @limit",
+			outboxID, outboxTopic, outboxKey, outboxPayload, outboxCreatedAt,
+			o.table, outboxPublishedAt, outboxCreatedAt, outboxID),
+		Params: map[string]interface{}{"limit": r.batchSize},
+	}
+
+	var events []OutboxEvent
+	err := o.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var (
+			e   OutboxEvent
+			key spanner.NullString
+		)
+		if err := row.Columns(&e.ID, &e.Topic, &key, &e.Payload, &e.CreatedAt); err != nil {
+			return err
+		}
+		e.Key = key.StringVal
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("read outbox %s: %w", o.tableName, err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := r.publisher.Publish(ctx, events); err != nil {
+		return 0, fmt.Errorf("publish outbox events: %w", err)
+	}
+
+	mutations := make([]*spanner.Mutation, len(events))
+	for i, e := range events {
+		mutations[i] = spanner.Update(o.tableName,
+			[]string{outboxID, outboxPublishedAt},
+			[]interface{}{e.ID, spanner.CommitTimestamp})
+	}
+	if _, err := o.client.Apply(ctx, mutations); err != nil {
+		return 0, fmt.Errorf("mark outbox events as sent: %w", err)
+	}
+	return len(events), nil
+}
+
+// Cleanup deletes the events published more than the relay's retention ago.
+// It returns the number of events deleted.
+func (r *OutboxRelay) Cleanup(ctx context.Context) (int, error) {
+	o := r.outbox
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf("SELECT %s FROM %s WHERE %s < @cutoff LIMIT @limit",
+			outboxID, o.table, outboxPublishedAt),
+		Params: map[string]interface{}{
+			"cutoff": time.Now().Add(-r.retention),
+			"limit":  r.batchSize,
+		},
+	}
+
+	deleted := 0
+	for {
+		var mutations []*spanner.Mutation
+		err := o.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			var id string
+			if err := row.Columns(&id); err != nil {
+				return err
+			}
+			mutations = append(mutations, spanner.Delete(o.tableName, spanner.Key{id}))
+			return nil
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("read outbox %s: %w", o.tableName, err)
+		}
+		if len(mutations) == 0 {
+			return deleted, nil
+		}
+		if _, err := o.client.Apply(ctx, mutations); err != nil {
+			return deleted, fmt.Errorf("delete published outbox events: %w", err)
+		}
+		deleted += len(mutations)
+		if len(mutations) < r.batchSize {
+			return deleted, nil
+		}
+	}
+}
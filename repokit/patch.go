@@ -0,0 +1,348 @@
+package repokit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// patchPlan is a validated Patch: the columns written whole and the JSON
+// paths set inside JSON columns.
+type patchPlan struct {
+	columns []string
+	values  []interface{}
+	paths   []jsonPatch
+}
+
+// jsonPatch sets paths inside a JSON column to values.
+type jsonPatch struct {
+	column string
+	paths  []string // JSONPath literals such as $.address.city
+	values []interface{}
+}
+
+// Patch updates only the given fields of the row identified by key, so
+// concurrent patches of different fields do not overwrite each other. Field
+// names are columns of the canonical column list, other than the primary
+// key. A dotted name such as "profile.address.city" sets a path inside the
+// JSON column profile, creating missing objects along it and starting from an
+// empty object if the column is NULL; path segments must be identifiers, and
+// values are converted to JSON (pass a spanner.NullJSON to set an object).
+//
+// Without dotted names Patch writes a spanner.Update mutation holding the key
+// and the named columns, which fails with codes.NotFound if the row does not
+// exist. With dotted names it runs an UPDATE statement using JSON_SET, and
+// returns a codes.NotFound error if no row matched. Lifecycle hooks are not
+// run, since there is no entity.
+func (r *SpannerRepository[T, K]) Patch(ctx context.Context, key K, fields map[string]interface{}, opts ...CallOption) (err error) {
+	ctx, op := r.startOperation(ctx, "Patch", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	plan, err := r.planPatch(fields)
+	if err != nil {
+		return err
+	}
+	defer r.invalidate(key)
+	if len(plan.paths) == 0 {
+		op.setMutations(1)
+		return r.apply(ctx, op, r.patchMutation(key, plan))
+	}
+
+	stmt, err := r.buildPatchStatement(key, plan, Criteria{})
+	if err != nil {
+		return err
+	}
+	op.setStatement(stmt)
+	count, err := r.runUpdate(ctx, op, stmt)
+	if err != nil {
+		return err
+	}
+	return r.patchedRows(op, count)
+}
+
+// PatchTx is the transactional version of Patch.
+func (r *SpannerRepository[T, K]) PatchTx(tx Transaction, key K, fields map[string]interface{}, opts ...CallOption) (err error) {
+	ctx, op := r.startTxOperation(tx, "PatchTx", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return err
+	}
+	plan, err := r.planPatch(fields)
+	if err != nil {
+		return err
+	}
+	if len(plan.paths) == 0 {
+		op.setMutations(1)
+		if err := stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{r.patchMutation(key, plan)}); err != nil {
+			return err
+		}
+		r.invalidateOnCommit(stx, key)
+		return nil
+	}
+
+	stmt, err := r.buildPatchStatement(key, plan, Criteria{})
+	if err != nil {
+		return err
+	}
+	op.setStatement(stmt)
+	count, err := stx.ReadWriteTransaction().UpdateWithOptions(ctx, stmt, op.opts.queryOptions())
+	if err != nil {
+		return err
+	}
+	r.invalidateOnCommit(stx, key)
+	return r.patchedRows(op, count)
+}
+
+// planPatch checks fields against the canonical column list and splits them
+// into whole columns and JSON paths, in name order.
+func (r *SpannerRepository[T, K]) planPatch(fields map[string]interface{}) (patchPlan, error) {
+	var plan patchPlan
+	if len(fields) == 0 {
+		return plan, fmt.Errorf("patch of table %s has no fields", r.tableName)
+	}
+	if len(r.columns) == 0 {
+		return plan, fmt.Errorf("table %s has no column list: configure WithColumns or spanner tags", r.tableName)
+	}
+	columns := make(map[string]string, len(r.columns))
+	for _, c := range r.columns {
+		columns[strings.ToLower(c)] = c
+	}
+	for _, k := range r.primaryKeys {
+		delete(columns, strings.ToLower(k))
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	whole := map[string]bool{}
+	paths := map[string]int{}
+	for _, name := range names {
+		column, path, nested := strings.Cut(name, ".")
+		if err := validateIdentifier(column); err != nil {
+			return plan, err
+		}
+		canonical, ok := columns[strings.ToLower(column)]
+		if !ok {
+			for _, k := range r.primaryKeys {
+				if strings.EqualFold(k, column) {
+					return plan, fmt.Errorf("table %s: primary key column %s cannot be patched", r.tableName, k)
+				}
+			}
+			return plan, fmt.Errorf("%w: %s", ErrColumnNotAllowed, column)
+		}
+		lower := strings.ToLower(canonical)
+		if _, byPath := paths[lower]; whole[lower] || (byPath && !nested) {
+			return plan, fmt.Errorf("table %s: column %s is patched both whole and by path", r.tableName, canonical)
+		}
+		if !nested {
+			whole[lower] = true
+			plan.columns = append(plan.columns, canonical)
+			plan.values = append(plan.values, fields[name])
+			continue
+		}
+
+		jsonPath, err := r.jsonPath(canonical, path)
+		if err != nil {
+			return plan, err
+		}
+		i, ok := paths[lower]
+		if !ok {
+			i = len(plan.paths)
+			paths[lower] = i
+			plan.paths = append(plan.paths, jsonPatch{column: canonical})
+		}
+		plan.paths[i].paths = append(plan.paths[i].paths, jsonPath)
+		plan.paths[i].values = append(plan.paths[i].values, fields[name])
+	}
+	return plan, nil
+}
+
+// jsonPath checks that column is a JSON column of T and converts the dotted
+// path inside it to a JSONPath literal.
+func (r *SpannerRepository[T, K]) jsonPath(column, path string) (string, error) {
+	expected, err := r.expectedSchema()
+	if err != nil {
+		return "", err
+	}
+	isJSON := false
+	for _, c := range expected.Columns {
+		if strings.EqualFold(c.Name, column) {
+			isJSON = baseType(c.Type) == "JSON"
+		}
+	}
+	if !isJSON {
+		return "", fmt.Errorf("table %s: column %s is not a JSON column, path %s cannot be patched", r.tableName, column, path)
+	}
+	// Segments are identifiers, so the literal needs no escaping.
+	for _, segment := range strings.Split(path, ".") {
+		if err := validateIdentifier(segment); err != nil {
+			return "", fmt.Errorf("table %s column %s path: %w", r.tableName, column, err)
+		}
+	}
+	return "$." + path, nil
+}
+
+// patchMutation builds the spanner.Update mutation writing the whole columns
+// of plan.
+func (r *SpannerRepository[T, K]) patchMutation(key K, plan patchPlan) *spanner.Mutation {
+	columns := append(append([]string{}, r.primaryKeys...), plan.columns...)
+	values := append(r.keys.values(key), plan.values...)
+	return spanner.Update(r.tableName, columns, values)
+}
+
+// buildPatchStatement builds the UPDATE statement writing plan to the row
+// identified by key if it matches precondition, e.g.
+// UPDATE t SET a = @p0, doc = JSON_SET(COALESCE(doc, JSON '{}'), '$.x', @p1)
+// WHERE id = @p2.
+func (r *SpannerRepository[T, K]) buildPatchStatement(key K, plan patchPlan, precondition Criteria) (spanner.Statement, error) {
+	params := map[string]interface{}{}
+	assignments := make([]string, 0, len(plan.columns)+len(plan.paths))
+	for i, c := range plan.columns {
+		column, err := quoteIdentifier(c)
+		if err != nil {
+			return spanner.Statement{}, err
+		}
+		assignments = append(assignments, fmt.Sprintf("%s = %s", column, addParam(params, plan.values[i])))
+	}
+	for _, p := range plan.paths {
+		column, err := quoteIdentifier(p.column)
+		if err != nil {
+			return spanner.Statement{}, err
+		}
+		// JSON_SET on NULL returns NULL, so a missing document starts empty.
+		args := []string{fmt.Sprintf("COALESCE(%s, JSON '{}')", column)}
+		for i, path := range p.paths {
+			args = append(args, "'"+path+"'", addParam(params, p.values[i]))
+		}
+		assignments = append(assignments, fmt.Sprintf("%s = JSON_SET(%s)", column, strings.Join(args, ", ")))
+	}
+	where, err := buildCriteriaClause(r.keys.criteria(key).And(precondition.Conditions...), params, r.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	return spanner.Statement{
+		SQL:    fmt.Sprintf("UPDATE %s SET %s%s", r.table, strings.Join(assignments, ", "), where),
+		Params: params,
+	}, nil
+}
+
+// patchedRows records the rows updated by a patch statement and returns a
+// codes.NotFound error if there were none.
+func (r *SpannerRepository[T, K]) patchedRows(op *operation, count int64) error {
+	op.setRows(int(count))
+	if count == 0 {
+		return status.Errorf(codes.NotFound, "table %s: row to patch not found", r.tableName)
+	}
+	return nil
+}
+
+// runUpdate runs the idempotent statement stmt in a read-write transaction
+// and returns the number of rows it updated. Spanner retries aborted
+// transactions; other failures are retried under the operation's retry
+// policy in a new transaction.
+func (r *SpannerRepository[T, K]) runUpdate(ctx context.Context, op *operation, stmt spanner.Statement) (count int64, err error) {
+	err = op.retry(ctx, func(ctx context.Context) error {
+		var err error
+		count, err = r.runUpdateOnce(ctx, op, stmt)
+		return err
+	})
+	return count, err
+}
+
+// runUpdateOnce runs stmt in a read-write transaction, retried by Spanner
+// only if it aborts, and returns the number of rows it updated.
+func (r *SpannerRepository[T, K]) runUpdateOnce(ctx context.Context, op *operation, stmt spanner.Statement) (count int64, err error) {
+	_, err = r.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		op.attempt()
+		var err error
+		count, err = txn.UpdateWithOptions(ctx, stmt, op.opts.queryOptions())
+		return err
+	}, op.opts.transactionOptions())
+	return count, err
+}
+
+// UpdateIf applies changes to the row identified by key only if the row also
+// matches precondition, in a single UPDATE ... WHERE statement run in a
+// read-write transaction, e.g. setting status to SHIPPED only if it is PAID:
+//
+//	applied, err := repo.UpdateIf(ctx, key,
+//		repokit.Where(repokit.Eq("status", "PAID")),
+//		map[string]interface{}{"status": "SHIPPED"})
+//
+// changes are checked like the fields of Patch, dotted JSON paths included.
+// The precondition's orderings are ignored. It reports whether the row was
+// updated: false means the precondition did not hold or the row does not
+// exist. Lifecycle hooks are not run.
+//
+// UpdateIf is not retried under the retry policy, since a retry after a
+// commit whose response was lost would find the precondition no longer
+// holding and report false for a write that happened. Spanner still retries
+// the transaction if it aborts; other errors leave the outcome unknown.
+func (r *SpannerRepository[T, K]) UpdateIf(ctx context.Context, key K, precondition Criteria, changes map[string]interface{}, opts ...CallOption) (applied bool, err error) {
+	ctx, op := r.startOperation(ctx, "UpdateIf", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	stmt, err := r.buildUpdateIfStatement(key, precondition, changes)
+	if err != nil {
+		return false, err
+	}
+	op.setStatement(stmt)
+	count, err := r.runUpdateOnce(ctx, op, stmt)
+	if err != nil {
+		return false, err
+	}
+	op.setRows(int(count))
+	if count > 0 {
+		r.invalidate(key)
+	}
+	return count > 0, nil
+}
+
+// UpdateIfTx is the transactional version of UpdateIf. The precondition is
+// evaluated when the statement runs, so later reads in tx see the change.
+func (r *SpannerRepository[T, K]) UpdateIfTx(tx Transaction, key K, precondition Criteria, changes map[string]interface{}, opts ...CallOption) (applied bool, err error) {
+	ctx, op := r.startTxOperation(tx, "UpdateIfTx", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return false, err
+	}
+	stmt, err := r.buildUpdateIfStatement(key, precondition, changes)
+	if err != nil {
+		return false, err
+	}
+	op.setStatement(stmt)
+	count, err := stx.ReadWriteTransaction().UpdateWithOptions(ctx, stmt, op.opts.queryOptions())
+	if err != nil {
+		return false, err
+	}
+	op.setRows(int(count))
+	if count > 0 {
+		r.invalidateOnCommit(stx, key)
+	}
+	return count > 0, nil
+}
+
+// buildUpdateIfStatement builds the statement used by UpdateIf and UpdateIfTx.
+func (r *SpannerRepository[T, K]) buildUpdateIfStatement(key K, precondition Criteria, changes map[string]interface{}) (spanner.Statement, error) {
+	plan, err := r.planPatch(changes)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	return r.buildPatchStatement(key, plan, precondition)
+}
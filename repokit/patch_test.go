@@ -0,0 +1,80 @@
+package repokit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+type patchUser struct {
+	ID      string           `spanner:"id"`
+	Email   string           `spanner:"email"`
+	Profile spanner.NullJSON `spanner:"profile"`
+}
+
+func TestBuildPatchStatement(t *testing.T) {
+	keys, err := newKeyCodec[string]([]string{"id"})
+	if err != nil {
+		t.Fatalf("newKeyCodec: %v", err)
+	}
+	repo := &SpannerRepository[patchUser, string]{
+		tableName:   "Users",
+		table:       "`Users`",
+		primaryKeys: []string{"id"},
+		columns:     []string{"id", "email", "profile"},
+		keys:        keys,
+	}
+
+	plan, err := repo.planPatch(map[string]interface{}{
+		"profile.address.city": "Lisbon",
+		"EMAIL":                "ann@example.com",
+		"profile.nickname":     "ann",
+	})
+	if err != nil {
+		t.Fatalf("planPatch: %v", err)
+	}
+	stmt, err := repo.buildPatchStatement("u1", plan, Criteria{})
+	if err != nil {
+		t.Fatalf("buildPatchStatement: %v", err)
+	}
+	wantSQL := "UPDATE `Users` SET `email` = @p0, `profile` = JSON_SET(COALESCE(`profile`, JSON '{}'), '$.address.city', @p1, '$.nickname', @p2) WHERE `id` = @p3"
+	if stmt.SQL != wantSQL {
+		t.Fatalf("SQL = %q, want %q", stmt.SQL, wantSQL)
+	}
+	wantParams := map[string]interface{}{"p0": "ann@example.com", "p1": "Lisbon", "p2": "ann", "p3": "u1"}
+	if !reflect.DeepEqual(stmt.Params, wantParams) {
+		t.Fatalf("params = %v, want %v", stmt.Params, wantParams)
+	}
+
+	if plan, err := repo.planPatch(map[string]interface{}{"email": "a@b.c"}); err != nil || len(plan.paths) != 0 {
+		t.Fatalf("planPatch(email) = (%+v, %v), want one whole column", plan, err)
+	}
+
+	for _, fields := range []map[string]interface{}{
+		nil,
+		{"id": "u2"},
+		{"email.domain": "example.com"},
+		{"profile": nil, "profile.nickname": "ann"},
+		{"profile.address..city": "Lisbon"},
+		{"profile.x']": "y"},
+	} {
+		if _, err := repo.planPatch(fields); err == nil {
+			t.Errorf("planPatch(%v) succeeded", fields)
+		}
+	}
+	if _, err := repo.planPatch(map[string]interface{}{"phone": "1"}); !errors.Is(err, ErrColumnNotAllowed) {
+		t.Fatalf("planPatch(phone) = %v, want ErrColumnNotAllowed", err)
+	}
+
+	stmt, err = repo.buildUpdateIfStatement("u1", Where(Eq("email", "old@example.com")).OrderBy(Asc("email")),
+		map[string]interface{}{"email": "new@example.com"})
+	if err != nil {
+		t.Fatalf("buildUpdateIfStatement: %v", err)
+	}
+	wantSQL = "UPDATE `Users` SET `email` = @p0 WHERE `id` = @p1 AND `email` = @p2"
+	if stmt.SQL != wantSQL {
+		t.Fatalf("SQL = %q, want %q", stmt.SQL, wantSQL)
+	}
+}
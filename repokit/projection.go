@@ -0,0 +1,207 @@
+package repokit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"cloud.google.com/go/spanner"
+)
+
+// fieldColumn returns the column a struct field maps to: its `spanner` tag,
+// or its name if untagged, as spanner.Row.ToStruct matches them. ok is false
+// for unexported fields and fields tagged `spanner:"-"`.
+func fieldColumn(field reflect.StructField) (name string, ok bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name = field.Tag.Get("spanner")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	}
+	return name, true
+}
+
+// structColumns returns the column names a struct type maps to (see
+// fieldColumn).
+func structColumns(t reflect.Type) ([]string, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("projection type must be a struct, got: %s", t)
+	}
+
+	columns := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := fieldColumn(t.Field(i)); ok {
+			columns = append(columns, name)
+		}
+	}
+	return columns, nil
+}
+
+// taggedColumns returns the `spanner` tag names of a struct type, ignoring
+// untagged fields. It returns nil if t is not a struct or has no tags.
+func taggedColumns(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("spanner")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// buildProjectionStatement builds the SELECT used by the projection functions.
+// When columns is empty, the column list is derived from P.
+func buildProjectionStatement[P any, T any, K any](
+	repo *SpannerRepository[T, K],
+	columns []string,
+	criteria Criteria,
+) (spanner.Statement, error) {
+	if len(columns) == 0 {
+		var err error
+		columns, err = structColumns(reflect.TypeOf((*P)(nil)).Elem())
+		if err != nil {
+			return spanner.Statement{}, err
+		}
+	}
+
+	columnList, err := repo.idents.buildColumnList(columns)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	params := map[string]interface{}{}
+	where, err := buildCriteriaClause(criteria, params, repo.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	orderBy, err := buildOrderClause(criteria, repo.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	return spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s%s%s", columnList, repo.table, where, orderBy),
+		Params: params,
+	}, nil
+}
+
+// Project fetches the rows matching criteria and maps them into P instead of T,
+// bypassing the repository's rowMapper. Rows are decoded with row.ToStruct, so P
+// must be a struct whose `spanner` tags (or field names) match the selected columns.
+// When columns is empty, the column list is derived from P.
+//
+// Project is a function rather than a method because Go methods cannot declare
+// their own type parameters. P comes first so T and K are inferred from repo.
+//
+// Example:
+//
+//	type UserSummary struct {
+//	    UserID string `spanner:"user_id"`
+//	}
+//
+//	summaries, err := repokit.Project[UserSummary](repo, ctx, nil, repokit.Criteria{})
+func Project[P any, T any, K any](
+	repo *SpannerRepository[T, K],
+	ctx context.Context,
+	columns []string,
+	criteria Criteria,
+	opts ...CallOption,
+) (projections []P, err error) {
+	ctx, op := repo.startOperation(ctx, "Project", opts)
+	defer func() { op.end(err) }()
+
+	stmt, err := buildProjectionStatement[P](repo, columns, criteria)
+	if err != nil {
+		return nil, err
+	}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		projections, err = queryStructs[P](ctx, repo.client.Single(), stmt, op.opts.queryOptions())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRows(len(projections))
+	return projections, nil
+}
+
+// ProjectTx is the transactional version of Project.
+func ProjectTx[P any, T any, K any](
+	repo *SpannerRepository[T, K],
+	tx Transaction,
+	columns []string,
+	criteria Criteria,
+	opts ...CallOption,
+) (projections []P, err error) {
+	ctx, op := repo.startTxOperation(tx, "ProjectTx", opts)
+	defer func() { op.end(err) }()
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := buildProjectionStatement[P](repo, columns, criteria)
+	if err != nil {
+		return nil, err
+	}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		projections, err = queryStructs[P](ctx, stx.ReadWriteTransaction(), stmt, op.opts.queryOptions())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRows(len(projections))
+	return projections, nil
+}
+
+// ProjectByID fetches a single row by primary key and maps it into P.
+// It returns the projection, a boolean indicating existence, and any error encountered.
+func ProjectByID[P any, T any, K any](
+	repo *SpannerRepository[T, K],
+	ctx context.Context,
+	key K,
+	columns []string,
+	opts ...CallOption,
+) (projection P, found bool, err error) {
+	ctx, op := repo.startOperation(ctx, "ProjectByID", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	stmt, err := buildProjectionStatement[P](repo, columns, repo.keys.criteria(key))
+	if err != nil {
+		return projection, false, err
+	}
+
+	op.setStatement(stmt)
+	var results []P
+	err = op.retry(ctx, func(ctx context.Context) error {
+		results, err = queryStructs[P](ctx, repo.client.Single(), stmt, op.opts.queryOptions())
+		return err
+	})
+	if err != nil {
+		return projection, false, err
+	}
+	op.setRows(len(results))
+	if len(results) == 0 {
+		return projection, false, nil
+	}
+	return results[0], true, nil
+}
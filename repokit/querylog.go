@@ -0,0 +1,103 @@
+package repokit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// ParamLogMode controls how statement parameter values are written to the
+// query log. Parameter names are always logged.
+type ParamLogMode int
+
+const (
+	// ParamsRedacted replaces every parameter value with "[REDACTED]".
+	ParamsRedacted ParamLogMode = iota
+	// ParamsHashed replaces every parameter value with a short SHA-256 digest,
+	// so equal values can be correlated across log lines without being
+	// revealed. Low-entropy values such as e-mail addresses can still be
+	// guessed from their digest; mask those columns with WithMaskedColumns.
+	ParamsHashed
+)
+
+// redacted is logged in place of hidden parameter values.
+const redacted = "[REDACTED]"
+
+// queryLogger writes one structured record per SQL statement run by a repository.
+type queryLogger struct {
+	logger *slog.Logger
+	slow   time.Duration
+	mode   ParamLogMode
+	masked map[string]bool
+}
+
+// paramColumnPattern matches "column <op> @param" comparisons, which is how
+// repokit binds criteria and key values, to find the column a parameter is
+// compared with.
+var paramColumnPattern = regexp.MustCompile(
+	"(?i)`?([A-Za-z_][A-Za-z0-9_]*)`?\\s*(?:=|!=|<>|<=|>=|<|>|\\sLIKE|\\sIN\\s+UNNEST\\s*\\()\\s*@([A-Za-z_][A-Za-z0-9_]*)")
+
+// paramColumns maps the parameters of sql to the column they are compared with.
+func paramColumns(sql string) map[string]string {
+	columns := map[string]string{}
+	for _, m := range paramColumnPattern.FindAllStringSubmatch(sql, -1) {
+		columns[m[2]] = strings.ToLower(m[1])
+	}
+	return columns
+}
+
+// paramValue renders the value of a parameter bound to column for the log.
+func (l *queryLogger) paramValue(name, column string, value interface{}) string {
+	if l.mode == ParamsRedacted || l.masked[strings.ToLower(name)] || l.masked[column] {
+		return redacted
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%T:%v", value, value)))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// log records stmt with its outcome. Statements slower than the slow
+// threshold are logged at warn, failed ones at error and the rest at debug.
+func (l *queryLogger) log(ctx context.Context, table, operation string, stmt spanner.Statement, d time.Duration, rows int, err error) {
+	level := slog.LevelDebug
+	switch {
+	case err != nil:
+		level = slog.LevelError
+	case l.slow > 0 && d >= l.slow:
+		level = slog.LevelWarn
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	names := make([]string, 0, len(stmt.Params))
+	for name := range stmt.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	columns := paramColumns(stmt.SQL)
+	params := make([]any, len(names))
+	for i, name := range names {
+		params[i] = slog.String(name, l.paramValue(name, columns[name], stmt.Params[name]))
+	}
+
+	attrs := []slog.Attr{
+		slog.String("table", table),
+		slog.String("operation", operation),
+		slog.String("sql", stmt.SQL),
+		slog.Group("params", params...),
+		slog.Duration("duration", d),
+		slog.Int("rows", rows),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.logger.LogAttrs(ctx, level, "repokit query", attrs...)
+}
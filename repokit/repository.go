@@ -0,0 +1,50 @@
+package repokit
+
+import "context"
+
+// Repository defines the storage-agnostic operations of a repository for
+// entities of type T identified by keys of type K.
+//
+// SpannerRepository implements it against Cloud Spanner and MemoryRepository
+// in memory, so services can depend on Repository and swap the in-memory
+// implementation in unit tests. CallOptions tune the Spanner requests and are
+// ignored by implementations they do not apply to.
+type Repository[T any, K any] interface {
+	// FindByID fetches a single entity by its primary key.
+	FindByID(ctx context.Context, key K, columns []string, opts ...CallOption) (T, bool, error)
+
+	// FindAll retrieves all entities.
+	FindAll(ctx context.Context, columns []string, opts ...CallOption) ([]T, error)
+
+	// FindByIDs fetches the entities matching keys, in primary key order.
+	// Keys with no matching entity are skipped.
+	FindByIDs(ctx context.Context, keys []K, columns []string, opts ...CallOption) ([]T, error)
+
+	// FindPage fetches up to pageSize entities in primary key order, starting
+	// after pageToken (the token returned by the previous page, nil for the first).
+	// pageSize must be positive.
+	FindPage(ctx context.Context, pageSize int, pageToken interface{}, columns []string, opts ...CallOption) ([]T, interface{}, error)
+
+	// Exists checks whether an entity exists by primary key.
+	Exists(ctx context.Context, key K, opts ...CallOption) (bool, error)
+
+	// Save inserts or updates an entity.
+	Save(ctx context.Context, entity T, opts ...CallOption) error
+
+	// Update updates an entity.
+	Update(ctx context.Context, entity T, opts ...CallOption) error
+
+	// Delete removes an entity by primary key.
+	Delete(ctx context.Context, key K, opts ...CallOption) error
+
+	// SaveTx inserts or updates an entity inside a transaction.
+	SaveTx(tx Transaction, entity T, opts ...CallOption) error
+
+	// UpdateTx updates an entity inside a transaction.
+	UpdateTx(tx Transaction, entity T, opts ...CallOption) error
+
+	// DeleteTx removes an entity by primary key inside a transaction.
+	DeleteTx(tx Transaction, key K, opts ...CallOption) error
+}
+
+var _ Repository[struct{}, string] = (*SpannerRepository[struct{}, string])(nil)
@@ -0,0 +1,96 @@
+package repokit
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy configures how repokit retries a failed call, on top of the
+// retries the Spanner client performs on its own. It applies to reads and to
+// the blind writes of Save, Update and Delete made outside a transaction;
+// calls inside a transaction are retried by the transaction manager instead.
+// Because writes may be retried after an ambiguous failure, the mutation
+// builders must be idempotent (spanner.InsertOrUpdate, spanner.Update).
+//
+// The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry. Defaults to 2.
+	Multiplier float64
+	// RetryableCodes lists the gRPC codes that are retried. Defaults to
+	// codes.Unavailable and codes.ResourceExhausted.
+	RetryableCodes []codes.Code
+}
+
+// defaultRetryableCodes are retried when RetryPolicy.RetryableCodes is empty.
+var defaultRetryableCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted}
+
+// retryable reports whether err should be retried under p.
+func (p RetryPolicy) retryable(err error) bool {
+	retryableCodes := p.RetryableCodes
+	if len(retryableCodes) == 0 {
+		retryableCodes = defaultRetryableCodes
+	}
+	code := spanner.ErrCode(err)
+	for _, c := range retryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before retry number n (starting at 1), with
+// full jitter so concurrent callers do not retry in lockstep.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	initial, maxBackoff, multiplier := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	d := float64(initial)
+	for i := 1; i < n && d < float64(maxBackoff); i++ {
+		d *= multiplier
+	}
+	if d > float64(maxBackoff) {
+		d = float64(maxBackoff)
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// retry runs fn until it succeeds, fails with a non-retryable error, runs out
+// of attempts or the context is done. The last error is returned.
+func (o *operation) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	p := o.opts.retry
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+		o.retries++
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
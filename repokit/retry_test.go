@@ -0,0 +1,54 @@
+package repokit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOperationRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	run := func(ctx context.Context, policy RetryPolicy, errs ...error) (int, error) {
+		op := &operation{opts: callOptions{retry: policy}}
+		calls := 0
+		err := op.retry(ctx, func(context.Context) error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+			return nil
+		})
+		return calls, err
+	}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	if calls, err := run(context.Background(), policy, unavailable, unavailable); err != nil || calls != 3 {
+		t.Fatalf("transient errors: calls=%d err=%v, want success on the third attempt", calls, err)
+	}
+	if calls, err := run(context.Background(), policy, unavailable, unavailable, unavailable); spanner.ErrCode(err) != codes.Unavailable || calls != 3 {
+		t.Fatalf("persistent errors: calls=%d err=%v, want Unavailable after 3 attempts", calls, err)
+	}
+	if calls, err := run(context.Background(), policy, status.Error(codes.NotFound, "missing")); spanner.ErrCode(err) != codes.NotFound || calls != 1 {
+		t.Fatalf("non-retryable error: calls=%d err=%v, want NotFound without retries", calls, err)
+	}
+	if calls, _ := run(context.Background(), RetryPolicy{}, unavailable); calls != 1 {
+		t.Fatalf("zero policy made %d calls, want 1", calls)
+	}
+
+	custom := policy
+	custom.RetryableCodes = []codes.Code{codes.Aborted}
+	if calls, _ := run(context.Background(), custom, unavailable); calls != 1 {
+		t.Fatalf("custom codes retried Unavailable: %d calls", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+	if calls, err := run(ctx, slow, unavailable, unavailable); spanner.ErrCode(err) != codes.Unavailable || calls != 1 {
+		t.Fatalf("canceled context: calls=%d err=%v, want to stop after the first attempt", calls, err)
+	}
+}
@@ -0,0 +1,332 @@
+package repokit
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// TableSchema describes a table for DDL generation. It is built from an
+// entity's struct tags by SchemaOf, NewTableSchema or
+// SpannerRepositoryBuilder.Schema.
+type TableSchema struct {
+	Name       string
+	Columns    []ColumnSchema
+	PrimaryKey []string
+	// InterleaveIn is the parent table, if the table is interleaved.
+	InterleaveIn string
+	// OnDeleteCascade deletes the rows of an interleaved table with their
+	// parent row.
+	OnDeleteCascade bool
+	// RowDeletionPolicy, if set, deletes rows once they are old enough.
+	RowDeletionPolicy *RowDeletionPolicy
+	Indexes           []IndexSchema
+}
+
+// ColumnSchema describes a column of a TableSchema.
+type ColumnSchema struct {
+	Name    string
+	Type    string // Spanner type, e.g. STRING(MAX) or ARRAY<INT64>
+	NotNull bool
+	// AllowCommitTimestamp lets the column be set to spanner.CommitTimestamp.
+	AllowCommitTimestamp bool
+}
+
+// IndexSchema describes a secondary index of a TableSchema.
+type IndexSchema struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// RowDeletionPolicy deletes the rows whose timestamp column is older than
+// Days days.
+type RowDeletionPolicy struct {
+	Column string
+	Days   int
+}
+
+// TableOptions holds the table settings that struct tags do not carry.
+type TableOptions struct {
+	// PrimaryKey lists the primary key columns in order. When empty, the
+	// fields tagged `repokit:"pk"` are used, in field order.
+	PrimaryKey        []string
+	InterleaveIn      string
+	OnDeleteCascade   bool
+	RowDeletionPolicy *RowDeletionPolicy
+}
+
+// StructField is a struct field as seen by schema generation. It can be
+// taken from reflection or, as cmd/repokit does, from Go source.
+type StructField struct {
+	Name string
+	// GoType is the field's type as written in Go, with the package name
+	// of imported types, e.g. "spanner.NullString", "*int64" or "[]string".
+	GoType string
+	Tag    reflect.StructTag
+}
+
+// SchemaOf builds the TableSchema of table from the `spanner` and `repokit`
+// tags of T's fields. See NewTableSchema.
+func SchemaOf[T any](table string, opts TableOptions) (TableSchema, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return TableSchema{}, fmt.Errorf("entity type %s is not a struct", t)
+	}
+	return NewTableSchema(table, reflectFields(t), opts)
+}
+
+// reflectFields returns the exported fields of the struct type t.
+func reflectFields(t reflect.Type) []StructField {
+	fields := make([]StructField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fields = append(fields, StructField{Name: f.Name, GoType: goTypeName(f.Type), Tag: f.Tag})
+	}
+	return fields
+}
+
+// goTypeName returns the name of t as written in Go source.
+func goTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + goTypeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + goTypeName(t.Elem())
+	case reflect.Uint8:
+		if t.PkgPath() == "" {
+			return "byte"
+		}
+	}
+	return t.String()
+}
+
+// NewTableSchema builds the TableSchema of table from fields. Like the
+// canonical column list, it only includes fields with a `spanner` tag.
+// Column types are derived from the Go types, and columns are NOT NULL
+// unless their type can hold NULL (pointers, spanner.Null* types, slices).
+// The `repokit` tag, a comma-separated list, refines the column:
+//
+//	pk             the column is part of the primary key, in field order
+//	notnull, null  override the derived nullability
+//	size=N         length of a STRING or BYTES column, instead of MAX
+//	type=T         Spanner type, for Go types without a default mapping
+//	commit_ts      allow_commit_timestamp = true
+//	index=Name     adds the column to secondary index Name, in field order
+//	unique=Name    adds the column to unique index Name, in field order
+func NewTableSchema(table string, fields []StructField, opts TableOptions) (TableSchema, error) {
+	return structSchema(table, fields, opts, true)
+}
+
+// structSchema implements NewTableSchema. Unless requireTypes is set, columns
+// whose Go type has no Spanner mapping are kept with an empty type.
+func structSchema(table string, fields []StructField, opts TableOptions, requireTypes bool) (TableSchema, error) {
+	schema := TableSchema{
+		Name:              table,
+		PrimaryKey:        opts.PrimaryKey,
+		InterleaveIn:      opts.InterleaveIn,
+		OnDeleteCascade:   opts.OnDeleteCascade,
+		RowDeletionPolicy: opts.RowDeletionPolicy,
+	}
+	var pk []string
+	indexes := map[string]*IndexSchema{}
+	seen := map[string]bool{}
+	for _, f := range fields {
+		name := f.Tag.Get("spanner")
+		if name == "" || name == "-" {
+			continue
+		}
+		if seen[strings.ToLower(name)] {
+			return TableSchema{}, fmt.Errorf("table %s field %s: column %s is mapped more than once", table, f.Name, name)
+		}
+		seen[strings.ToLower(name)] = true
+		col, err := columnSchema(name, f.GoType)
+		if err != nil {
+			return TableSchema{}, fmt.Errorf("table %s field %s: %w", table, f.Name, err)
+		}
+		size := "MAX"
+		for _, opt := range strings.Split(f.Tag.Get("repokit"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "":
+			case "pk":
+				pk = append(pk, name)
+			case "notnull":
+				col.NotNull = true
+			case "null":
+				col.NotNull = false
+			case "size":
+				if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+					return TableSchema{}, fmt.Errorf("table %s field %s: invalid size %q", table, f.Name, value)
+				}
+				size = value
+			case "type":
+				col.Type = value
+			case "commit_ts":
+				col.AllowCommitTimestamp = true
+			case "index", "unique":
+				idx, ok := indexes[value]
+				if !ok {
+					idx = &IndexSchema{Name: value}
+					indexes[value] = idx
+					schema.Indexes = append(schema.Indexes, IndexSchema{Name: value})
+				}
+				idx.Columns = append(idx.Columns, name)
+				idx.Unique = idx.Unique || key == "unique"
+			default:
+				return TableSchema{}, fmt.Errorf("table %s field %s: unknown repokit tag option %q", table, f.Name, key)
+			}
+		}
+		if col.Type == "" && requireTypes {
+			return TableSchema{}, fmt.Errorf("table %s field %s: no Spanner type for Go type %s, set one with repokit:\"type=...\"", table, f.Name, f.GoType)
+		}
+		col.Type = strings.ReplaceAll(col.Type, "(MAX)", "("+size+")")
+		schema.Columns = append(schema.Columns, col)
+	}
+	for i := range schema.Indexes {
+		schema.Indexes[i] = *indexes[schema.Indexes[i].Name]
+	}
+	if len(schema.PrimaryKey) == 0 {
+		schema.PrimaryKey = pk
+	}
+	return schema, nil
+}
+
+// goColumnTypes maps Go types to Spanner column types. The bool is true when
+// the Go type can hold NULL.
+var goColumnTypes = map[string]struct {
+	spannerType string
+	nullable    bool
+}{
+	"string":              {"STRING(MAX)", false},
+	"spanner.NullString":  {"STRING(MAX)", true},
+	"int":                 {"INT64", false},
+	"int64":               {"INT64", false},
+	"int32":               {"INT64", false},
+	"spanner.NullInt64":   {"INT64", true},
+	"bool":                {"BOOL", false},
+	"spanner.NullBool":    {"BOOL", true},
+	"float64":             {"FLOAT64", false},
+	"spanner.NullFloat64": {"FLOAT64", true},
+	"float32":             {"FLOAT32", false},
+	"spanner.NullFloat32": {"FLOAT32", true},
+	"time.Time":           {"TIMESTAMP", false},
+	"spanner.NullTime":    {"TIMESTAMP", true},
+	"civil.Date":          {"DATE", false},
+	"spanner.NullDate":    {"DATE", true},
+	"big.Rat":             {"NUMERIC", false},
+	"spanner.NullNumeric": {"NUMERIC", true},
+	"spanner.NullJSON":    {"JSON", true},
+	"[]byte":              {"BYTES(MAX)", true},
+}
+
+// columnSchema derives the column named name from a Go type. The type is
+// left empty if the Go type has no default mapping.
+func columnSchema(name, goType string) (ColumnSchema, error) {
+	if err := validateIdentifier(name); err != nil {
+		return ColumnSchema{}, err
+	}
+	col := ColumnSchema{Name: name}
+	if m, ok := goColumnTypes[goType]; ok {
+		col.Type, col.NotNull = m.spannerType, !m.nullable
+		return col, nil
+	}
+	// Pointers and slices can hold NULL.
+	if elem, ok := strings.CutPrefix(goType, "*"); ok {
+		if m, ok := goColumnTypes[elem]; ok {
+			col.Type = m.spannerType
+		}
+		return col, nil
+	}
+	if elem, ok := strings.CutPrefix(goType, "[]"); ok {
+		elem = strings.TrimPrefix(elem, "*")
+		if m, ok := goColumnTypes[elem]; ok {
+			col.Type = "ARRAY<" + m.spannerType + ">"
+		}
+	}
+	return col, nil
+}
+
+// DDL returns the CREATE TABLE statement of s followed by the CREATE INDEX
+// statements of its indexes. Statements have no trailing semicolon.
+func (s TableSchema) DDL() ([]string, error) {
+	table, err := quoteIdentifier(s.Name)
+	if err != nil {
+		return nil, fmt.Errorf("table: %w", err)
+	}
+	if len(s.PrimaryKey) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key: tag fields with repokit:\"pk\" or configure WithPrimaryKeys", s.Name)
+	}
+
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	idents := newIdentifierPolicy(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", table)
+	for _, c := range s.Columns {
+		name, err := quoteIdentifier(c.Name)
+		if err != nil {
+			return nil, fmt.Errorf("table %s column: %w", s.Name, err)
+		}
+		fmt.Fprintf(&b, "\t%s %s", name, c.Type)
+		if c.NotNull {
+			b.WriteString(" NOT NULL")
+		}
+		if c.AllowCommitTimestamp {
+			b.WriteString(" OPTIONS (allow_commit_timestamp = true)")
+		}
+		b.WriteString(",\n")
+	}
+
+	pk, err := idents.joinColumns(s.PrimaryKey)
+	if err != nil {
+		return nil, fmt.Errorf("primary key: %w", err)
+	}
+	fmt.Fprintf(&b, ") PRIMARY KEY (%s)", pk)
+
+	if s.InterleaveIn != "" {
+		parent, err := quoteIdentifier(s.InterleaveIn)
+		if err != nil {
+			return nil, fmt.Errorf("table %s parent: %w", s.Name, err)
+		}
+		fmt.Fprintf(&b, ",\n  INTERLEAVE IN PARENT %s", parent)
+		if s.OnDeleteCascade {
+			b.WriteString(" ON DELETE CASCADE")
+		}
+	}
+	if p := s.RowDeletionPolicy; p != nil {
+		column, err := idents.column(p.Column)
+		if err != nil {
+			return nil, fmt.Errorf("row deletion policy: %w", err)
+		}
+		if p.Days <= 0 {
+			return nil, fmt.Errorf("table %s row deletion policy needs a positive number of days", s.Name)
+		}
+		fmt.Fprintf(&b, ",\n  ROW DELETION POLICY (OLDER_THAN(%s, INTERVAL %d DAY))", column, p.Days)
+	}
+
+	statements := []string{b.String()}
+	for _, idx := range s.Indexes {
+		name, err := quoteIdentifier(idx.Name)
+		if err != nil {
+			return nil, fmt.Errorf("table %s index: %w", s.Name, err)
+		}
+		cols, err := idents.joinColumns(idx.Columns)
+		if err != nil {
+			return nil, fmt.Errorf("index %s: %w", idx.Name, err)
+		}
+		unique := ""
+		if idx.Unique {
+			unique = "UNIQUE "
+		}
+		statements = append(statements, fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, name, table, cols))
+	}
+	return statements, nil
+}
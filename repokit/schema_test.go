@@ -0,0 +1,120 @@
+package repokit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/spansql"
+)
+
+type schemaInvoice struct {
+	CustomerID string             `spanner:"customer_id" repokit:"pk,size=36"`
+	InvoiceID  int64              `spanner:"invoice_id" repokit:"pk"`
+	Status     string             `spanner:"status" repokit:"size=16,index=InvoicesByStatus"`
+	Number     spanner.NullString `spanner:"number" repokit:"unique=InvoicesByNumber"`
+	Total      *float64           `spanner:"total"`
+	Lines      []string           `spanner:"lines"`
+	Attributes spanner.NullJSON   `spanner:"attributes"`
+	Reference  customString       `spanner:"reference" repokit:"type=STRING(64),notnull"`
+	CreatedAt  time.Time          `spanner:"created_at" repokit:"commit_ts,index=InvoicesByStatus"`
+	Ignored    string
+	internal   string
+}
+
+type customString string
+
+func TestSchemaDDL(t *testing.T) {
+	schema, err := SchemaOf[schemaInvoice]("Invoices", TableOptions{
+		InterleaveIn:      "Customers",
+		OnDeleteCascade:   true,
+		RowDeletionPolicy: &RowDeletionPolicy{Column: "created_at", Days: 90},
+	})
+	if err != nil {
+		t.Fatalf("SchemaOf: %v", err)
+	}
+	got, err := schema.DDL()
+	if err != nil {
+		t.Fatalf("DDL: %v", err)
+	}
+	want := []string{
+		"CREATE TABLE `Invoices` (\n" +
+			"\t`customer_id` STRING(36) NOT NULL,\n" +
+			"\t`invoice_id` INT64 NOT NULL,\n" +
+			"\t`status` STRING(16) NOT NULL,\n" +
+			"\t`number` STRING(MAX),\n" +
+			"\t`total` FLOAT64,\n" +
+			"\t`lines` ARRAY<STRING(MAX)>,\n" +
+			"\t`attributes` JSON,\n" +
+			"\t`reference` STRING(64) NOT NULL,\n" +
+			"\t`created_at` TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),\n" +
+			") PRIMARY KEY (`customer_id`, `invoice_id`),\n" +
+			"  INTERLEAVE IN PARENT `Customers` ON DELETE CASCADE,\n" +
+			"  ROW DELETION POLICY (OLDER_THAN(`created_at`, INTERVAL 90 DAY))",
+		"CREATE INDEX `InvoicesByStatus` ON `Invoices` (`status`, `created_at`)",
+		"CREATE UNIQUE INDEX `InvoicesByNumber` ON `Invoices` (`number`)",
+	}
+	if strings.Join(got, ";\n") != strings.Join(want, ";\n") {
+		t.Fatalf("DDL =\n%s\nwant\n%s", strings.Join(got, ";\n"), strings.Join(want, ";\n"))
+	}
+	parent := "CREATE TABLE Customers (customer_id STRING(36) NOT NULL) PRIMARY KEY (customer_id)"
+	if _, err := spansql.ParseDDL("generated", parent+";\n"+strings.Join(got, ";\n")); err != nil {
+		t.Fatalf("generated DDL does not parse: %v", err)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	type noKey struct {
+		Name string `spanner:"name"`
+	}
+	type unknownType struct {
+		ID    int64          `spanner:"id" repokit:"pk"`
+		Props map[string]int `spanner:"props"`
+	}
+	type badOption struct {
+		ID int64 `spanner:"id" repokit:"primary"`
+	}
+	type duplicate struct {
+		ID    int64 `spanner:"id" repokit:"pk"`
+		Other int64 `spanner:"ID"`
+	}
+
+	if schema, err := SchemaOf[noKey]("T", TableOptions{}); err != nil {
+		t.Fatalf("SchemaOf: %v", err)
+	} else if _, err := schema.DDL(); err == nil {
+		t.Fatal("DDL accepted a table without primary key")
+	}
+	if schema, err := SchemaOf[noKey]("T", TableOptions{PrimaryKey: []string{"id"}}); err != nil {
+		t.Fatalf("SchemaOf: %v", err)
+	} else if _, err := schema.DDL(); err == nil {
+		t.Fatal("DDL accepted a primary key column that is not a field")
+	}
+	if _, err := SchemaOf[unknownType]("T", TableOptions{}); err == nil {
+		t.Fatal("SchemaOf accepted a field without Spanner type")
+	}
+	if _, err := SchemaOf[badOption]("T", TableOptions{}); err == nil {
+		t.Fatal("SchemaOf accepted an unknown tag option")
+	}
+	if _, err := SchemaOf[duplicate]("T", TableOptions{}); err == nil {
+		t.Fatal("SchemaOf accepted a column mapped twice")
+	}
+}
+
+func TestBuilderDDL(t *testing.T) {
+	type key struct {
+		CustomerID string `spanner:"customer_id"`
+		InvoiceID  int64  `spanner:"invoice_id"`
+	}
+	got, err := NewSpannerRepositoryBuilder[schemaInvoice, key]().
+		WithTableName("Invoices").
+		WithPrimaryKeys([]string{"invoice_id", "customer_id"}).
+		WithRowDeletionPolicy("created_at", 7).
+		DDL()
+	if err != nil {
+		t.Fatalf("DDL: %v", err)
+	}
+	if !strings.Contains(got[0], ") PRIMARY KEY (`invoice_id`, `customer_id`),\n  ROW DELETION POLICY (OLDER_THAN(`created_at`, INTERVAL 7 DAY))") {
+		t.Fatalf("DDL = %s, want the builder's primary key and row deletion policy", got[0])
+	}
+}
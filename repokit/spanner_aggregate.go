@@ -0,0 +1,396 @@
+package repokit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// Aggregate describes an aggregate expression used by GroupBy.
+// Alias is the output column name and must match a `spanner` tag
+// (or field name) on the bucket type.
+type Aggregate struct {
+	Func   string
+	Column string
+	Alias  string
+}
+
+// CountAs counts the rows of each group into alias.
+func CountAs(alias string) Aggregate {
+	return Aggregate{Func: "COUNT", Column: "*", Alias: alias}
+}
+
+// SumAs sums column for each group into alias.
+func SumAs(column, alias string) Aggregate {
+	return Aggregate{Func: "SUM", Column: column, Alias: alias}
+}
+
+// MinAs computes the minimum of column for each group into alias.
+func MinAs(column, alias string) Aggregate {
+	return Aggregate{Func: "MIN", Column: column, Alias: alias}
+}
+
+// MaxAs computes the maximum of column for each group into alias.
+func MaxAs(column, alias string) Aggregate {
+	return Aggregate{Func: "MAX", Column: column, Alias: alias}
+}
+
+// AvgAs computes the average of column for each group into alias.
+func AvgAs(column, alias string) Aggregate {
+	return Aggregate{Func: "AVG", Column: column, Alias: alias}
+}
+
+// aggregateFuncs lists the aggregate functions accepted in an Aggregate.
+var aggregateFuncs = map[string]bool{"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true}
+
+// expression renders the aggregate as "FUNC(column) AS alias", validating
+// the function name and quoting the column and alias.
+func (a Aggregate) expression(idents identifierPolicy) (string, error) {
+	if !aggregateFuncs[a.Func] {
+		return "", fmt.Errorf("unsupported aggregate function %q", a.Func)
+	}
+	alias, err := quoteIdentifier(a.Alias)
+	if err != nil {
+		return "", err
+	}
+	if a.Func == "COUNT" && a.Column == "*" {
+		return fmt.Sprintf("COUNT(*) AS %s", alias), nil
+	}
+	column, err := idents.column(a.Column)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s(%s) AS %s", a.Func, column, alias), nil
+}
+
+// buildAggregateStatement builds "SELECT FUNC(column) FROM table [WHERE ...]".
+// column is "*" for COUNT(*).
+func (r *SpannerRepository[T, K]) buildAggregateStatement(fn, column string, criteria Criteria) (spanner.Statement, error) {
+	arg := "*"
+	if column != "*" {
+		var err error
+		if arg, err = r.idents.column(column); err != nil {
+			return spanner.Statement{}, err
+		}
+	}
+
+	params := map[string]interface{}{}
+	where, err := buildCriteriaClause(criteria, params, r.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	return spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s(%s) FROM %s%s", fn, arg, r.table, where),
+		Params: params,
+	}, nil
+}
+
+// queryScalar runs stmt and decodes the first column of the single result row into dest.
+func queryScalar(ctx context.Context, q queryer, stmt spanner.Statement, opts spanner.QueryOptions, dest interface{}) error {
+	iter := q.QueryWithOptions(ctx, stmt, opts)
+	defer iter.Stop()
+
+	row, err := iter.Next()
+	if err != nil {
+		return err
+	}
+	return row.Column(0, dest)
+}
+
+// aggregate runs a single-row aggregate function and decodes it into dest.
+// newQueryer is called on every attempt, since a single-use transaction
+// cannot run a second query.
+func (r *SpannerRepository[T, K]) aggregate(
+	ctx context.Context,
+	op *operation,
+	newQueryer func() queryer,
+	fn, column string,
+	criteria Criteria,
+	dest interface{},
+) error {
+	stmt, err := r.buildAggregateStatement(fn, column, criteria)
+	if err != nil {
+		return err
+	}
+	op.setStatement(stmt)
+	return op.retry(ctx, func(ctx context.Context) error {
+		return queryScalar(ctx, newQueryer(), stmt, op.opts.queryOptions(), dest)
+	})
+}
+
+// Count returns the number of rows in the table.
+func (r *SpannerRepository[T, K]) Count(ctx context.Context, opts ...CallOption) (int64, error) {
+	var count int64
+	err := r.scalar(ctx, "Count", "COUNT", "*", Criteria{}, &count, opts)
+	return count, err
+}
+
+// CountWhere returns the number of rows matching criteria.
+func (r *SpannerRepository[T, K]) CountWhere(ctx context.Context, criteria Criteria, opts ...CallOption) (int64, error) {
+	var count int64
+	err := r.scalar(ctx, "CountWhere", "COUNT", "*", criteria, &count, opts)
+	return count, err
+}
+
+// CountTx is the transactional version of Count.
+func (r *SpannerRepository[T, K]) CountTx(tx Transaction, opts ...CallOption) (int64, error) {
+	var count int64
+	err := r.scalarTx(tx, "CountTx", "COUNT", "*", Criteria{}, &count, opts)
+	return count, err
+}
+
+// CountWhereTx is the transactional version of CountWhere.
+func (r *SpannerRepository[T, K]) CountWhereTx(tx Transaction, criteria Criteria, opts ...CallOption) (int64, error) {
+	var count int64
+	err := r.scalarTx(tx, "CountWhereTx", "COUNT", "*", criteria, &count, opts)
+	return count, err
+}
+
+// scalar runs the aggregate fn outside a transaction under a span named name.
+func (r *SpannerRepository[T, K]) scalar(
+	ctx context.Context,
+	name, fn, column string,
+	criteria Criteria,
+	dest interface{},
+	opts []CallOption,
+) (err error) {
+	ctx, op := r.startOperation(ctx, name, opts)
+	defer func() { op.end(err) }()
+
+	single := func() queryer { return r.client.Single() }
+	return r.aggregate(ctx, op, single, fn, column, criteria, dest)
+}
+
+// scalarTx runs the aggregate fn inside tx under a span named name.
+func (r *SpannerRepository[T, K]) scalarTx(
+	tx Transaction,
+	name, fn, column string,
+	criteria Criteria,
+	dest interface{},
+	opts []CallOption,
+) (err error) {
+	ctx, op := r.startTxOperation(tx, name, opts)
+	defer func() { op.end(err) }()
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return err
+	}
+	txn := func() queryer { return stx.ReadWriteTransaction() }
+	return r.aggregate(ctx, op, txn, fn, column, criteria, dest)
+}
+
+// Sum computes SUM(column) over the rows matching criteria as a V, such as
+// int64 or float64, so no destination has to be declared. ok is false when
+// no row matches, since SUM is then NULL. Like GroupBy, Sum is a function
+// because Go methods cannot declare their own type parameters.
+//
+// Example:
+//
+//	total, ok, err := repokit.Sum[int64](repo, ctx, "amount",
+//	    repokit.Where(repokit.Eq("status", "PAID")))
+func Sum[V any, T any, K any](repo *SpannerRepository[T, K], ctx context.Context, column string, criteria Criteria, opts ...CallOption) (V, bool, error) {
+	return typedScalar[V](func(dest interface{}) error {
+		return repo.scalar(ctx, "Sum", "SUM", column, criteria, dest, opts)
+	})
+}
+
+// SumTx is the transactional version of Sum.
+func SumTx[V any, T any, K any](repo *SpannerRepository[T, K], tx Transaction, column string, criteria Criteria, opts ...CallOption) (V, bool, error) {
+	return typedScalar[V](func(dest interface{}) error {
+		return repo.scalarTx(tx, "SumTx", "SUM", column, criteria, dest, opts)
+	})
+}
+
+// Min computes MIN(column) over the rows matching criteria as a V, such as
+// int64, string or time.Time. See Sum for ok.
+func Min[V any, T any, K any](repo *SpannerRepository[T, K], ctx context.Context, column string, criteria Criteria, opts ...CallOption) (V, bool, error) {
+	return typedScalar[V](func(dest interface{}) error {
+		return repo.scalar(ctx, "Min", "MIN", column, criteria, dest, opts)
+	})
+}
+
+// MinTx is the transactional version of Min.
+func MinTx[V any, T any, K any](repo *SpannerRepository[T, K], tx Transaction, column string, criteria Criteria, opts ...CallOption) (V, bool, error) {
+	return typedScalar[V](func(dest interface{}) error {
+		return repo.scalarTx(tx, "MinTx", "MIN", column, criteria, dest, opts)
+	})
+}
+
+// Max computes MAX(column) over the rows matching criteria as a V. See Sum
+// for ok.
+func Max[V any, T any, K any](repo *SpannerRepository[T, K], ctx context.Context, column string, criteria Criteria, opts ...CallOption) (V, bool, error) {
+	return typedScalar[V](func(dest interface{}) error {
+		return repo.scalar(ctx, "Max", "MAX", column, criteria, dest, opts)
+	})
+}
+
+// MaxTx is the transactional version of Max.
+func MaxTx[V any, T any, K any](repo *SpannerRepository[T, K], tx Transaction, column string, criteria Criteria, opts ...CallOption) (V, bool, error) {
+	return typedScalar[V](func(dest interface{}) error {
+		return repo.scalarTx(tx, "MaxTx", "MAX", column, criteria, dest, opts)
+	})
+}
+
+// typedScalar runs an aggregate through run, decoding it into a *V so that
+// NULL is reported as ok == false rather than failing to decode.
+func typedScalar[V any](run func(dest interface{}) error) (V, bool, error) {
+	var value *V
+	if err := run(&value); err != nil || value == nil {
+		var zero V
+		return zero, false, err
+	}
+	return *value, true, nil
+}
+
+// buildGroupByStatement builds the GROUP BY query shared by GroupBy and GroupByTx.
+func (r *SpannerRepository[T, K]) buildGroupByStatement(
+	groupBy []string,
+	aggregates []Aggregate,
+	criteria Criteria,
+) (spanner.Statement, error) {
+	if len(groupBy) == 0 {
+		return spanner.Statement{}, fmt.Errorf("group by requires at least one column")
+	}
+
+	groupList, err := r.idents.joinColumns(groupBy)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+
+	selectList := make([]string, 0, len(aggregates)+1)
+	selectList = append(selectList, groupList)
+	for _, a := range aggregates {
+		expr, err := a.expression(r.idents)
+		if err != nil {
+			return spanner.Statement{}, err
+		}
+		selectList = append(selectList, expr)
+	}
+
+	params := map[string]interface{}{}
+	where, err := buildCriteriaClause(criteria, params, r.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+
+	// Buckets are sorted by the grouping columns unless criteria orders them.
+	orderBy := " ORDER BY " + groupList
+	if len(criteria.Orderings) > 0 {
+		if orderBy, err = buildOrderClause(criteria, r.idents); err != nil {
+			return spanner.Statement{}, err
+		}
+	}
+
+	return spanner.Statement{
+		SQL: fmt.Sprintf("SELECT %s FROM %s%s GROUP BY %s%s",
+			strings.Join(selectList, ", "), r.table, where, groupList, orderBy),
+		Params: params,
+	}, nil
+}
+
+// queryStructs decodes every row of stmt into a value of type S using row.ToStruct.
+func queryStructs[S any](ctx context.Context, q queryer, stmt spanner.Statement, opts spanner.QueryOptions) ([]S, error) {
+	iter := q.QueryWithOptions(ctx, stmt, opts)
+	defer iter.Stop()
+
+	var results []S
+	for {
+		row, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var s S
+		if err := row.ToStruct(&s); err != nil {
+			return nil, err
+		}
+		results = append(results, s)
+	}
+	return results, nil
+}
+
+// GroupBy groups the rows matching criteria by the groupBy columns and computes
+// the given aggregates for each group. Each result row is decoded into a bucket
+// of type B whose `spanner` tags match the group columns and aggregate aliases.
+//
+// GroupBy is a function rather than a method because Go methods cannot declare
+// their own type parameters. B comes first so T and K are inferred from repo.
+//
+// Example:
+//
+//	type StatusTotals struct {
+//	    Status string `spanner:"status"`
+//	    Orders int64  `spanner:"orders"`
+//	    Amount int64  `spanner:"amount"`
+//	}
+//
+//	buckets, err := repokit.GroupBy[StatusTotals](repo, ctx,
+//	    []string{"status"},
+//	    []repokit.Aggregate{repokit.CountAs("orders"), repokit.SumAs("amount", "amount")},
+//	    repokit.Criteria{})
+func GroupBy[B any, T any, K any](
+	repo *SpannerRepository[T, K],
+	ctx context.Context,
+	groupBy []string,
+	aggregates []Aggregate,
+	criteria Criteria,
+	opts ...CallOption,
+) (buckets []B, err error) {
+	ctx, op := repo.startOperation(ctx, "GroupBy", opts)
+	defer func() { op.end(err) }()
+
+	stmt, err := repo.buildGroupByStatement(groupBy, aggregates, criteria)
+	if err != nil {
+		return nil, err
+	}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		buckets, err = queryStructs[B](ctx, repo.client.Single(), stmt, op.opts.queryOptions())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRows(len(buckets))
+	return buckets, nil
+}
+
+// GroupByTx is the transactional version of GroupBy.
+func GroupByTx[B any, T any, K any](
+	repo *SpannerRepository[T, K],
+	tx Transaction,
+	groupBy []string,
+	aggregates []Aggregate,
+	criteria Criteria,
+	opts ...CallOption,
+) (buckets []B, err error) {
+	ctx, op := repo.startTxOperation(tx, "GroupByTx", opts)
+	defer func() { op.end(err) }()
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := repo.buildGroupByStatement(groupBy, aggregates, criteria)
+	if err != nil {
+		return nil, err
+	}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		buckets, err = queryStructs[B](ctx, stx.ReadWriteTransaction(), stmt, op.opts.queryOptions())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRows(len(buckets))
+	return buckets, nil
+}
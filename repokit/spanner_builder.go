@@ -1,60 +1,366 @@
 package repokit
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
 	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SpannerRepositoryBuilder provides a builder for constructing SpannerRepository instances.
-type SpannerRepositoryBuilder[T any] struct {
-	client      *spanner.Client
-	tableName   string
-	primaryKeys []string
-	rowMapper   func(*spanner.Row) (T, error)
-	mutation    func(entity T) *spanner.Mutation
+type SpannerRepositoryBuilder[T any, K any] struct {
+	client         *spanner.Client
+	tableName      string
+	primaryKeys    []string
+	columns        []string
+	rowMapper      func(*spanner.Row) (T, error)
+	mutation       func(entity T) *spanner.Mutation
+	update         func(entity T) *spanner.Mutation
+	keyExtractor   func(entity T) K
+	strict         bool
+	tracerProvider trace.TracerProvider
+	metrics        Metrics
+	logger         *slog.Logger
+	slowQuery      time.Duration
+	paramLogMode   ParamLogMode
+	maskedColumns  []string
+	timeout        time.Duration
+	retry          RetryPolicy
+	cache          Cache[T]
+	batchWindow    time.Duration
+	maxBatch       int
+	table          TableOptions // interleaving and row deletion policy, for Schema
+	hooks          hooks[T, K]
 }
 
 // NewSpannerRepositoryBuilder initializes a new builder for SpannerRepository.
-func NewSpannerRepositoryBuilder[T any]() *SpannerRepositoryBuilder[T] {
-	return &SpannerRepositoryBuilder[T]{}
+// T is the entity type and K the primary key type.
+func NewSpannerRepositoryBuilder[T any, K any]() *SpannerRepositoryBuilder[T, K] {
+	return &SpannerRepositoryBuilder[T, K]{}
 }
 
 // WithClient sets the Cloud Spanner client.
-func (b *SpannerRepositoryBuilder[T]) WithClient(client *spanner.Client) *SpannerRepositoryBuilder[T] {
+func (b *SpannerRepositoryBuilder[T, K]) WithClient(client *spanner.Client) *SpannerRepositoryBuilder[T, K] {
 	b.client = client
 	return b
 }
 
 // WithTableName sets the table name.
-func (b *SpannerRepositoryBuilder[T]) WithTableName(table string) *SpannerRepositoryBuilder[T] {
+func (b *SpannerRepositoryBuilder[T, K]) WithTableName(table string) *SpannerRepositoryBuilder[T, K] {
 	b.tableName = table
 	return b
 }
 
 // WithPrimaryKeys sets the primary key columns (in schema order).
-func (b *SpannerRepositoryBuilder[T]) WithPrimaryKeys(keys []string) *SpannerRepositoryBuilder[T] {
+func (b *SpannerRepositoryBuilder[T, K]) WithPrimaryKeys(keys []string) *SpannerRepositoryBuilder[T, K] {
 	b.primaryKeys = keys
 	return b
 }
 
+// WithColumns sets the canonical column list of the table, in the order the
+// row mapper expects them. It is used whenever a caller passes no columns.
+// When not set, the list is derived from T's `spanner` tags.
+func (b *SpannerRepositoryBuilder[T, K]) WithColumns(columns []string) *SpannerRepositoryBuilder[T, K] {
+	b.columns = columns
+	return b
+}
+
 // WithRowMapper sets the row-to-entity mapper function.
-func (b *SpannerRepositoryBuilder[T]) WithRowMapper(mapper func(*spanner.Row) (T, error)) *SpannerRepositoryBuilder[T] {
+func (b *SpannerRepositoryBuilder[T, K]) WithRowMapper(mapper func(*spanner.Row) (T, error)) *SpannerRepositoryBuilder[T, K] {
 	b.rowMapper = mapper
 	return b
 }
 
 // WithMutation sets the entity-to-mutation builder function.
-func (b *SpannerRepositoryBuilder[T]) WithMutation(builder func(entity T) *spanner.Mutation) *SpannerRepositoryBuilder[T] {
+func (b *SpannerRepositoryBuilder[T, K]) WithMutation(builder func(entity T) *spanner.Mutation) *SpannerRepositoryBuilder[T, K] {
 	b.mutation = builder
 	return b
 }
 
+// WithUpdateMutation sets the mutation builder used by Update and UpdateTx.
+// Use it with spanner.Update to make updates of missing rows fail with
+// codes.NotFound. When not set, the WithMutation builder is used.
+func (b *SpannerRepositoryBuilder[T, K]) WithUpdateMutation(builder func(entity T) *spanner.Mutation) *SpannerRepositoryBuilder[T, K] {
+	b.update = builder
+	return b
+}
+
+// WithKeyExtractor sets the function returning the primary key of an entity.
+func (b *SpannerRepositoryBuilder[T, K]) WithKeyExtractor(extractor func(entity T) K) *SpannerRepositoryBuilder[T, K] {
+	b.keyExtractor = extractor
+	return b
+}
+
+// WithStrictColumns restricts every column name accepted by the repository
+// (select lists, criteria, aggregates) to the canonical column list (see
+// WithColumns) plus the primary key columns. Use it when column names may
+// come from untrusted input, such as an HTTP fields= parameter.
+func (b *SpannerRepositoryBuilder[T, K]) WithStrictColumns() *SpannerRepositoryBuilder[T, K] {
+	b.strict = true
+	return b
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to open a
+// span for every repository method. When not set, tracing is a no-op.
+func (b *SpannerRepositoryBuilder[T, K]) WithTracerProvider(tp trace.TracerProvider) *SpannerRepositoryBuilder[T, K] {
+	b.tracerProvider = tp
+	return b
+}
+
+// WithMetrics sets the Metrics receiving the latency, rows, mutations and
+// error class of every repository method. See NewOTelMetrics.
+func (b *SpannerRepositoryBuilder[T, K]) WithMetrics(metrics Metrics) *SpannerRepositoryBuilder[T, K] {
+	b.metrics = metrics
+	return b
+}
+
+// WithLogger enables structured query logging: every SQL statement the
+// repository runs is logged with its parameter names, redacted values,
+// duration and row count. Statements log at debug, failed ones at error.
+func (b *SpannerRepositoryBuilder[T, K]) WithLogger(logger *slog.Logger) *SpannerRepositoryBuilder[T, K] {
+	b.logger = logger
+	return b
+}
+
+// WithSlowQueryThreshold makes statements taking at least d log at warn.
+func (b *SpannerRepositoryBuilder[T, K]) WithSlowQueryThreshold(d time.Duration) *SpannerRepositoryBuilder[T, K] {
+	b.slowQuery = d
+	return b
+}
+
+// WithParamLogMode sets how parameter values are logged. The default is
+// ParamsRedacted.
+func (b *SpannerRepositoryBuilder[T, K]) WithParamLogMode(mode ParamLogMode) *SpannerRepositoryBuilder[T, K] {
+	b.paramLogMode = mode
+	return b
+}
+
+// WithMaskedColumns marks columns holding personal data. Parameters compared
+// with these columns, or named after them, are always logged as redacted.
+func (b *SpannerRepositoryBuilder[T, K]) WithMaskedColumns(columns ...string) *SpannerRepositoryBuilder[T, K] {
+	b.maskedColumns = append(b.maskedColumns, columns...)
+	return b
+}
+
+// WithDefaultTimeout bounds every repository call, retries included, to d.
+// WithTimeout overrides it per call.
+func (b *SpannerRepositoryBuilder[T, K]) WithDefaultTimeout(d time.Duration) *SpannerRepositoryBuilder[T, K] {
+	b.timeout = d
+	return b
+}
+
+// WithDefaultRetryPolicy sets how calls made outside a transaction are retried.
+// WithRetryPolicy overrides it per call. By default repokit does not retry on
+// top of the Spanner client.
+func (b *SpannerRepositoryBuilder[T, K]) WithDefaultRetryPolicy(policy RetryPolicy) *SpannerRepositoryBuilder[T, K] {
+	b.retry = policy
+	return b
+}
+
+// WithCache makes FindByID and Exists read through cache. FindByID caches
+// reads of the canonical column list by primary key, unless the key was
+// written while it read; Save, Update, Delete, Patch, UpdateIf and
+// SaveReturningKey invalidate the key once written, and SaveTx, UpdateTx,
+// DeleteTx, PatchTx and UpdateIfTx once their transaction has committed (see
+// SaveReturningKeyTx for its exception). Writes made by other processes, by
+// other DML or by commits that fail ambiguously are only seen once the entry
+// expires, so bound staleness with the cache's TTL. Cached entities are
+// shared between callers and must not be modified. See NewLRUCache.
+func (b *SpannerRepositoryBuilder[T, K]) WithCache(cache Cache[T]) *SpannerRepositoryBuilder[T, K] {
+	b.cache = cache
+	return b
+}
+
+// WithBatching coalesces concurrent FindByID calls of the canonical column
+// list: the keys requested within window are read together with one
+// FindByIDs call of at most maxBatch keys (no limit if maxBatch is zero or
+// less), and concurrent calls for the same key share a single read. It
+// trades up to window of added latency for fewer Spanner round trips, which
+// suits fan-out readers such as GraphQL resolvers. Batched reads use the
+// repository's default call options, with a 30 second timeout if none is
+// set, and are traced under the span of the first caller in the batch;
+// per-call options only bound how long a caller waits.
+func (b *SpannerRepositoryBuilder[T, K]) WithBatching(window time.Duration, maxBatch int) *SpannerRepositoryBuilder[T, K] {
+	b.batchWindow = window
+	b.maxBatch = maxBatch
+	return b
+}
+
+// WithBeforeSave registers a hook run on the entity before Save, Update,
+// SaveTx and UpdateTx build their mutation, after the entity's own
+// BeforeSave method (see BeforeSaver). The hook may modify the entity; an
+// error aborts the write.
+func (b *SpannerRepositoryBuilder[T, K]) WithBeforeSave(hook func(ctx context.Context, entity *T) error) *SpannerRepositoryBuilder[T, K] {
+	b.hooks.beforeSave = append(b.hooks.beforeSave, hook)
+	return b
+}
+
+// WithAfterSave registers a hook run once Save or Update has applied the
+// entity's mutation, or once SaveTx or UpdateTx has buffered it, after the
+// entity's own AfterSave method (see AfterSaver). Its error is returned to
+// the caller but does not undo a Save or Update, which has committed.
+func (b *SpannerRepositoryBuilder[T, K]) WithAfterSave(hook func(ctx context.Context, entity T) error) *SpannerRepositoryBuilder[T, K] {
+	b.hooks.afterSave = append(b.hooks.afterSave, hook)
+	return b
+}
+
+// WithAfterLoad registers a hook run on every entity read by the row mapper,
+// after the entity's own AfterLoad method (see AfterLoader). The hook may
+// modify the entity; an error aborts the read. Cached reads are not hooked
+// again.
+func (b *SpannerRepositoryBuilder[T, K]) WithAfterLoad(hook func(ctx context.Context, entity *T) error) *SpannerRepositoryBuilder[T, K] {
+	b.hooks.afterLoad = append(b.hooks.afterLoad, hook)
+	return b
+}
+
+// WithBeforeDelete registers a hook run on the key before Delete and DeleteTx
+// write their mutation. An error aborts the delete.
+func (b *SpannerRepositoryBuilder[T, K]) WithBeforeDelete(hook func(ctx context.Context, key K) error) *SpannerRepositoryBuilder[T, K] {
+	b.hooks.beforeDelete = append(b.hooks.beforeDelete, hook)
+	return b
+}
+
+// WithInterleave records that the table is interleaved in parent, with ON
+// DELETE CASCADE if onDeleteCascade is set. It is only used by Schema and DDL.
+func (b *SpannerRepositoryBuilder[T, K]) WithInterleave(parent string, onDeleteCascade bool) *SpannerRepositoryBuilder[T, K] {
+	b.table.InterleaveIn = parent
+	b.table.OnDeleteCascade = onDeleteCascade
+	return b
+}
+
+// WithRowDeletionPolicy records that rows are deleted once the timestamp in
+// column is more than days days old. It is only used by Schema and DDL.
+func (b *SpannerRepositoryBuilder[T, K]) WithRowDeletionPolicy(column string, days int) *SpannerRepositoryBuilder[T, K] {
+	b.table.RowDeletionPolicy = &RowDeletionPolicy{Column: column, Days: days}
+	return b
+}
+
+// Schema returns the TableSchema of the configured table, derived from T's
+// struct tags (see NewTableSchema), the primary keys and the WithInterleave
+// and WithRowDeletionPolicy settings. It does not need a client.
+func (b *SpannerRepositoryBuilder[T, K]) Schema() (TableSchema, error) {
+	if b.tableName == "" {
+		return TableSchema{}, fmt.Errorf("table name is required")
+	}
+	opts := b.table
+	opts.PrimaryKey = b.primaryKeys
+	return SchemaOf[T](b.tableName, opts)
+}
+
+// DDL returns the statements creating the configured table and its indexes.
+// See Schema.
+func (b *SpannerRepositoryBuilder[T, K]) DDL() ([]string, error) {
+	schema, err := b.Schema()
+	if err != nil {
+		return nil, err
+	}
+	return schema.DDL()
+}
+
 // Build creates the SpannerRepository with the provided configuration.
-func (b *SpannerRepositoryBuilder[T]) Build() *SpannerRepository[T] {
-	return &SpannerRepository[T]{
-		client:      b.client,
-		tableName:   b.tableName,
-		primaryKeys: b.primaryKeys,
-		rowMapper:   b.rowMapper,
-		mutation:    b.mutation,
+// It returns an error if a required option is missing, if the table or primary
+// key names are not valid identifiers, or if the key type K does not match the
+// configured primary key columns.
+func (b *SpannerRepositoryBuilder[T, K]) Build() (*SpannerRepository[T, K], error) {
+	switch {
+	case b.client == nil:
+		return nil, fmt.Errorf("spanner client is required")
+	case b.tableName == "":
+		return nil, fmt.Errorf("table name is required")
+	case b.rowMapper == nil:
+		return nil, fmt.Errorf("row mapper is required")
+	case b.mutation == nil:
+		return nil, fmt.Errorf("mutation builder is required")
+	case b.keyExtractor == nil:
+		return nil, fmt.Errorf("key extractor is required")
+	}
+
+	table, err := quoteIdentifier(b.tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range b.primaryKeys {
+		if err := validateIdentifier(k); err != nil {
+			return nil, fmt.Errorf("table %s primary key: %w", b.tableName, err)
+		}
+	}
+	keys, err := newKeyCodec[K](b.primaryKeys)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", b.tableName, err)
+	}
+
+	columns := b.columns
+	if len(columns) == 0 {
+		columns = taggedColumns(reflect.TypeOf((*T)(nil)).Elem())
+	}
+	for _, c := range columns {
+		if err := validateIdentifier(c); err != nil {
+			return nil, fmt.Errorf("table %s column: %w", b.tableName, err)
+		}
+	}
+
+	update := b.update
+	if update == nil {
+		update = b.mutation
+	}
+
+	var idents identifierPolicy
+	if b.strict {
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("table %s: strict columns require WithColumns or spanner tags on the entity", b.tableName)
+		}
+		allowed := make([]string, 0, len(columns)+len(b.primaryKeys))
+		allowed = append(allowed, columns...)
+		allowed = append(allowed, b.primaryKeys...)
+		idents = newIdentifierPolicy(allowed)
+	}
+
+	inst := newInstruments()
+	inst.tracer = newTracer(b.tracerProvider)
+	if b.metrics != nil {
+		inst.metrics = b.metrics
+	}
+	if b.logger != nil {
+		masked := make(map[string]bool, len(b.maskedColumns))
+		for _, c := range b.maskedColumns {
+			masked[strings.ToLower(c)] = true
+		}
+		inst.queries = &queryLogger{logger: b.logger, slow: b.slowQuery, mode: b.paramLogMode, masked: masked}
+	}
+
+	hooks := entityHooks[T, K]()
+	hooks.beforeSave = append(hooks.beforeSave, b.hooks.beforeSave...)
+	hooks.afterSave = append(hooks.afterSave, b.hooks.afterSave...)
+	hooks.afterLoad = append(hooks.afterLoad, b.hooks.afterLoad...)
+	hooks.beforeDelete = append(hooks.beforeDelete, b.hooks.beforeDelete...)
+
+	repo := &SpannerRepository[T, K]{
+		client:       b.client,
+		tableName:    b.tableName,
+		primaryKeys:  b.primaryKeys,
+		columns:      columns,
+		rowMapper:    b.rowMapper,
+		mutation:     b.mutation,
+		update:       update,
+		keyExtractor: b.keyExtractor,
+		keys:         keys,
+		table:        table,
+		idents:       idents,
+		inst:         inst,
+		defaults:     callOptions{timeout: b.timeout, retry: b.retry},
+		cache:        b.cache,
+		hooks:        hooks,
+	}
+	if b.cache != nil {
+		repo.versions = &cacheVersions{}
+	}
+	if b.batchWindow > 0 {
+		repo.batch = newBatcher(repo, b.batchWindow, b.maxBatch)
 	}
+	return repo, nil
 }
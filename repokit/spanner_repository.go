@@ -4,246 +4,494 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
 	"strings"
 
 	"cloud.google.com/go/spanner"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
 )
 
 // SpannerRepository provides a generic, type-safe repository implementation
 // for Cloud Spanner. It supports CRUD, transactional operations, pagination,
 // and key-returning inserts.
 //
-// T represents the domain entity mapped to a Spanner table.
-type SpannerRepository[T any] struct {
-	client      *spanner.Client
-	tableName   string
-	primaryKeys []string
-	rowMapper   func(*spanner.Row) (T, error)
-	mutation    func(entity T) *spanner.Mutation
-}
-
-// buildColumnList builds a comma-separated list of columns for a SELECT statement.
-// If no columns are provided, it defaults to "*".
-func buildColumnList(columns []string) string {
-	if len(columns) == 0 {
-		return "*"
-	}
-	return strings.Join(columns, ", ")
+// T represents the domain entity mapped to a Spanner table and K its primary
+// key type (see SpannerRepositoryBuilder.Build for the accepted key shapes).
+type SpannerRepository[T any, K any] struct {
+	client       *spanner.Client
+	tableName    string
+	primaryKeys  []string
+	columns      []string // canonical column list, used when a caller passes none
+	rowMapper    func(*spanner.Row) (T, error)
+	mutation     func(entity T) *spanner.Mutation
+	update       func(entity T) *spanner.Mutation
+	keyExtractor func(entity T) K
+	keys         *keyCodec[K]
+	table        string // quoted table name used in SQL text
+	idents       identifierPolicy
+	inst         instruments
+	defaults     callOptions    // timeout and retry policy set on the builder
+	cache        Cache[T]       // nil when caching is disabled
+	versions     *cacheVersions // invalidation counters of cache keys, with cache
+	batch        *batcher[T, K] // nil when batching is disabled
+	hooks        hooks[T, K]
 }
 
-// buildWhereClause builds a WHERE clause using the provided keys in the form "col = @col".
-func buildWhereClause(keys []string) string {
-	parts := make([]string, len(keys))
-	for i, k := range keys {
-		parts[i] = fmt.Sprintf("%s = @%s", k, k)
-	}
-	return strings.Join(parts, " AND ")
+// queryer is implemented by every Spanner transaction type able to run a
+// query, so read helpers can serve both single-use and read-write transactions.
+type queryer interface {
+	QueryWithOptions(ctx context.Context, statement spanner.Statement, opts spanner.QueryOptions) *spanner.RowIterator
 }
 
-// structToMap converts a struct (or pointer to struct) into a map[string]interface{},
-// using the `spanner` tag if present, otherwise the field name in lowercase.
-func structToMap(key interface{}) (map[string]interface{}, error) {
-	v := reflect.ValueOf(key)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	if v.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("key precisa ser struct, recebido: %T", key)
+// spannerTx asserts that tx was created by a SpannerTransactionManager.
+func spannerTx(tx Transaction) (*SpannerTransaction, error) {
+	stx, ok := tx.(*SpannerTransaction)
+	if !ok {
+		return nil, fmt.Errorf("invalid transaction type")
 	}
+	return stx, nil
+}
 
-	t := v.Type()
-	result := make(map[string]interface{}, v.NumField())
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		name := field.Tag.Get("spanner")
-		if name == "" {
-			name = strings.ToLower(field.Name)
-		}
-		result[name] = v.Field(i).Interface()
+// startOperation opens the span of a repository method and resolves its call options.
+func (r *SpannerRepository[T, K]) startOperation(ctx context.Context, name string, opts []CallOption) (context.Context, *operation) {
+	return startOperation(ctx, r.inst, r.tableName, name, r.defaults, opts)
+}
+
+// startTxOperation opens the span of a transactional repository method as a
+// child of the transaction's span. The call inherits the transaction's
+// priority and is never retried by repokit: an aborted transaction is retried
+// as a whole by the transaction manager.
+func (r *SpannerRepository[T, K]) startTxOperation(tx Transaction, name string, opts []CallOption) (context.Context, *operation) {
+	ctx := context.Background()
+	if tx != nil {
+		ctx = tx.Context()
 	}
-	return result, nil
+	base := r.defaults
+	base.retry = RetryPolicy{}
+	if stx, ok := tx.(*SpannerTransaction); ok {
+		base.priority = stx.opts.priority
+	}
+	return startOperation(ctx, r.inst, r.tableName, name, base, opts)
 }
 
 // Client returns the underlying Spanner client.
-func (r *SpannerRepository[T]) Client() *spanner.Client {
+func (r *SpannerRepository[T, K]) Client() *spanner.Client {
 	return r.client
 }
 
-// RowMapper exposes the rowMapper function used to convert a spanner.Row into an entity.
-func (r *SpannerRepository[T]) RowMapper(row *spanner.Row) (T, error) {
-	return r.rowMapper(row)
+// Columns returns the canonical column list of the table.
+func (r *SpannerRepository[T, K]) Columns() []string {
+	return r.columns
 }
 
-// Mutation exposes the mutation builder function for the repository.
-func (r *SpannerRepository[T]) Mutation(entity T) *spanner.Mutation {
-	return r.mutation(entity)
+// selectColumns returns columns, or the canonical column list if columns is empty.
+func (r *SpannerRepository[T, K]) selectColumns(columns []string) []string {
+	if len(columns) == 0 {
+		return r.columns
+	}
+	return columns
 }
 
-// Single executes a custom SQL query expected to return a single row.
-// Returns the mapped entity, a boolean indicating existence, and any error encountered.
-func (r *SpannerRepository[T]) Single(ctx context.Context, sql string, params map[string]interface{}) (T, bool, error) {
-	var entity T
-	stmt := spanner.Statement{SQL: sql, Params: params}
+// mapRows maps every row of iter with the row mapper and the AfterLoad
+// hooks. It stops iter.
+func (r *SpannerRepository[T, K]) mapRows(ctx context.Context, iter *spanner.RowIterator) ([]T, error) {
+	var results []T
+	err := iter.Do(func(row *spanner.Row) error {
+		entity, err := r.loadRow(ctx, row)
+		if err != nil {
+			return err
+		}
+		results = append(results, entity)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-	iter := r.client.Single().Query(ctx, stmt)
+// mapFirstRow maps the first row of iter like mapRows, reporting whether
+// there was one. It stops iter.
+func (r *SpannerRepository[T, K]) mapFirstRow(ctx context.Context, iter *spanner.RowIterator) (T, bool, error) {
 	defer iter.Stop()
 
+	var entity T
 	row, err := iter.Next()
+	if errors.Is(err, iterator.Done) {
+		return entity, false, nil
+	}
 	if err != nil {
-		if errors.Is(err, iterator.Done) {
-			return entity, false, nil
-		}
 		return entity, false, err
 	}
-
-	entity, err = r.rowMapper(row)
+	entity, err = r.loadRow(ctx, row)
 	if err != nil {
 		return entity, false, err
 	}
-
 	return entity, true, nil
 }
 
-// FindByID fetches a single entity by its primary key.
-func (r *SpannerRepository[T]) FindByID(ctx context.Context, key interface{}, columns []string) (T, bool, error) {
-	var entity T
+// apply writes m outside a transaction, retrying per the call's retry policy.
+func (r *SpannerRepository[T, K]) apply(ctx context.Context, op *operation, m *spanner.Mutation) error {
+	return op.retry(ctx, func(ctx context.Context) error {
+		_, err := r.client.Apply(ctx, []*spanner.Mutation{m}, op.opts.applyOptions()...)
+		return err
+	})
+}
+
+// keyString returns a string identifying key, used to index cached and
+// batched reads.
+func (r *SpannerRepository[T, K]) keyString(key K) string {
+	return r.tableName + r.keys.spannerKey(key).String()
+}
+
+// cached returns the cached read of key, if caching is enabled and the
+// entry is present.
+func (r *SpannerRepository[T, K]) cached(key K) (CacheEntry[T], bool) {
+	if r.cache == nil {
+		return CacheEntry[T]{}, false
+	}
+	return r.cache.Get(r.keyString(key))
+}
+
+// cacheVersion returns the invalidation count of key, taken before reading
+// the row to cache.
+func (r *SpannerRepository[T, K]) cacheVersion(key K) uint64 {
+	return r.versions.of(r.keyString(key)).Load()
+}
 
-	params, err := structToMap(key)
+// cacheRead caches entry, read for key, unless key was invalidated since
+// version was taken: the read may then predate the write.
+func (r *SpannerRepository[T, K]) cacheRead(key K, version uint64, entry CacheEntry[T]) {
+	id := r.keyString(key)
+	counter := r.versions.of(id)
+	if counter.Load() != version {
+		return
+	}
+	r.cache.Set(id, entry)
+	// An invalidation between the check and Set may have deleted first.
+	if counter.Load() != version {
+		r.cache.Delete(id)
+	}
+}
+
+// invalidate drops the cached read of key, if caching is enabled, and makes
+// reads of key in flight skip the cache.
+func (r *SpannerRepository[T, K]) invalidate(key K) {
+	if r.cache != nil {
+		id := r.keyString(key)
+		r.versions.of(id).Add(1)
+		r.cache.Delete(id)
+	}
+}
+
+// invalidateOnCommit drops the cached read of key once tx has committed, so
+// concurrent readers cannot cache the value the transaction is replacing
+// before it is visible.
+func (r *SpannerRepository[T, K]) invalidateOnCommit(tx *SpannerTransaction, key K) {
+	if r.cache != nil {
+		tx.onCommit(func() { r.invalidate(key) })
+	}
+}
+
+// RowMapper exposes the rowMapper function used to convert a spanner.Row into an entity.
+// It does not run the AfterLoad hooks.
+func (r *SpannerRepository[T, K]) RowMapper(row *spanner.Row) (T, error) {
+	return r.rowMapper(row)
+}
+
+// Mutation exposes the mutation builder function for the repository.
+// It does not run the BeforeSave hooks.
+func (r *SpannerRepository[T, K]) Mutation(entity T) *spanner.Mutation {
+	return r.mutation(entity)
+}
+
+// KeyOf returns the primary key of entity using the configured key extractor.
+func (r *SpannerRepository[T, K]) KeyOf(entity T) K {
+	return r.keyExtractor(entity)
+}
+
+// Single executes a custom SQL query expected to return a single row.
+// Returns the mapped entity, a boolean indicating existence, and any error encountered.
+func (r *SpannerRepository[T, K]) Single(ctx context.Context, sql string, params map[string]interface{}, opts ...CallOption) (entity T, found bool, err error) {
+	ctx, op := r.startOperation(ctx, "Single", opts)
+	defer func() { op.end(err) }()
+
+	stmt := spanner.Statement{SQL: sql, Params: params}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		entity, found, err = r.mapFirstRow(ctx, r.client.Single().QueryWithOptions(ctx, stmt, op.opts.queryOptions()))
+		return err
+	})
 	if err != nil {
 		return entity, false, err
 	}
+	op.setFound(found)
+	return entity, found, nil
+}
 
-	where := buildWhereClause(r.primaryKeys)
-	stmt := spanner.Statement{
-		SQL:    fmt.Sprintf("SELECT %s FROM %s WHERE %s", buildColumnList(columns), r.tableName, where),
-		Params: params,
+// FindByID fetches a single entity by its primary key.
+// If columns is empty, the repository's canonical column list is selected.
+// Returns the mapped entity, a boolean indicating existence, and any error encountered.
+func (r *SpannerRepository[T, K]) FindByID(ctx context.Context, key K, columns []string, opts ...CallOption) (entity T, found bool, err error) {
+	ctx, op := r.startOperation(ctx, "FindByID", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	// Only reads of the canonical column list are cached.
+	cacheable := r.cache != nil && len(columns) == 0
+	var version uint64
+	if cacheable {
+		if entry, ok := r.cached(key); ok {
+			op.setCacheHit(true)
+			op.setFound(entry.Found)
+			return entry.Entity, entry.Found, nil
+		}
+		op.setCacheHit(false)
+		version = r.cacheVersion(key)
 	}
 
-	iter := r.client.Single().Query(ctx, stmt)
-	defer iter.Stop()
+	if r.batch != nil && len(columns) == 0 {
+		op.setBatched()
+		entity, found, err = r.batch.load(ctx, key)
+		if err != nil {
+			return entity, false, err
+		}
+		op.setFound(found)
+		if cacheable {
+			r.cacheRead(key, version, CacheEntry[T]{Entity: entity, Found: found})
+		}
+		return entity, found, nil
+	}
 
-	row, err := iter.Next()
+	columnList, err := r.idents.buildColumnList(r.selectColumns(columns))
+	if err != nil {
+		return entity, false, err
+	}
+	params := map[string]interface{}{}
+	where, err := buildCriteriaClause(r.keys.criteria(key), params, r.idents)
 	if err != nil {
 		return entity, false, err
 	}
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s%s", columnList, r.table, where),
+		Params: params,
+	}
 
-	entity, err = r.rowMapper(row)
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		entity, found, err = r.mapFirstRow(ctx, r.client.Single().QueryWithOptions(ctx, stmt, op.opts.queryOptions()))
+		return err
+	})
 	if err != nil {
 		return entity, false, err
 	}
-	return entity, true, nil
+	op.setFound(found)
+	if cacheable {
+		r.cacheRead(key, version, CacheEntry[T]{Entity: entity, Found: found})
+	}
+	return entity, found, nil
 }
 
 // FindAll retrieves all rows from the table.
-func (r *SpannerRepository[T]) FindAll(ctx context.Context, columns []string) ([]T, error) {
+// If columns is empty, the repository's canonical column list is selected.
+func (r *SpannerRepository[T, K]) FindAll(ctx context.Context, columns []string, opts ...CallOption) (results []T, err error) {
+	ctx, op := r.startOperation(ctx, "FindAll", opts)
+	defer func() { op.end(err) }()
+
+	columnList, err := r.idents.buildColumnList(r.selectColumns(columns))
+	if err != nil {
+		return nil, err
+	}
 	stmt := spanner.Statement{
-		SQL: fmt.Sprintf("SELECT %s FROM %s", buildColumnList(columns), r.tableName),
+		SQL: fmt.Sprintf("SELECT %s FROM %s", columnList, r.table),
 	}
 
-	iter := r.client.Single().Query(ctx, stmt)
-	defer iter.Stop()
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		results, err = r.mapRows(ctx, r.client.Single().QueryWithOptions(ctx, stmt, op.opts.queryOptions()))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRows(len(results))
+	return results, nil
+}
 
-	var results []T
-	for {
-		row, err := iter.Next()
-		if errors.Is(err, iterator.Done) {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+// FindWhere fetches the entities matching criteria, in the criteria's order.
+// If columns is empty, the repository's canonical column list is selected.
+func (r *SpannerRepository[T, K]) FindWhere(ctx context.Context, criteria Criteria, columns []string, opts ...CallOption) (results []T, err error) {
+	ctx, op := r.startOperation(ctx, "FindWhere", opts)
+	defer func() { op.end(err) }()
 
-		entity, err := r.rowMapper(row)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, entity)
+	stmt, err := r.buildFindWhereStatement(criteria, columns)
+	if err != nil {
+		return nil, err
+	}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		results, err = r.mapRows(ctx, r.client.Single().QueryWithOptions(ctx, stmt, op.opts.queryOptions()))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	op.setRows(len(results))
 	return results, nil
 }
 
+// FindWhereTx is the transactional version of FindWhere.
+func (r *SpannerRepository[T, K]) FindWhereTx(tx Transaction, criteria Criteria, columns []string, opts ...CallOption) (results []T, err error) {
+	ctx, op := r.startTxOperation(tx, "FindWhereTx", opts)
+	defer func() { op.end(err) }()
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := r.buildFindWhereStatement(criteria, columns)
+	if err != nil {
+		return nil, err
+	}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		results, err = r.mapRows(ctx, stx.ReadWriteTransaction().QueryWithOptions(ctx, stmt, op.opts.queryOptions()))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRows(len(results))
+	return results, nil
+}
+
+// buildFindWhereStatement builds the SELECT used by FindWhere and FindWhereTx.
+func (r *SpannerRepository[T, K]) buildFindWhereStatement(criteria Criteria, columns []string) (spanner.Statement, error) {
+	columnList, err := r.idents.buildColumnList(r.selectColumns(columns))
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	params := map[string]interface{}{}
+	where, err := buildCriteriaClause(criteria, params, r.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	orderBy, err := buildOrderClause(criteria, r.idents)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	return spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s%s%s", columnList, r.table, where, orderBy),
+		Params: params,
+	}, nil
+}
+
 // FindByIDs fetches multiple entities by their primary keys.
-func (r *SpannerRepository[T]) FindByIDs(ctx context.Context, keys []interface{}, columns []string) ([]T, error) {
-	var spannerKeys []spanner.Key
-	for _, k := range keys {
-		params, err := structToMap(k)
-		if err != nil {
-			return nil, err
-		}
-		values := make([]interface{}, len(r.primaryKeys))
-		for i, col := range r.primaryKeys {
-			values[i] = params[col]
-		}
-		spannerKeys = append(spannerKeys, spanner.Key(values))
+// If columns is empty, the repository's canonical column list is read.
+func (r *SpannerRepository[T, K]) FindByIDs(ctx context.Context, keys []K, columns []string, opts ...CallOption) (results []T, err error) {
+	ctx, op := r.startOperation(ctx, "FindByIDs", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(len(keys))
+
+	if len(keys) == 0 {
+		return nil, nil
 	}
 
-	keySet := spanner.KeySetFromKeys(spannerKeys...)
+	columns = r.selectColumns(columns)
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s has no column list: configure WithColumns or spanner tags", r.tableName)
+	}
+	if err := r.idents.checkColumns(columns); err != nil {
+		return nil, err
+	}
 
-	iter := r.client.Single().Read(ctx, r.tableName, keySet, columns)
-	defer iter.Stop()
+	spannerKeys := make([]spanner.Key, len(keys))
+	for i, k := range keys {
+		spannerKeys[i] = r.keys.spannerKey(k)
+	}
 
-	var results []T
-	for {
-		row, err := iter.Next()
-		if errors.Is(err, iterator.Done) {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+	keySet := spanner.KeySetFromKeys(spannerKeys...)
 
-		entity, err := r.rowMapper(row)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, entity)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		results, err = r.mapRows(ctx, r.client.Single().ReadWithOptions(ctx, r.tableName, keySet, columns, op.opts.readOptions()))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	op.setRows(len(results))
 	return results, nil
 }
 
-// Save performs an upsert (insert or update) using a mutation.
-func (r *SpannerRepository[T]) Save(ctx context.Context, entity T) error {
-	m := r.mutation(entity)
-	_, err := r.client.Apply(ctx, []*spanner.Mutation{m})
-	return err
-}
+// Save performs an upsert (insert or update) using a mutation. An error of
+// an AfterSave hook is returned once the mutation has committed.
+func (r *SpannerRepository[T, K]) Save(ctx context.Context, entity T, opts ...CallOption) (err error) {
+	ctx, op := r.startOperation(ctx, "Save", opts)
+	defer func() { op.end(err) }()
 
-// Update updates an entity in the table.
-func (r *SpannerRepository[T]) Update(ctx context.Context, entity T) error {
+	if err := r.hooks.runBeforeSave(ctx, &entity); err != nil {
+		return err
+	}
 	m := r.mutation(entity)
-	_, err := r.client.Apply(ctx, []*spanner.Mutation{m})
-	return err
+	op.setMutations(1)
+	defer r.invalidate(r.keyExtractor(entity))
+	if err := r.apply(ctx, op, m); err != nil {
+		return err
+	}
+	return r.hooks.runAfterSave(ctx, entity)
 }
 
-// Delete removes an entity from the table by primary key.
-func (r *SpannerRepository[T]) Delete(ctx context.Context, key interface{}) error {
-	params, err := structToMap(key)
-	if err != nil {
+// Update updates an entity in the table. An error of an AfterSave hook is
+// returned once the mutation has committed.
+func (r *SpannerRepository[T, K]) Update(ctx context.Context, entity T, opts ...CallOption) (err error) {
+	ctx, op := r.startOperation(ctx, "Update", opts)
+	defer func() { op.end(err) }()
+
+	if err := r.hooks.runBeforeSave(ctx, &entity); err != nil {
 		return err
 	}
-
-	values := make([]interface{}, len(r.primaryKeys))
-	for i, k := range r.primaryKeys {
-		values[i] = params[k]
+	m := r.update(entity)
+	op.setMutations(1)
+	defer r.invalidate(r.keyExtractor(entity))
+	if err := r.apply(ctx, op, m); err != nil {
+		return err
 	}
+	return r.hooks.runAfterSave(ctx, entity)
+}
+
+// Delete removes an entity from the table by primary key.
+func (r *SpannerRepository[T, K]) Delete(ctx context.Context, key K, opts ...CallOption) (err error) {
+	ctx, op := r.startOperation(ctx, "Delete", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
 
-	m := spanner.Delete(r.tableName, spanner.Key(values))
-	_, err = r.client.Apply(ctx, []*spanner.Mutation{m})
-	return err
+	if err := r.hooks.runBeforeDelete(ctx, key); err != nil {
+		return err
+	}
+	m := spanner.Delete(r.tableName, r.keys.spannerKey(key))
+	op.setMutations(1)
+	defer r.invalidate(key)
+	return r.apply(ctx, op, m)
 }
 
 // SaveReturningKey inserts a row using DML and returns the generated primary key.
-func (r *SpannerRepository[T]) SaveReturningKey(
+// When dest is a *K, the cached read of the returned key is invalidated, so a
+// cached absence of the row is not served after the insert.
+func (r *SpannerRepository[T, K]) SaveReturningKey(
 	ctx context.Context,
 	insertSQL string,
 	params map[string]interface{},
 	dest interface{},
-) error {
-	_, err := r.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+	opts ...CallOption,
+) (err error) {
+	ctx, op := r.startOperation(ctx, "SaveReturningKey", opts)
+	defer func() { op.end(err) }()
+
+	_, err = r.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		op.attempt()
 		stmt := spanner.Statement{SQL: insertSQL, Params: params}
-		iter := txn.Query(ctx, stmt)
+		op.setStatement(stmt)
+		iter := txn.QueryWithOptions(ctx, stmt, op.opts.queryOptions())
 		defer iter.Stop()
 
 		row, err := iter.Next()
@@ -255,20 +503,40 @@ func (r *SpannerRepository[T]) SaveReturningKey(
 			return err
 		}
 		return nil
-	})
-	return err
+	}, op.opts.transactionOptions())
+	if err != nil {
+		return err
+	}
+	r.invalidateReturnedKey(dest)
+	return nil
+}
+
+// invalidateReturnedKey invalidates the key returned into dest by
+// SaveReturningKey or SaveReturningKeyTx, if dest holds a K.
+func (r *SpannerRepository[T, K]) invalidateReturnedKey(dest interface{}) {
+	if key, ok := dest.(*K); ok {
+		r.invalidate(*key)
+	}
 }
 
-// SaveReturningKeyTx is the transactional version of SaveReturningKey.
-func (r *SpannerRepository[T]) SaveReturningKeyTx(
+// SaveReturningKeyTx is the transactional version of SaveReturningKey. txn
+// is not a Transaction, so the returned key is invalidated when the statement
+// runs rather than on commit; a FindByID made before the commit may cache the
+// row's absence again.
+func (r *SpannerRepository[T, K]) SaveReturningKeyTx(
 	ctx context.Context,
 	txn *spanner.ReadWriteTransaction,
 	insertSQL string,
 	params map[string]interface{},
 	dest interface{},
-) error {
+	opts ...CallOption,
+) (err error) {
+	ctx, op := r.startOperation(ctx, "SaveReturningKeyTx", opts)
+	defer func() { op.end(err) }()
+
 	stmt := spanner.Statement{SQL: insertSQL, Params: params}
-	iter := txn.Query(ctx, stmt)
+	op.setStatement(stmt)
+	iter := txn.QueryWithOptions(ctx, stmt, op.opts.queryOptions())
 	defer iter.Stop()
 
 	row, err := iter.Next()
@@ -279,110 +547,184 @@ func (r *SpannerRepository[T]) SaveReturningKeyTx(
 	if err := row.Column(0, dest); err != nil {
 		return err
 	}
+	r.invalidateReturnedKey(dest)
 	return nil
 }
 
 // Exists checks whether an entity exists by primary key.
-func (r *SpannerRepository[T]) Exists(ctx context.Context, key interface{}) (bool, error) {
-	_, found, err := r.FindByID(ctx, key, r.primaryKeys)
-	return found, err
+// It reads only the key columns and does not invoke the row mapper.
+func (r *SpannerRepository[T, K]) Exists(ctx context.Context, key K, opts ...CallOption) (exists bool, err error) {
+	ctx, op := r.startOperation(ctx, "Exists", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	if entry, ok := r.cached(key); ok {
+		op.setCacheHit(true)
+		op.setFound(entry.Found)
+		return entry.Found, nil
+	}
+
+	err = op.retry(ctx, func(ctx context.Context) error {
+		_, err := r.client.Single().ReadRowWithOptions(ctx, r.tableName, r.keys.spannerKey(key), r.primaryKeys, op.opts.readOptions())
+		exists = err == nil
+		if spanner.ErrCode(err) == codes.NotFound {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	op.setFound(exists)
+	return exists, nil
 }
 
 // SaveTx performs an upsert inside a transaction.
-func (r *SpannerRepository[T]) SaveTx(tx Transaction, entity T) error {
-	stx, ok := tx.(*SpannerTransaction)
-	if !ok {
-		return fmt.Errorf("invalid transaction type")
+func (r *SpannerRepository[T, K]) SaveTx(tx Transaction, entity T, opts ...CallOption) (err error) {
+	ctx, op := r.startTxOperation(tx, "SaveTx", opts)
+	defer func() { op.end(err) }()
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return err
+	}
+	if err := r.hooks.runBeforeSave(ctx, &entity); err != nil {
+		return err
 	}
 	m := r.mutation(entity)
-	return stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{m})
+	op.setMutations(1)
+	if err := stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{m}); err != nil {
+		return err
+	}
+	r.invalidateOnCommit(stx, r.keyExtractor(entity))
+	return r.hooks.runAfterSave(ctx, entity)
 }
 
 // DeleteTx removes an entity inside a transaction.
-func (r *SpannerRepository[T]) DeleteTx(tx Transaction, key interface{}) error {
-	stx, ok := tx.(*SpannerTransaction)
-	if !ok {
-		return fmt.Errorf("invalid transaction type")
-	}
-	params, err := structToMap(key)
+func (r *SpannerRepository[T, K]) DeleteTx(tx Transaction, key K, opts ...CallOption) (err error) {
+	ctx, op := r.startTxOperation(tx, "DeleteTx", opts)
+	defer func() { op.end(err) }()
+	op.setKeys(1)
+
+	stx, err := spannerTx(tx)
 	if err != nil {
 		return err
 	}
-
-	values := make([]interface{}, len(r.primaryKeys))
-	for i, k := range r.primaryKeys {
-		values[i] = params[k]
+	if err := r.hooks.runBeforeDelete(ctx, key); err != nil {
+		return err
 	}
 
-	m := spanner.Delete(r.tableName, spanner.Key(values))
-	return stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{m})
+	m := spanner.Delete(r.tableName, r.keys.spannerKey(key))
+	op.setMutations(1)
+	if err := stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{m}); err != nil {
+		return err
+	}
+	r.invalidateOnCommit(stx, key)
+	return nil
 }
 
 // UpdateTx updates an entity inside a transaction.
-func (r *SpannerRepository[T]) UpdateTx(tx Transaction, entity T) error {
-	stx, ok := tx.(*SpannerTransaction)
-	if !ok {
-		return fmt.Errorf("invalid transaction type")
+func (r *SpannerRepository[T, K]) UpdateTx(tx Transaction, entity T, opts ...CallOption) (err error) {
+	ctx, op := r.startTxOperation(tx, "UpdateTx", opts)
+	defer func() { op.end(err) }()
+
+	stx, err := spannerTx(tx)
+	if err != nil {
+		return err
 	}
-	m := r.mutation(entity)
-	return stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{m})
+	if err := r.hooks.runBeforeSave(ctx, &entity); err != nil {
+		return err
+	}
+	m := r.update(entity)
+	op.setMutations(1)
+	if err := stx.ReadWriteTransaction().BufferWrite([]*spanner.Mutation{m}); err != nil {
+		return err
+	}
+	r.invalidateOnCommit(stx, r.keyExtractor(entity))
+	return r.hooks.runAfterSave(ctx, entity)
 }
 
-// FindPage fetches entities with cursor-based pagination.
-// pageToken should be the last seen primary key from a previous page.
-func (r *SpannerRepository[T]) FindPage(
+// FindPage fetches entities with cursor-based pagination, in primary key order.
+// pageSize must be positive. pageToken should be the token returned by the previous page (nil for the first
+// page): the last seen primary key value, or a []interface{} holding every key
+// value when the primary key has several columns.
+func (r *SpannerRepository[T, K]) FindPage(
 	ctx context.Context,
 	pageSize int,
 	pageToken interface{},
 	columns []string,
-) ([]T, interface{}, error) {
-	var stmt spanner.Statement
-
-	if pageToken == nil || pageToken == "" {
-		stmt = spanner.Statement{
-			SQL: fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s LIMIT @limit`,
-				buildColumnList(columns), r.tableName, strings.Join(r.primaryKeys, ", ")),
-			Params: map[string]interface{}{
-				"limit": pageSize,
-			},
-		}
-	} else {
-		stmt = spanner.Statement{
-			SQL: fmt.Sprintf(`SELECT %s FROM %s WHERE %s > @pageToken ORDER BY %s LIMIT @limit`,
-				buildColumnList(columns), r.tableName, r.primaryKeys[0],
-				strings.Join(r.primaryKeys, ", ")),
-			Params: map[string]interface{}{
-				"pageToken": pageToken,
-				"limit":     pageSize,
-			},
-		}
+	opts ...CallOption,
+) (results []T, nextToken interface{}, err error) {
+	ctx, op := r.startOperation(ctx, "FindPage", opts)
+	defer func() { op.end(err) }()
+
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, nil, err
+	}
+	columnList, err := r.idents.buildColumnList(r.selectColumns(columns))
+	if err != nil {
+		return nil, nil, err
+	}
+	orderBy, err := r.idents.joinColumns(r.primaryKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := r.keys.pageTokenValues(pageToken)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	iter := r.client.Single().Query(ctx, stmt)
-	defer iter.Stop()
+	params := map[string]interface{}{"limit": pageSize}
+	where := ""
+	if after != nil {
+		where = " WHERE " + r.buildAfterKeyClause(after, params)
+	}
 
-	var results []T
-	var lastKey interface{}
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT %s FROM %s%s ORDER BY %s LIMIT @limit`,
+			columnList, r.table, where, orderBy),
+		Params: params,
+	}
 
-	for {
-		row, err := iter.Next()
-		if errors.Is(err, iterator.Done) {
-			break
-		}
-		if err != nil {
-			return nil, nil, err
-		}
+	op.setStatement(stmt)
+	err = op.retry(ctx, func(ctx context.Context) error {
+		results, err = r.mapRows(ctx, r.client.Single().QueryWithOptions(ctx, stmt, op.opts.queryOptions()))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	op.setRows(len(results))
 
-		entity, err := r.rowMapper(row)
-		if err != nil {
-			return nil, nil, err
-		}
-		results = append(results, entity)
+	if len(results) == 0 {
+		return results, nil, nil
+	}
+	return results, r.keys.pageToken(r.keyExtractor(results[len(results)-1])), nil
+}
 
-		if err := row.ColumnByName(r.primaryKeys[0], &lastKey); err != nil {
-			return nil, nil, err
-		}
+// buildAfterKeyClause builds a condition matching the rows whose primary key
+// sorts after the given key values, e.g. for (a, b):
+// (a > @p1) OR (a = @p1 AND b > @p2).
+func (r *SpannerRepository[T, K]) buildAfterKeyClause(after []interface{}, params map[string]interface{}) string {
+	keyColumns := make([]string, len(r.primaryKeys))
+	for i, k := range r.primaryKeys {
+		// primary keys are validated by Build, so quoting cannot fail
+		keyColumns[i], _ = quoteIdentifier(k)
 	}
 
-	return results, lastKey, nil
+	placeholders := make([]string, len(after))
+	for i, v := range after {
+		placeholders[i] = addParam(params, v)
+	}
+
+	alternatives := make([]string, len(after))
+	for i := range after {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = %s", keyColumns[j], placeholders[j]))
+		}
+		terms = append(terms, fmt.Sprintf("%s > %s", keyColumns[i], placeholders[i]))
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+	return strings.Join(alternatives, " OR ")
 }
@@ -1,8 +1,11 @@
 package repokit
 
 import (
-	"cloud.google.com/go/spanner"
 	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SpannerTransaction is a wrapper around Cloud Spanner's
@@ -11,8 +14,10 @@ import (
 // interact with transactions without depending directly on
 // the Spanner client API.
 type SpannerTransaction struct {
-	ctx context.Context
-	txn *spanner.ReadWriteTransaction
+	ctx         context.Context
+	txn         *spanner.ReadWriteTransaction
+	opts        callOptions // inherited by the repository calls made in the transaction
+	afterCommit []func()
 }
 
 // Context returns the context associated with this transaction.
@@ -29,24 +34,75 @@ func (t *SpannerTransaction) ReadWriteTransaction() *spanner.ReadWriteTransactio
 	return t.txn
 }
 
+// onCommit registers fn to run once the transaction has committed. It is not
+// run if the attempt is aborted and retried, rolled back or fails to commit.
+func (t *SpannerTransaction) onCommit(fn func()) {
+	t.afterCommit = append(t.afterCommit, fn)
+}
+
 // SpannerTransactionManager manages execution of functions within
 // a Cloud Spanner read-write transaction. It abstracts the Spanner
 // client so that application code only deals with the generic
 // Transaction interface.
 type SpannerTransactionManager struct {
-	client *spanner.Client
+	client   *spanner.Client
+	inst     instruments
+	defaults callOptions
+}
+
+var _ TransactionManager = (*SpannerTransactionManager)(nil)
+
+// TransactionManagerOption configures a SpannerTransactionManager.
+type TransactionManagerOption func(*SpannerTransactionManager)
+
+// WithTransactionTracerProvider sets the OpenTelemetry tracer provider used
+// to open a span for every transaction. When not set, tracing is a no-op.
+func WithTransactionTracerProvider(tp trace.TracerProvider) TransactionManagerOption {
+	return func(m *SpannerTransactionManager) {
+		m.inst.tracer = newTracer(tp)
+	}
+}
+
+// WithTransactionMetrics sets the Metrics receiving the duration, attempt
+// count and error class of every transaction. See NewOTelMetrics.
+func WithTransactionMetrics(metrics Metrics) TransactionManagerOption {
+	return func(m *SpannerTransactionManager) {
+		if metrics != nil {
+			m.inst.metrics = metrics
+		}
+	}
+}
+
+// WithTransactionTimeout sets the default total budget of a transaction,
+// covering every attempt Spanner makes after an abort. WithTimeout overrides
+// it per call.
+func WithTransactionTimeout(d time.Duration) TransactionManagerOption {
+	return func(m *SpannerTransactionManager) {
+		m.defaults.timeout = d
+	}
 }
 
 // NewSpannerTransactionManager creates a new transaction manager
 // bound to the provided Spanner client. The manager is responsible
 // for running functions inside read-write transactions.
-func NewSpannerTransactionManager(client *spanner.Client) *SpannerTransactionManager {
-	return &SpannerTransactionManager{client: client}
+func NewSpannerTransactionManager(client *spanner.Client, opts ...TransactionManagerOption) *SpannerTransactionManager {
+	m := &SpannerTransactionManager{client: client, inst: newInstruments()}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 // RunInTransaction executes the given function inside a read-write
 // transaction. If the function returns an error, the transaction is
-// rolled back; otherwise, it is committed.
+// rolled back; otherwise, it is committed. The function may run several
+// times if Spanner aborts the transaction; the number of attempts is
+// recorded on the transaction span.
+//
+// opts may set the transaction tag, which Spanner attaches to every statement
+// and to the commit, the priority of the commit, which is also the default
+// priority of the repository calls made inside the transaction, and the
+// total timeout of the transaction, retries included.
 //
 // Example:
 //
@@ -58,10 +114,22 @@ func NewSpannerTransactionManager(client *spanner.Client) *SpannerTransactionMan
 func (m *SpannerTransactionManager) RunInTransaction(
 	ctx context.Context,
 	fn func(transaction Transaction) error,
-) error {
-	_, err := m.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
-		tx := &SpannerTransaction{ctx: ctx, txn: txn}
-		return fn(tx)
-	})
-	return err
+	opts ...CallOption,
+) (err error) {
+	ctx, op := startOperation(ctx, m.inst, "", "RunInTransaction", m.defaults, opts)
+	defer func() { op.end(err) }()
+
+	var last *SpannerTransaction
+	_, err = m.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		op.attempt()
+		last = &SpannerTransaction{ctx: ctx, txn: txn, opts: op.opts}
+		return fn(last)
+	}, op.opts.transactionOptions())
+	if err != nil {
+		return err
+	}
+	for _, hook := range last.afterCommit {
+		hook()
+	}
+	return nil
 }
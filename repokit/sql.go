@@ -0,0 +1,118 @@
+package repokit
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidIdentifier is returned when a table or column name does not
+	// follow Spanner's identifier grammar.
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+
+	// ErrColumnNotAllowed is returned when a column is not part of the
+	// repository's column allowlist.
+	ErrColumnNotAllowed = errors.New("column not allowed")
+)
+
+// identifierPattern is Spanner's grammar for unquoted identifiers. Quoted
+// identifiers may contain more characters, but repokit deliberately accepts
+// only this subset so a quoted name can never escape its backticks.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// maxIdentifierLength is the maximum length of a Spanner identifier.
+const maxIdentifierLength = 128
+
+// validateIdentifier checks that name is a valid Spanner identifier.
+func validateIdentifier(name string) error {
+	if len(name) > maxIdentifierLength || !identifierPattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+	return nil
+}
+
+// quoteIdentifier validates name and wraps it in backticks, so reserved words
+// such as `Order` or `Group` can be used as table and column names.
+// Dotted names (schema.table) are validated and quoted part by part.
+func quoteIdentifier(name string) (string, error) {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if err := validateIdentifier(part); err != nil {
+			return "", err
+		}
+		parts[i] = "`" + part + "`"
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// identifierPolicy validates, allowlists and quotes the column names used to
+// build SQL statements. A nil allowlist accepts every valid identifier.
+type identifierPolicy struct {
+	allowed map[string]bool
+}
+
+// newIdentifierPolicy builds a policy restricted to columns. Spanner column
+// names are case-insensitive, so the allowlist is too.
+func newIdentifierPolicy(columns []string) identifierPolicy {
+	if len(columns) == 0 {
+		return identifierPolicy{}
+	}
+	allowed := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		allowed[strings.ToLower(c)] = true
+	}
+	return identifierPolicy{allowed: allowed}
+}
+
+// checkColumn validates name and checks it against the allowlist.
+func (p identifierPolicy) checkColumn(name string) error {
+	if err := validateIdentifier(name); err != nil {
+		return err
+	}
+	if p.allowed != nil && !p.allowed[strings.ToLower(name)] {
+		return fmt.Errorf("%w: %s", ErrColumnNotAllowed, name)
+	}
+	return nil
+}
+
+// column validates name and returns it quoted.
+func (p identifierPolicy) column(name string) (string, error) {
+	if err := p.checkColumn(name); err != nil {
+		return "", err
+	}
+	return quoteIdentifier(name)
+}
+
+// checkColumns validates every name in columns.
+func (p identifierPolicy) checkColumns(columns []string) error {
+	for _, c := range columns {
+		if err := p.checkColumn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinColumns validates and quotes columns into a comma-separated list.
+func (p identifierPolicy) joinColumns(columns []string) (string, error) {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		q, err := p.column(c)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// buildColumnList builds a comma-separated list of quoted columns for a SELECT
+// statement. If no columns are provided, it defaults to "*".
+func (p identifierPolicy) buildColumnList(columns []string) (string, error) {
+	if len(columns) == 0 {
+		return "*", nil
+	}
+	return p.joinColumns(columns)
+}
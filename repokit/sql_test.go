@@ -0,0 +1,86 @@
+package repokit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func FuzzQuoteIdentifier(f *testing.F) {
+	for _, seed := range []string{"users", "Order", "user_id", "a.b", "x`; DROP TABLE t; --", "", "1abc", "é"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted, err := quoteIdentifier(name)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidIdentifier) {
+				t.Fatalf("quoteIdentifier(%q) returned unexpected error %v", name, err)
+			}
+			return
+		}
+		for _, part := range strings.Split(quoted, ".") {
+			if len(part) < 3 || part[0] != '`' || part[len(part)-1] != '`' {
+				t.Fatalf("quoteIdentifier(%q) = %q, part %q is not quoted", name, quoted, part)
+			}
+			if !identifierPattern.MatchString(part[1 : len(part)-1]) {
+				t.Fatalf("quoteIdentifier(%q) = %q, part %q escapes the identifier grammar", name, quoted, part)
+			}
+		}
+	})
+}
+
+func FuzzCriteriaBuild(f *testing.F) {
+	f.Add("status", "PAID")
+	f.Add("status", "' OR 1=1 --")
+	f.Add("status = 'x' OR 1=1 --", "x")
+	f.Add("Group", "@p0")
+	f.Fuzz(func(t *testing.T, column, value string) {
+		params := map[string]interface{}{}
+		sql, err := Where(Eq(column, value), In(column, []string{value})).build(params, identifierPolicy{})
+		if err != nil {
+			if !errors.Is(err, ErrInvalidIdentifier) {
+				t.Fatalf("build(%q) returned unexpected error %v", column, err)
+			}
+			return
+		}
+		want := fmt.Sprintf("`%s` = @p0 AND `%s` IN UNNEST(@p1)", column, column)
+		if sql != want {
+			t.Fatalf("build(%q, %q) = %q, want %q", column, value, sql, want)
+		}
+		if params["p0"] != value {
+			t.Fatalf("build(%q, %q) bound %v, want the value as a parameter", column, value, params["p0"])
+		}
+	})
+}
+
+func FuzzBuildColumnList(f *testing.F) {
+	f.Add("user_id,email")
+	f.Add("email,(SELECT secret FROM admin)")
+	f.Add("user_id,password")
+	f.Fuzz(func(t *testing.T, fields string) {
+		columns := strings.Split(fields, ",")
+		policy := newIdentifierPolicy([]string{"user_id", "email"})
+
+		list, err := policy.buildColumnList(columns)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidIdentifier) && !errors.Is(err, ErrColumnNotAllowed) {
+				t.Fatalf("buildColumnList(%q) returned unexpected error %v", fields, err)
+			}
+			return
+		}
+		for _, c := range strings.Split(list, ", ") {
+			name := strings.ToLower(strings.Trim(c, "`"))
+			if name != "user_id" && name != "email" {
+				t.Fatalf("buildColumnList(%q) = %q, contains column %q outside the allowlist", fields, list, c)
+			}
+		}
+	})
+}
+
+func TestAggregateRejectsUnknownFunction(t *testing.T) {
+	_, err := Aggregate{Func: "SUM(1)); DELETE FROM t; --", Column: "amount", Alias: "total"}.expression(identifierPolicy{})
+	if err == nil {
+		t.Fatal("expected an error for an unknown aggregate function")
+	}
+}
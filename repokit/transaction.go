@@ -16,7 +16,8 @@ type Transaction interface {
 type TransactionManager interface {
 	// RunInTransaction executes the given function within a transaction.
 	// If the function returns an error, the transaction is rolled back.
-	// Otherwise, it is committed.
+	// Otherwise, it is committed. opts may set the transaction tag and
+	// priority.
 	//
 	// Example:
 	//
@@ -24,5 +25,5 @@ type TransactionManager interface {
 	//       // perform repository operations atomically
 	//       return nil
 	//   })
-	RunInTransaction(ctx context.Context, fn func(tx Transaction) error) error
+	RunInTransaction(ctx context.Context, fn func(tx Transaction) error, opts ...CallOption) error
 }
@@ -0,0 +1,287 @@
+package repokit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// ErrSchemaDrift is returned by Validate when the table in the database does
+// not match what the repository expects.
+var ErrSchemaDrift = errors.New("schema drift")
+
+// SchemaReport lists the differences between a table in the database and
+// what a repository expects of it. See SpannerRepository.Validate.
+type SchemaReport struct {
+	Table string
+	// TableMissing is set when the table does not exist. The other fields are
+	// then empty.
+	TableMissing bool
+	// MissingColumns are mapped by the repository but absent from the table.
+	MissingColumns []string
+	// UnmappedColumns exist in the table but are not mapped. They are only
+	// informational and do not make the report fail.
+	UnmappedColumns []string
+	// ColumnMismatches are mapped columns whose type or nullability cannot
+	// be read into the entity.
+	ColumnMismatches []ColumnMismatch
+	// PrimaryKey is set when the primary key columns or their order differ.
+	PrimaryKey *KeyMismatch
+	// IndexMismatches are the indexes declared with repokit tags that are
+	// missing (Actual is nil) or cover other columns.
+	IndexMismatches []KeyMismatch
+}
+
+// ColumnMismatch is a column whose definition differs from the entity field.
+type ColumnMismatch struct {
+	Column   string
+	Reason   string // "type" or "nullability"
+	Expected string
+	Actual   string
+}
+
+// KeyMismatch is a primary key or index whose columns differ.
+type KeyMismatch struct {
+	Name     string
+	Expected []string
+	Actual   []string
+}
+
+// OK reports whether the table matches the repository.
+func (r *SchemaReport) OK() bool {
+	return !r.TableMissing && len(r.MissingColumns) == 0 && len(r.ColumnMismatches) == 0 &&
+		r.PrimaryKey == nil && len(r.IndexMismatches) == 0
+}
+
+// Err returns nil if the report is OK, or an error wrapping ErrSchemaDrift
+// that describes the differences.
+func (r *SchemaReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrSchemaDrift, r)
+}
+
+// String describes the differences in one line per problem.
+func (r *SchemaReport) String() string {
+	if r.TableMissing {
+		return fmt.Sprintf("table %s does not exist", r.Table)
+	}
+	var lines []string
+	if len(r.MissingColumns) > 0 {
+		lines = append(lines, fmt.Sprintf("table %s is missing columns %s", r.Table, strings.Join(r.MissingColumns, ", ")))
+	}
+	for _, m := range r.ColumnMismatches {
+		lines = append(lines, fmt.Sprintf("table %s column %s %s: expected %s, got %s", r.Table, m.Column, m.Reason, m.Expected, m.Actual))
+	}
+	if m := r.PrimaryKey; m != nil {
+		lines = append(lines, fmt.Sprintf("table %s primary key: expected (%s), got (%s)",
+			r.Table, strings.Join(m.Expected, ", "), strings.Join(m.Actual, ", ")))
+	}
+	for _, m := range r.IndexMismatches {
+		if m.Actual == nil {
+			lines = append(lines, fmt.Sprintf("table %s is missing index %s", r.Table, m.Name))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("table %s index %s: expected (%s), got (%s)",
+			r.Table, m.Name, strings.Join(m.Expected, ", "), strings.Join(m.Actual, ", ")))
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("table %s matches", r.Table)
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Validate reads the table's definition from INFORMATION_SCHEMA and compares
+// it with the repository: the canonical columns must exist, the primary key
+// must have the configured columns in order, and the columns backed by a
+// `spanner`-tagged field must have the type and nullability that field can
+// read (see NewTableSchema; STRING and BYTES lengths are not compared).
+// Indexes declared with repokit tags must exist with the same columns. Table
+// and column names are matched case-insensitively, like Spanner does.
+//
+// Call it at startup to fail fast on schema drift instead of at query time.
+// The report is returned whenever the schema could be read; the error wraps
+// ErrSchemaDrift if the report is not OK.
+func (r *SpannerRepository[T, K]) Validate(ctx context.Context, opts ...CallOption) (report *SchemaReport, err error) {
+	ctx, op := r.startOperation(ctx, "Validate", opts)
+	defer func() { op.end(err) }()
+
+	expected, err := r.expectedSchema()
+	if err != nil {
+		return nil, err
+	}
+	var live TableSchema
+	err = op.retry(ctx, func(ctx context.Context) error {
+		live, err = r.loadSchema(ctx, op)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read schema of table %s: %w", r.tableName, err)
+	}
+	report = diffSchema(expected, live)
+	return report, report.Err()
+}
+
+// expectedSchema returns the table as the repository sees it. Columns are
+// the canonical column list; their type is left empty when no tagged field
+// of T backs them or its Go type has no default mapping.
+func (r *SpannerRepository[T, K]) expectedSchema() (TableSchema, error) {
+	expected := TableSchema{Name: r.tableName, PrimaryKey: r.primaryKeys}
+	fields := map[string]ColumnSchema{}
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() == reflect.Struct {
+		tagged, err := structSchema(r.tableName, reflectFields(t), TableOptions{PrimaryKey: r.primaryKeys}, false)
+		if err != nil {
+			return TableSchema{}, err
+		}
+		for _, c := range tagged.Columns {
+			fields[strings.ToLower(c.Name)] = c
+		}
+		expected.Indexes = tagged.Indexes
+	}
+	for _, name := range r.columns {
+		col, ok := fields[strings.ToLower(name)]
+		if !ok {
+			col = ColumnSchema{Name: name}
+		}
+		expected.Columns = append(expected.Columns, col)
+	}
+	return expected, nil
+}
+
+// loadSchema reads the columns, primary key and secondary index columns of
+// the repository's table. It returns a schema without columns if the table
+// does not exist.
+func (r *SpannerRepository[T, K]) loadSchema(ctx context.Context, op *operation) (TableSchema, error) {
+	live := TableSchema{Name: r.tableName}
+	params := map[string]interface{}{"table": r.tableName}
+
+	columns := spanner.Statement{
+		SQL: `SELECT COLUMN_NAME, SPANNER_TYPE, IS_NULLABLE FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = '' AND LOWER(TABLE_NAME) = LOWER(@table) ORDER BY ORDINAL_POSITION`,
+		Params: params,
+	}
+	err := r.client.Single().QueryWithOptions(ctx, columns, op.opts.queryOptions()).Do(func(row *spanner.Row) error {
+		var name, typ, nullable string
+		if err := row.Columns(&name, &typ, &nullable); err != nil {
+			return err
+		}
+		live.Columns = append(live.Columns, ColumnSchema{Name: name, Type: typ, NotNull: nullable == "NO"})
+		return nil
+	})
+	if err != nil || len(live.Columns) == 0 {
+		return live, err
+	}
+
+	indexColumns := spanner.Statement{
+		SQL: `SELECT INDEX_NAME, INDEX_TYPE, COLUMN_NAME FROM INFORMATION_SCHEMA.INDEX_COLUMNS
+WHERE TABLE_SCHEMA = '' AND LOWER(TABLE_NAME) = LOWER(@table) AND ORDINAL_POSITION IS NOT NULL
+ORDER BY INDEX_NAME, ORDINAL_POSITION`,
+		Params: params,
+	}
+	indexes := map[string]int{}
+	err = r.client.Single().QueryWithOptions(ctx, indexColumns, op.opts.queryOptions()).Do(func(row *spanner.Row) error {
+		var index, typ, column string
+		if err := row.Columns(&index, &typ, &column); err != nil {
+			return err
+		}
+		if typ == "PRIMARY_KEY" {
+			live.PrimaryKey = append(live.PrimaryKey, column)
+			return nil
+		}
+		i, ok := indexes[index]
+		if !ok {
+			i = len(live.Indexes)
+			indexes[index] = i
+			live.Indexes = append(live.Indexes, IndexSchema{Name: index})
+		}
+		live.Indexes[i].Columns = append(live.Indexes[i].Columns, column)
+		return nil
+	})
+	return live, err
+}
+
+// columnLength matches the length of STRING and BYTES types.
+var columnLength = regexp.MustCompile(`\(\s*(?i:MAX|\d+)\s*\)`)
+
+// baseType returns t without lengths, in upper case.
+func baseType(t string) string {
+	return strings.ToUpper(strings.ReplaceAll(columnLength.ReplaceAllString(t, ""), " ", ""))
+}
+
+// diffSchema compares the expected table with the live one. Names are
+// compared case-insensitively, like Spanner does.
+func diffSchema(expected, live TableSchema) *SchemaReport {
+	report := &SchemaReport{Table: expected.Name}
+	if len(live.Columns) == 0 {
+		report.TableMissing = true
+		return report
+	}
+
+	liveColumns := make(map[string]ColumnSchema, len(live.Columns))
+	for _, c := range live.Columns {
+		liveColumns[strings.ToLower(c.Name)] = c
+	}
+	mapped := make(map[string]bool, len(expected.Columns))
+	for _, want := range expected.Columns {
+		mapped[strings.ToLower(want.Name)] = true
+		got, ok := liveColumns[strings.ToLower(want.Name)]
+		if !ok {
+			report.MissingColumns = append(report.MissingColumns, want.Name)
+			continue
+		}
+		if want.Type == "" {
+			continue
+		}
+		if baseType(want.Type) != baseType(got.Type) {
+			report.ColumnMismatches = append(report.ColumnMismatches,
+				ColumnMismatch{Column: want.Name, Reason: "type", Expected: want.Type, Actual: got.Type})
+		}
+		// A nullable column is only a problem when the field cannot hold NULL.
+		if want.NotNull && !got.NotNull {
+			report.ColumnMismatches = append(report.ColumnMismatches,
+				ColumnMismatch{Column: want.Name, Reason: "nullability", Expected: "NOT NULL", Actual: "nullable"})
+		}
+	}
+	for _, c := range live.Columns {
+		if !mapped[strings.ToLower(c.Name)] {
+			report.UnmappedColumns = append(report.UnmappedColumns, c.Name)
+		}
+	}
+
+	if !sameColumns(expected.PrimaryKey, live.PrimaryKey) {
+		report.PrimaryKey = &KeyMismatch{Name: "PRIMARY_KEY", Expected: expected.PrimaryKey, Actual: live.PrimaryKey}
+	}
+	for _, want := range expected.Indexes {
+		m := KeyMismatch{Name: want.Name, Expected: want.Columns}
+		found := false
+		for _, got := range live.Indexes {
+			if strings.EqualFold(got.Name, want.Name) {
+				m.Actual, found = got.Columns, true
+				break
+			}
+		}
+		if !found || !sameColumns(want.Columns, m.Actual) {
+			report.IndexMismatches = append(report.IndexMismatches, m)
+		}
+	}
+	return report
+}
+
+// sameColumns reports whether a and b name the same columns in the same order.
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package repokit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestExpectedSchema(t *testing.T) {
+	repo := &SpannerRepository[schemaInvoice, spanner.Key]{
+		tableName:   "Invoices",
+		primaryKeys: []string{"customer_id", "invoice_id"},
+		columns:     []string{"customer_id", "invoice_id", "status", "reference", "legacy"},
+	}
+	expected, err := repo.expectedSchema()
+	if err != nil {
+		t.Fatalf("expectedSchema: %v", err)
+	}
+	want := []ColumnSchema{
+		{Name: "customer_id", Type: "STRING(36)", NotNull: true},
+		{Name: "invoice_id", Type: "INT64", NotNull: true},
+		{Name: "status", Type: "STRING(16)", NotNull: true},
+		{Name: "reference", Type: "STRING(64)", NotNull: true},
+		{Name: "legacy"},
+	}
+	if !reflect.DeepEqual(expected.Columns, want) {
+		t.Fatalf("columns = %+v, want %+v", expected.Columns, want)
+	}
+	if len(expected.Indexes) != 2 {
+		t.Fatalf("indexes = %+v, want the two tagged indexes", expected.Indexes)
+	}
+}
+
+func TestDiffSchema(t *testing.T) {
+	expected := TableSchema{
+		Name: "Invoices",
+		Columns: []ColumnSchema{
+			{Name: "customer_id", Type: "STRING(36)", NotNull: true},
+			{Name: "invoice_id", Type: "INT64", NotNull: true},
+			{Name: "status", Type: "STRING(16)", NotNull: true},
+			{Name: "total", Type: "FLOAT64"},
+			{Name: "lines", Type: "ARRAY<STRING(MAX)>"},
+			{Name: "number", Type: "STRING(MAX)"},
+		},
+		PrimaryKey: []string{"customer_id", "invoice_id"},
+		Indexes: []IndexSchema{
+			{Name: "InvoicesByStatus", Columns: []string{"status", "created_at"}},
+			{Name: "InvoicesByNumber", Columns: []string{"number"}},
+		},
+	}
+	live := TableSchema{
+		Name: "Invoices",
+		Columns: []ColumnSchema{
+			{Name: "Customer_ID", Type: "STRING(MAX)", NotNull: true},
+			{Name: "invoice_id", Type: "INT64", NotNull: true},
+			{Name: "status", Type: "STRING(16)"},
+			{Name: "total", Type: "NUMERIC", NotNull: true},
+			{Name: "lines", Type: "ARRAY<STRING(64)>"},
+			{Name: "note", Type: "STRING(MAX)"},
+		},
+		PrimaryKey: []string{"invoice_id", "customer_id"},
+		Indexes:    []IndexSchema{{Name: "InvoicesByStatus", Columns: []string{"status", "created_at"}}},
+	}
+
+	report := diffSchema(expected, live)
+	want := &SchemaReport{
+		Table:           "Invoices",
+		MissingColumns:  []string{"number"},
+		UnmappedColumns: []string{"note"},
+		ColumnMismatches: []ColumnMismatch{
+			{Column: "status", Reason: "nullability", Expected: "NOT NULL", Actual: "nullable"},
+			{Column: "total", Reason: "type", Expected: "FLOAT64", Actual: "NUMERIC"},
+		},
+		PrimaryKey: &KeyMismatch{
+			Name:     "PRIMARY_KEY",
+			Expected: []string{"customer_id", "invoice_id"},
+			Actual:   []string{"invoice_id", "customer_id"},
+		},
+		IndexMismatches: []KeyMismatch{{Name: "InvoicesByNumber", Expected: []string{"number"}}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("diffSchema = %+v, want %+v", report, want)
+	}
+	if err := report.Err(); !errors.Is(err, ErrSchemaDrift) || ClassifyError(err) != ErrorClassPrecondition {
+		t.Fatalf("Err() = %v, want a failed precondition wrapping ErrSchemaDrift", err)
+	}
+
+	// Unmapped columns alone do not fail validation.
+	expected.Columns, expected.Indexes = expected.Columns[:2], nil
+	live.PrimaryKey = expected.PrimaryKey
+	if report := diffSchema(expected, live); !report.OK() || report.Err() != nil {
+		t.Fatalf("diffSchema = %v, want OK", report)
+	}
+
+	if report := diffSchema(expected, TableSchema{Name: "Invoices"}); !report.TableMissing || report.OK() {
+		t.Fatalf("diffSchema without columns = %+v, want a missing table", report)
+	}
+}
@@ -0,0 +1,291 @@
+package repokittest
+
+import (
+	"context"
+	"math/rand"
+	"path"
+	"sync"
+
+	"github.com/Waelson/go-spanner-repo/repokit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Operation names understood by an Injector. Repository operations use the
+// name of the Repository method (e.g. "FindByID", "SaveTx").
+const (
+	// OpRunInTransaction fails RunInTransaction before the callback runs.
+	OpRunInTransaction = "RunInTransaction"
+	// OpCommit fails a transaction attempt after its callback succeeded.
+	// Injecting Aborted here makes the transaction manager retry the callback.
+	OpCommit = "Commit"
+	// OpExecuteStreamingSQL fails a query RPC of a client created by
+	// NewFaultySpannertestClient before it reaches the server.
+	OpExecuteStreamingSQL = "ExecuteStreamingSql"
+	// OpExecuteSQL fails a DML RPC of a client created by
+	// NewFaultySpannertestClient before it reaches the server.
+	OpExecuteSQL = "ExecuteSql"
+)
+
+// Aborted returns the error Spanner reports when a transaction is aborted
+// because of lock contention. Transaction managers retry the callback on it.
+func Aborted() error {
+	return status.Error(codes.Aborted, "repokittest: injected transaction abort")
+}
+
+// DeadlineExceeded returns a deadline-exceeded error.
+func DeadlineExceeded() error {
+	return status.Error(codes.DeadlineExceeded, "repokittest: injected deadline exceeded")
+}
+
+// Unavailable returns an unavailable error.
+func Unavailable() error {
+	return status.Error(codes.Unavailable, "repokittest: injected unavailable")
+}
+
+// SessionNotFound returns the error Spanner reports when a session was
+// garbage-collected on the server.
+func SessionNotFound() error {
+	return status.Error(codes.NotFound, "Session not found: repokittest injected session")
+}
+
+// faultRule fails the call-th call of op.
+type faultRule struct {
+	op   string
+	call int
+	err  error
+}
+
+// Injector decides which calls of a FaultyRepository or FaultyTransactionManager
+// fail. Calls are counted per operation, starting at 1. It is safe for
+// concurrent use.
+type Injector struct {
+	mu          sync.Mutex
+	calls       map[string]int
+	rules       []faultRule
+	rnd         *rand.Rand
+	probability float64
+	randomOps   map[string]bool
+	randomErrs  []error
+}
+
+// NewInjector creates an Injector that injects no faults until configured.
+func NewInjector() *Injector {
+	return &Injector{calls: make(map[string]int)}
+}
+
+// FailOn makes the call-th call (1-based) of op return err.
+//
+// Example:
+//
+//	inj := repokittest.NewInjector().
+//	    FailOn(repokittest.OpCommit, 1, repokittest.Aborted())
+func (i *Injector) FailOn(op string, call int, err error) *Injector {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append(i.rules, faultRule{op: op, call: call, err: err})
+	return i
+}
+
+// FailRandomly makes each call of ops (every operation if ops is empty) fail
+// with the given probability, returning one of errs. The choice is driven by
+// seed, so a failing run can be reproduced.
+func (i *Injector) FailRandomly(seed int64, probability float64, errs []error, ops ...string) *Injector {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rnd = rand.New(rand.NewSource(seed))
+	i.probability = probability
+	i.randomErrs = errs
+	i.randomOps = make(map[string]bool, len(ops))
+	for _, op := range ops {
+		i.randomOps[op] = true
+	}
+	return i
+}
+
+// Calls returns how many times op has been called so far.
+func (i *Injector) Calls(op string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.calls[op]
+}
+
+// next records a call of op and returns the error to inject, if any.
+func (i *Injector) next(op string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.calls[op]++
+	for _, r := range i.rules {
+		if r.op == op && r.call == i.calls[op] {
+			return r.err
+		}
+	}
+	if i.rnd != nil && len(i.randomErrs) > 0 && (len(i.randomOps) == 0 || i.randomOps[op]) {
+		if i.rnd.Float64() < i.probability {
+			return i.randomErrs[i.rnd.Intn(len(i.randomErrs))]
+		}
+	}
+	return nil
+}
+
+// unaryInterceptor fails the unary RPCs chosen by the injector; the operation
+// is the RPC method name, such as OpExecuteSQL.
+func (i *Injector) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if err := i.next(path.Base(method)); err != nil {
+		return err
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// streamInterceptor fails the streaming RPCs chosen by the injector; the
+// operation is the RPC method name, such as OpExecuteStreamingSQL.
+func (i *Injector) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := i.next(path.Base(method)); err != nil {
+		return nil, err
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+// FaultyRepository decorates a repokit.Repository and fails the calls chosen
+// by its Injector before they reach the wrapped repository.
+type FaultyRepository[T any, K any] struct {
+	next     repokit.Repository[T, K]
+	injector *Injector
+}
+
+var _ repokit.Repository[struct{}, string] = (*FaultyRepository[struct{}, string])(nil)
+
+// NewFaultyRepository wraps repo with fault injection driven by injector.
+func NewFaultyRepository[T any, K any](repo repokit.Repository[T, K], injector *Injector) *FaultyRepository[T, K] {
+	return &FaultyRepository[T, K]{next: repo, injector: injector}
+}
+
+// FindByID injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) FindByID(ctx context.Context, key K, columns []string, opts ...repokit.CallOption) (T, bool, error) {
+	if err := r.injector.next("FindByID"); err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return r.next.FindByID(ctx, key, columns, opts...)
+}
+
+// FindAll injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) FindAll(ctx context.Context, columns []string, opts ...repokit.CallOption) ([]T, error) {
+	if err := r.injector.next("FindAll"); err != nil {
+		return nil, err
+	}
+	return r.next.FindAll(ctx, columns, opts...)
+}
+
+// FindByIDs injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) FindByIDs(ctx context.Context, keys []K, columns []string, opts ...repokit.CallOption) ([]T, error) {
+	if err := r.injector.next("FindByIDs"); err != nil {
+		return nil, err
+	}
+	return r.next.FindByIDs(ctx, keys, columns, opts...)
+}
+
+// FindPage injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) FindPage(
+	ctx context.Context,
+	pageSize int,
+	pageToken interface{},
+	columns []string,
+	opts ...repokit.CallOption,
+) ([]T, interface{}, error) {
+	if err := r.injector.next("FindPage"); err != nil {
+		return nil, nil, err
+	}
+	return r.next.FindPage(ctx, pageSize, pageToken, columns, opts...)
+}
+
+// Exists injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) Exists(ctx context.Context, key K, opts ...repokit.CallOption) (bool, error) {
+	if err := r.injector.next("Exists"); err != nil {
+		return false, err
+	}
+	return r.next.Exists(ctx, key, opts...)
+}
+
+// Save injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) Save(ctx context.Context, entity T, opts ...repokit.CallOption) error {
+	if err := r.injector.next("Save"); err != nil {
+		return err
+	}
+	return r.next.Save(ctx, entity, opts...)
+}
+
+// Update injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) Update(ctx context.Context, entity T, opts ...repokit.CallOption) error {
+	if err := r.injector.next("Update"); err != nil {
+		return err
+	}
+	return r.next.Update(ctx, entity, opts...)
+}
+
+// Delete injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) Delete(ctx context.Context, key K, opts ...repokit.CallOption) error {
+	if err := r.injector.next("Delete"); err != nil {
+		return err
+	}
+	return r.next.Delete(ctx, key, opts...)
+}
+
+// SaveTx injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) SaveTx(tx repokit.Transaction, entity T, opts ...repokit.CallOption) error {
+	if err := r.injector.next("SaveTx"); err != nil {
+		return err
+	}
+	return r.next.SaveTx(tx, entity, opts...)
+}
+
+// UpdateTx injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) UpdateTx(tx repokit.Transaction, entity T, opts ...repokit.CallOption) error {
+	if err := r.injector.next("UpdateTx"); err != nil {
+		return err
+	}
+	return r.next.UpdateTx(tx, entity, opts...)
+}
+
+// DeleteTx injects a fault or delegates to the wrapped repository.
+func (r *FaultyRepository[T, K]) DeleteTx(tx repokit.Transaction, key K, opts ...repokit.CallOption) error {
+	if err := r.injector.next("DeleteTx"); err != nil {
+		return err
+	}
+	return r.next.DeleteTx(tx, key, opts...)
+}
+
+// FaultyTransactionManager decorates a repokit.TransactionManager. It can fail
+// RunInTransaction up front (OpRunInTransaction) or fail an attempt after its
+// callback succeeded (OpCommit). An injected Aborted at OpCommit is retried by
+// the wrapped manager, so tests can verify that callbacks are idempotent.
+type FaultyTransactionManager struct {
+	next     repokit.TransactionManager
+	injector *Injector
+}
+
+var _ repokit.TransactionManager = (*FaultyTransactionManager)(nil)
+
+// NewFaultyTransactionManager wraps tm with fault injection driven by injector.
+func NewFaultyTransactionManager(tm repokit.TransactionManager, injector *Injector) *FaultyTransactionManager {
+	return &FaultyTransactionManager{next: tm, injector: injector}
+}
+
+// RunInTransaction injects faults around the wrapped manager's RunInTransaction.
+func (m *FaultyTransactionManager) RunInTransaction(
+	ctx context.Context,
+	fn func(tx repokit.Transaction) error,
+	opts ...repokit.CallOption,
+) error {
+	if err := m.injector.next(OpRunInTransaction); err != nil {
+		return err
+	}
+	return m.next.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return m.injector.next(OpCommit)
+	}, opts...)
+}
@@ -0,0 +1,129 @@
+package repokittest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/Waelson/go-spanner-repo/repokit"
+	"google.golang.org/grpc/codes"
+)
+
+func TestInjectedAbortRetriesCallback(t *testing.T) {
+	for name, factory := range map[string]Factory{
+		"memory":      MemoryFactory(),
+		"spannertest": SpannerFactory(NewSpannertestClient),
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo, tm := factory(t)
+			inj := NewInjector().FailOn(OpCommit, 1, Aborted())
+			faultyTM := NewFaultyTransactionManager(tm, inj)
+			order := sampleOrders()[0]
+
+			attempts := 0
+			err := faultyTM.RunInTransaction(context.Background(), func(tx repokit.Transaction) error {
+				attempts++
+				return repo.SaveTx(tx, order)
+			})
+			if err != nil {
+				t.Fatalf("RunInTransaction: %v", err)
+			}
+			if attempts != 2 || inj.Calls(OpCommit) != 2 {
+				t.Fatalf("callback ran %d times with %d commits, want 2 and 2", attempts, inj.Calls(OpCommit))
+			}
+			got, err := repo.FindAll(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("FindAll: %v", err)
+			}
+			assertOrders(t, got, []Order{order})
+		})
+	}
+}
+
+func TestInjectedErrorsOnChosenCalls(t *testing.T) {
+	repo, _ := MemoryFactory()(t)
+	inj := NewInjector().FailOn("Save", 2, Unavailable())
+	faulty := NewFaultyRepository(repo, inj)
+	ctx := context.Background()
+
+	orders := sampleOrders()
+	if err := faulty.Save(ctx, orders[0]); err != nil {
+		t.Fatalf("first Save: %v", err)
+	}
+	assertCode(t, faulty.Save(ctx, orders[1]), codes.Unavailable)
+	if err := faulty.Save(ctx, orders[1]); err != nil {
+		t.Fatalf("third Save: %v", err)
+	}
+}
+
+func TestSeededFaultsAreReproducible(t *testing.T) {
+	run := func() []codes.Code {
+		inj := NewInjector().FailRandomly(42, 0.5, []error{Aborted(), DeadlineExceeded(), SessionNotFound()}, "FindByID")
+		var got []codes.Code
+		for i := 0; i < 20; i++ {
+			got = append(got, spanner.ErrCode(inj.next("FindByID")))
+		}
+		return got
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seeded runs differ at call %d: %v vs %v", i+1, first, second)
+		}
+	}
+}
+
+func TestInjectedQueryFaultRetriesAggregate(t *testing.T) {
+	inj := NewInjector()
+	client := NewFaultySpannertestClient(t, inj)
+	repo, err := NewOrderBuilder(client).
+		WithDefaultRetryPolicy(repokit.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			RetryableCodes: []codes.Code{codes.Aborted},
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("build repository: %v", err)
+	}
+	seed(t, repo, sampleOrders()...)
+
+	inj.FailOn(OpExecuteStreamingSQL, inj.Calls(OpExecuteStreamingSQL)+1, Aborted())
+	if n, err := repo.Count(context.Background()); err != nil || n != 5 {
+		t.Fatalf("Count after an injected abort = (%d, %v), want 5", n, err)
+	}
+}
+
+func TestInjectedDMLFaultIsNotRetriedByUpdateIf(t *testing.T) {
+	inj := NewInjector()
+	client := NewFaultySpannertestClient(t, inj)
+	repo, err := NewOrderBuilder(client).
+		WithDefaultRetryPolicy(repokit.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			RetryableCodes: []codes.Code{codes.DeadlineExceeded},
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("build repository: %v", err)
+	}
+	seed(t, repo, sampleOrders()...)
+
+	// Spanner reruns a failed first statement once with an explicit
+	// BeginTransaction, so two faults fail the whole transaction.
+	calls := inj.Calls(OpExecuteSQL)
+	inj.FailOn(OpExecuteSQL, calls+1, DeadlineExceeded()).
+		FailOn(OpExecuteSQL, calls+2, DeadlineExceeded())
+	key := OrderKey{CustomerID: "alice", OrderID: 1}
+	applied, err := repo.UpdateIf(context.Background(), key,
+		repokit.Where(OrderCols.Status.Eq("PAID")), map[string]interface{}{"status": "SHIPPED"})
+	assertCode(t, err, codes.DeadlineExceeded)
+	if applied {
+		t.Fatal("UpdateIf reported a failed update as applied")
+	}
+	if got := inj.Calls(OpExecuteSQL) - calls; got != 2 {
+		t.Fatalf("UpdateIf made %d DML calls, want 2 without a retry", got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package repokittest provides a conformance suite for repokit repositories
+// and helpers to run it against the in-process spannertest server or the
+// Cloud Spanner emulator.
+package repokittest
+
+import (
+	"cloud.google.com/go/spanner"
+	"github.com/Waelson/go-spanner-repo/repokit"
+)
+
+// OrdersTable is the table used by the conformance suite.
+const OrdersTable = "Orders"
+
+// OutboxTable is the outbox table used by the conformance suite.
+const OutboxTable = "Outbox"
+
+// Schema holds the DDL statements a database must contain for the suite.
+// The composite primary key exercises multi-column key handling.
+var Schema = append([]string{
+	`CREATE TABLE Orders (
+		customer_id STRING(36) NOT NULL,
+		order_id INT64 NOT NULL,
+		status STRING(32) NOT NULL,
+		amount INT64 NOT NULL,
+	) PRIMARY KEY (customer_id, order_id)`,
+}, mustOutboxSchema(OutboxTable)...)
+
+// mustOutboxSchema returns the DDL of the outbox table, which only fails for
+// an invalid table name.
+func mustOutboxSchema(table string) []string {
+	schema, err := repokit.OutboxSchema(table)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// profilesTable holds an entity with a JSON column. spannertest does not
+// support JSON, so only NewEmulatorClient creates it, from profilesSchema.
+const profilesTable = "Profiles"
+
+// profilesSchema holds the DDL of profilesTable.
+var profilesSchema = []string{
+	`CREATE TABLE Profiles (
+		user_id STRING(36) NOT NULL,
+		profile JSON,
+	) PRIMARY KEY (user_id)`,
+}
+
+// userProfile is the entity stored in profilesTable.
+type userProfile struct {
+	UserID  string           `spanner:"user_id"`
+	Profile spanner.NullJSON `spanner:"profile"`
+}
+
+// newProfileRepository creates a SpannerRepository for profilesTable.
+func newProfileRepository(client *spanner.Client) (*repokit.SpannerRepository[userProfile, string], error) {
+	return repokit.NewSpannerRepositoryBuilder[userProfile, string]().
+		WithClient(client).
+		WithTableName(profilesTable).
+		WithPrimaryKeys([]string{"user_id"}).
+		WithRowMapper(func(row *spanner.Row) (userProfile, error) {
+			var p userProfile
+			err := row.ToStruct(&p)
+			return p, err
+		}).
+		WithMutation(func(p userProfile) *spanner.Mutation {
+			m, _ := spanner.InsertOrUpdateStruct(profilesTable, p)
+			return m
+		}).
+		WithKeyExtractor(func(p userProfile) string { return p.UserID }).
+		Build()
+}
+
+// Order is the entity stored in OrdersTable.
+type Order struct {
+	CustomerID string `spanner:"customer_id"`
+	OrderID    int64  `spanner:"order_id"`
+	Status     string `spanner:"status"`
+	Amount     int64  `spanner:"amount"`
+}
+
+// OrderKey is the composite primary key of Order.
+type OrderKey struct {
+	CustomerID string `spanner:"customer_id"`
+	OrderID    int64  `spanner:"order_id"`
+}
+
+// OrderCols are the typed columns of OrdersTable.
+var OrderCols = struct {
+	CustomerID repokit.Column[string]
+	OrderID    repokit.Column[int64]
+	Status     repokit.Column[string]
+	Amount     repokit.Column[int64]
+}{
+	CustomerID: repokit.NewColumn[string]("customer_id"),
+	OrderID:    repokit.NewColumn[int64]("order_id"),
+	Status:     repokit.NewColumn[string]("status"),
+	Amount:     repokit.NewColumn[int64]("amount"),
+}
+
+// OrderPrimaryKeys lists the primary key columns of OrdersTable in schema order.
+var OrderPrimaryKeys = []string{"customer_id", "order_id"}
+
+// orderKey returns the primary key of an Order.
+func orderKey(o Order) OrderKey {
+	return OrderKey{CustomerID: o.CustomerID, OrderID: o.OrderID}
+}
+
+// orderRowMapper converts a Spanner row into an Order.
+func orderRowMapper(row *spanner.Row) (Order, error) {
+	var o Order
+	err := row.ToStruct(&o)
+	return o, err
+}
+
+// orderMutation builds an upsert mutation for an Order.
+func orderMutation(o Order) *spanner.Mutation {
+	m, _ := spanner.InsertOrUpdateStruct(OrdersTable, o)
+	return m
+}
+
+// orderUpdateMutation builds an update mutation for an Order, so updates of
+// missing rows fail with codes.NotFound.
+func orderUpdateMutation(o Order) *spanner.Mutation {
+	m, _ := spanner.UpdateStruct(OrdersTable, o)
+	return m
+}
+
+// NewOrderBuilder returns a SpannerRepositoryBuilder configured for OrdersTable.
+// Callers may add options before calling Build.
+func NewOrderBuilder(client *spanner.Client) *repokit.SpannerRepositoryBuilder[Order, OrderKey] {
+	return repokit.NewSpannerRepositoryBuilder[Order, OrderKey]().
+		WithClient(client).
+		WithTableName(OrdersTable).
+		WithPrimaryKeys(OrderPrimaryKeys).
+		WithRowMapper(orderRowMapper).
+		WithMutation(orderMutation).
+		WithUpdateMutation(orderUpdateMutation).
+		WithKeyExtractor(orderKey)
+}
+
+// NewOrderRepository creates a SpannerRepository for OrdersTable.
+func NewOrderRepository(client *spanner.Client) (*repokit.SpannerRepository[Order, OrderKey], error) {
+	return NewOrderBuilder(client).Build()
+}
+
+// NewMemoryOrderRepository creates a MemoryRepository for Order.
+func NewMemoryOrderRepository() (*repokit.MemoryRepository[Order, OrderKey], error) {
+	return repokit.NewMemoryRepository[Order, OrderKey](OrderPrimaryKeys, orderKey)
+}
@@ -0,0 +1,1134 @@
+package repokittest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/Waelson/go-spanner-repo/repokit"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/codes"
+)
+
+// RunSpannerRepositorySuite verifies the SpannerRepository features that have
+// no in-memory counterpart: raw queries, aggregations, projections, typed
+// column filtering and ordering, lifecycle hooks, partial and conditional
+// updates, key returning inserts, identifier validation, builder validation,
+// schema validation, tracing, metrics, query logging, call options, timeouts,
+// caching, batching and the transactional outbox.
+// Every subtest gets a fresh database from newClient.
+func RunSpannerRepositorySuite(t *testing.T, newClient ClientFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey])
+	}{
+		{"Single", testSingle},
+		{"Aggregates", testAggregates},
+		{"AggregatesTx", testAggregatesTx},
+		{"GroupBy", testGroupBy},
+		{"Project", testProject},
+		{"TypedColumns", testTypedColumns},
+		{"Hooks", testHooks},
+		{"Patch", testPatch},
+		{"PatchJSON", testPatchJSON},
+		{"UpdateIf", testUpdateIf},
+		{"SaveReturningKey", testSaveReturningKey},
+		{"InvalidIdentifiers", testInvalidIdentifiers},
+		{"StrictColumns", testStrictColumns},
+		{"BuildValidation", testBuildValidation},
+		{"Validate", testValidate},
+		{"Tracing", testTracing},
+		{"Metrics", testMetrics},
+		{"QueryLogging", testQueryLogging},
+		{"CallOptions", testCallOptions},
+		{"Timeouts", testTimeouts},
+		{"Cache", testCache},
+		{"Batching", testBatching},
+		{"Outbox", testOutbox},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newClient(t)
+			repo, err := NewOrderRepository(client)
+			if err != nil {
+				t.Fatalf("build repository: %v", err)
+			}
+			seed(t, repo, sampleOrders()...)
+			tc.fn(t, client, repo)
+		})
+	}
+}
+
+func testSingle(t *testing.T, _ *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	sql := "SELECT customer_id, order_id, status, amount FROM Orders WHERE customer_id = @c AND order_id = @o"
+
+	got, found, err := repo.Single(ctx, sql, map[string]interface{}{"c": "bob", "o": int64(1)})
+	if err != nil || !found {
+		t.Fatalf("Single existing = (%v, %v), want found", found, err)
+	}
+	assertOrders(t, []Order{got}, []Order{sampleOrders()[3]})
+
+	_, found, err = repo.Single(ctx, sql, map[string]interface{}{"c": "bob", "o": int64(9)})
+	if err != nil || found {
+		t.Fatalf("Single missing = (%v, %v), want not found and no error", found, err)
+	}
+}
+
+func testAggregates(t *testing.T, _ *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	paid := repokit.Where(repokit.Eq("status", "PAID"))
+
+	if n, err := repo.Count(ctx); err != nil || n != 5 {
+		t.Fatalf("Count = (%d, %v), want 5", n, err)
+	}
+	if n, err := repo.CountWhere(ctx, paid); err != nil || n != 3 {
+		t.Fatalf("CountWhere = (%d, %v), want 3", n, err)
+	}
+
+	total, ok, err := repokit.Sum[int64](repo, ctx, "amount", paid)
+	skipQuotingUnsupported(t, err)
+	if err != nil || !ok || total != 155 {
+		t.Fatalf("Sum = (%d, %v, %v), want 155", total, ok, err)
+	}
+	if low, ok, err := repokit.Min[int64](repo, ctx, "amount", paid); err != nil || !ok || low != 25 {
+		t.Fatalf("Min = (%d, %v, %v), want 25", low, ok, err)
+	}
+	if low, ok, err := repokit.Min[string](repo, ctx, "status", repokit.Criteria{}); err != nil || !ok || low != "NEW" {
+		t.Fatalf("Min of status = (%q, %v, %v), want NEW", low, ok, err)
+	}
+	if high, ok, err := repokit.Max[int64](repo, ctx, "amount", repokit.Criteria{}); err != nil || !ok || high != 100 {
+		t.Fatalf("Max = (%d, %v, %v), want 100", high, ok, err)
+	}
+	if high, ok, err := repokit.Max[int64](repo, ctx, "amount", repokit.Where(repokit.Eq("status", "LOST"))); err != nil || ok || high != 0 {
+		t.Fatalf("Max over no rows = (%d, %v, %v), want not ok", high, ok, err)
+	}
+
+	in := repokit.Where(repokit.In("customer_id", []string{"bob"}), repokit.Ge("amount", 30))
+	if n, err := repo.CountWhere(ctx, in); err != nil || n != 2 {
+		t.Fatalf("CountWhere with IN = (%d, %v), want 2", n, err)
+	}
+}
+
+func testAggregatesTx(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	tm := repokit.NewSpannerTransactionManager(client)
+	err := tm.RunInTransaction(context.Background(), func(tx repokit.Transaction) error {
+		n, err := repo.CountWhereTx(tx, repokit.Where(repokit.Eq("customer_id", "alice")))
+		if err != nil {
+			return err
+		}
+		if n != 3 {
+			t.Errorf("CountWhereTx = %d, want 3", n)
+		}
+		sum, ok, err := repokit.SumTx[int64](repo, tx, "amount", repokit.Criteria{})
+		if err != nil {
+			return err
+		}
+		if !ok || sum != 275 {
+			t.Errorf("SumTx = (%d, %v), want 275", sum, ok)
+		}
+		high, ok, err := repokit.MaxTx[int64](repo, tx, "amount", repokit.Criteria{})
+		if err != nil {
+			return err
+		}
+		if !ok || high != 100 {
+			t.Errorf("MaxTx = (%d, %v), want 100", high, ok)
+		}
+		return nil
+	})
+	skipQuotingUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+}
+
+// statusTotals is the bucket type used by testGroupBy.
+type statusTotals struct {
+	Status string `spanner:"status"`
+	Orders int64  `spanner:"orders"`
+	Amount int64  `spanner:"amount"`
+}
+
+func testGroupBy(t *testing.T, _ *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	buckets, err := repokit.GroupBy[statusTotals](repo, context.Background(),
+		[]string{"status"},
+		[]repokit.Aggregate{repokit.CountAs("orders"), repokit.SumAs("amount", "amount")},
+		repokit.Criteria{})
+	skipQuotingUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("GroupBy: %v", err)
+	}
+	want := []statusTotals{
+		{Status: "NEW", Orders: 1, Amount: 50},
+		{Status: "PAID", Orders: 3, Amount: 155},
+		{Status: "SHIPPED", Orders: 1, Amount: 70},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("GroupBy = %+v, want %+v", buckets, want)
+	}
+	for i := range want {
+		if buckets[i] != want[i] {
+			t.Fatalf("GroupBy = %+v, want %+v", buckets, want)
+		}
+	}
+}
+
+// orderSummary is the projection type used by testProject.
+type orderSummary struct {
+	OrderID int64  `spanner:"order_id"`
+	Status  string `spanner:"status"`
+}
+
+func testProject(t *testing.T, _ *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+
+	summaries, err := repokit.Project[orderSummary](repo, ctx, nil, repokit.Where(repokit.Eq("customer_id", "bob")))
+	if err != nil {
+		t.Fatalf("Project: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("Project = %+v, want 2 summaries", summaries)
+	}
+
+	summary, found, err := repokit.ProjectByID[orderSummary](repo, ctx, OrderKey{CustomerID: "alice", OrderID: 2}, nil)
+	if err != nil || !found {
+		t.Fatalf("ProjectByID = (%v, %v), want found", found, err)
+	}
+	if summary != (orderSummary{OrderID: 2, Status: "NEW"}) {
+		t.Fatalf("ProjectByID = %+v", summary)
+	}
+
+	_, found, err = repokit.ProjectByID[orderSummary](repo, ctx, OrderKey{CustomerID: "alice", OrderID: 9}, nil)
+	if err != nil || found {
+		t.Fatalf("ProjectByID missing = (%v, %v), want not found", found, err)
+	}
+}
+
+func testTypedColumns(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	paid := repokit.Where(OrderCols.Status.Eq("PAID")).OrderBy(OrderCols.Amount.Desc())
+
+	orders, err := repo.FindWhere(ctx, paid, nil)
+	if err != nil {
+		t.Fatalf("FindWhere: %v", err)
+	}
+	var amounts []int64
+	for _, o := range orders {
+		amounts = append(amounts, o.Amount)
+	}
+	if fmt.Sprint(amounts) != "[100 30 25]" {
+		t.Fatalf("FindWhere amounts = %v, want [100 30 25]", amounts)
+	}
+
+	summaries, err := repokit.Project[orderSummary](repo, ctx,
+		repokit.ColumnNames(OrderCols.OrderID, OrderCols.Status),
+		repokit.Where(OrderCols.CustomerID.In("alice")).OrderBy(OrderCols.OrderID.Desc()))
+	if err != nil {
+		t.Fatalf("Project: %v", err)
+	}
+	if len(summaries) != 3 || summaries[0].OrderID != 3 || summaries[2].OrderID != 1 {
+		t.Fatalf("Project = %+v, want alice's orders by descending id", summaries)
+	}
+
+	buckets, err := repokit.GroupBy[statusTotals](repo, ctx,
+		repokit.ColumnNames(OrderCols.Status),
+		[]repokit.Aggregate{repokit.CountAs("orders"), repokit.SumAs(OrderCols.Amount.Name(), "amount")},
+		repokit.Criteria{}.OrderBy(repokit.Desc("amount")))
+	skipQuotingUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("GroupBy: %v", err)
+	}
+	if len(buckets) != 3 || buckets[0].Status != "PAID" || buckets[2].Status != "NEW" {
+		t.Fatalf("GroupBy = %+v, want buckets by descending amount", buckets)
+	}
+
+	err = repokit.NewSpannerTransactionManager(client).RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		orders, err := repo.FindWhereTx(tx, repokit.Where(OrderCols.Amount.Lt(50)), nil)
+		if err == nil && len(orders) != 2 {
+			err = fmt.Errorf("FindWhereTx = %+v, want 2 orders", orders)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testHooks(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	errNegative := errors.New("negative amount")
+	errProtected := errors.New("protected order")
+	errAudit := errors.New("audit failed")
+	var saved []OrderKey
+	repo, err := NewOrderBuilder(client).
+		WithBeforeSave(func(_ context.Context, o *Order) error {
+			if o.Amount < 0 {
+				return errNegative
+			}
+			o.Status = strings.ToUpper(o.Status)
+			return nil
+		}).
+		WithAfterSave(func(_ context.Context, o Order) error {
+			if o.CustomerID == "audit" {
+				return errAudit
+			}
+			saved = append(saved, orderKey(o))
+			return nil
+		}).
+		WithAfterLoad(func(_ context.Context, o *Order) error {
+			o.Status = strings.ToLower(o.Status)
+			return nil
+		}).
+		WithBeforeDelete(func(_ context.Context, key OrderKey) error {
+			if key.CustomerID == "bob" {
+				return errProtected
+			}
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("build repository with hooks: %v", err)
+	}
+
+	if err := repo.Save(ctx, Order{CustomerID: "carol", OrderID: 1, Status: "new", Amount: 10}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	row, err := client.Single().ReadRow(ctx, OrdersTable, spanner.Key{"carol", int64(1)}, []string{"status"})
+	if err != nil {
+		t.Fatalf("ReadRow: %v", err)
+	}
+	var stored string
+	if err := row.Column(0, &stored); err != nil || stored != "NEW" {
+		t.Fatalf("stored status = %q (%v), want NEW", stored, err)
+	}
+	got, found, err := repo.FindByID(ctx, OrderKey{CustomerID: "carol", OrderID: 1}, nil)
+	if err != nil || !found || got.Status != "new" {
+		t.Fatalf("FindByID = (%+v, %v, %v), want status new", got, found, err)
+	}
+	orders, err := repo.FindWhere(ctx, repokit.Where(OrderCols.CustomerID.Eq("alice")), nil)
+	if err != nil {
+		t.Fatalf("FindWhere: %v", err)
+	}
+	for _, o := range orders {
+		if o.Status != strings.ToLower(o.Status) {
+			t.Fatalf("FindWhere returned %+v, want AfterLoad applied", o)
+		}
+	}
+
+	err = repo.Update(ctx, Order{CustomerID: "carol", OrderID: 1, Status: "paid", Amount: -1})
+	if !errors.Is(err, errNegative) {
+		t.Fatalf("Update with negative amount = %v, want %v", err, errNegative)
+	}
+	if err := repo.Delete(ctx, OrderKey{CustomerID: "bob", OrderID: 1}); !errors.Is(err, errProtected) {
+		t.Fatalf("Delete protected = %v, want %v", err, errProtected)
+	}
+	if exists, err := repo.Exists(ctx, OrderKey{CustomerID: "bob", OrderID: 1}); err != nil || !exists {
+		t.Fatalf("Exists after aborted Delete = (%v, %v), want true", exists, err)
+	}
+
+	tm := repokit.NewSpannerTransactionManager(client)
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := repo.SaveTx(tx, Order{CustomerID: "carol", OrderID: 2, Status: "new", Amount: 5}); err != nil {
+			return err
+		}
+		return repo.UpdateTx(tx, Order{CustomerID: "carol", OrderID: 1, Status: "paid", Amount: -5})
+	})
+	if !errors.Is(err, errNegative) {
+		t.Fatalf("transaction with negative amount = %v, want %v", err, errNegative)
+	}
+	if exists, err := repo.Exists(ctx, OrderKey{CustomerID: "carol", OrderID: 2}); err != nil || exists {
+		t.Fatalf("Exists after rolled back SaveTx = (%v, %v), want false", exists, err)
+	}
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		return repo.DeleteTx(tx, OrderKey{CustomerID: "bob", OrderID: 2})
+	})
+	if !errors.Is(err, errProtected) {
+		t.Fatalf("DeleteTx protected = %v, want %v", err, errProtected)
+	}
+
+	want := []OrderKey{{CustomerID: "carol", OrderID: 1}, {CustomerID: "carol", OrderID: 2}}
+	if fmt.Sprint(saved) != fmt.Sprint(want) {
+		t.Fatalf("AfterSave saw %v, want %v", saved, want)
+	}
+
+	// An AfterSave error is returned, but the committed write stays.
+	audited := OrderKey{CustomerID: "audit", OrderID: 1}
+	if err := repo.Save(ctx, Order{CustomerID: "audit", OrderID: 1, Status: "new", Amount: 1}); !errors.Is(err, errAudit) {
+		t.Fatalf("Save with a failing AfterSave = %v, want %v", err, errAudit)
+	}
+	if exists, err := repo.Exists(ctx, audited); err != nil || !exists {
+		t.Fatalf("Exists after a failing AfterSave = (%v, %v), want the write kept", exists, err)
+	}
+	// In a transaction, returning it rolls the write back.
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		return repo.SaveTx(tx, Order{CustomerID: "audit", OrderID: 2, Status: "new", Amount: 1})
+	})
+	if !errors.Is(err, errAudit) {
+		t.Fatalf("SaveTx with a failing AfterSave = %v, want %v", err, errAudit)
+	}
+	if exists, err := repo.Exists(ctx, OrderKey{CustomerID: "audit", OrderID: 2}); err != nil || exists {
+		t.Fatalf("Exists after a rolled back SaveTx = (%v, %v), want false", exists, err)
+	}
+}
+
+func testPatch(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	key := OrderKey{CustomerID: "alice", OrderID: 2}
+
+	if err := repo.Patch(ctx, key, map[string]interface{}{"status": "PAID"}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	// A concurrent writer's change to another column survives the patch.
+	if _, err := client.Apply(ctx, []*spanner.Mutation{
+		spanner.Update(OrdersTable, []string{"customer_id", "order_id", "amount"}, []interface{}{"alice", int64(2), int64(55)}),
+	}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if err := repo.Patch(ctx, key, map[string]interface{}{"Status": "SHIPPED"}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	got, _, err := repo.FindByID(ctx, key, nil)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	assertOrders(t, []Order{got}, []Order{{CustomerID: "alice", OrderID: 2, Status: "SHIPPED", Amount: 55}})
+
+	missing := OrderKey{CustomerID: "alice", OrderID: 99}
+	if err := repo.Patch(ctx, missing, map[string]interface{}{"status": "PAID"}); spanner.ErrCode(err) != codes.NotFound {
+		t.Fatalf("Patch missing row = %v, want NotFound", err)
+	}
+	if err := repo.Patch(ctx, key, map[string]interface{}{"note": "x"}); !errors.Is(err, repokit.ErrColumnNotAllowed) {
+		t.Fatalf("Patch unknown column = %v, want ErrColumnNotAllowed", err)
+	}
+	for _, fields := range []map[string]interface{}{
+		{"order_id": int64(3)},
+		{"status.code": "PAID"},
+		{},
+	} {
+		if err := repo.Patch(ctx, key, fields); err == nil {
+			t.Errorf("Patch(%v) succeeded", fields)
+		}
+	}
+
+	err = repokit.NewSpannerTransactionManager(client).RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		return repo.PatchTx(tx, OrderKey{CustomerID: "bob", OrderID: 1}, map[string]interface{}{"amount": int64(75), "status": "DELIVERED"})
+	})
+	if err != nil {
+		t.Fatalf("PatchTx: %v", err)
+	}
+	got, _, err = repo.FindByID(ctx, OrderKey{CustomerID: "bob", OrderID: 1}, nil)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	assertOrders(t, []Order{got}, []Order{{CustomerID: "bob", OrderID: 1, Status: "DELIVERED", Amount: 75}})
+}
+
+func testValidate(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	probe := spanner.Statement{SQL: "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES LIMIT 1"}
+	if err := client.Single().Query(ctx, probe).Do(func(*spanner.Row) error { return nil }); err != nil {
+		t.Skipf("backend has no INFORMATION_SCHEMA: %v", err)
+	}
+	if report, err := repo.Validate(ctx); err != nil {
+		t.Fatalf("Validate = (%v, %v), want OK", report, err)
+	}
+	// Spanner matches table names case-insensitively, so must Validate.
+	lower, err := NewOrderBuilder(client).WithTableName(strings.ToLower(OrdersTable)).Build()
+	if err != nil {
+		t.Fatalf("build repository: %v", err)
+	}
+	if report, err := lower.Validate(ctx); err != nil {
+		t.Fatalf("Validate of %q = (%v, %v), want OK", strings.ToLower(OrdersTable), report, err)
+	}
+	missing, err := NewOrderBuilder(client).WithTableName("NoSuchTable").Build()
+	if err != nil {
+		t.Fatalf("build repository: %v", err)
+	}
+	if report, err := missing.Validate(ctx); !errors.Is(err, repokit.ErrSchemaDrift) || report == nil || !report.TableMissing {
+		t.Fatalf("Validate of a missing table = (%v, %v), want TableMissing", report, err)
+	}
+}
+
+func testPatchJSON(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	if err := client.Single().Query(ctx, spanner.Statement{SQL: "SELECT user_id FROM Profiles LIMIT 1"}).Do(func(*spanner.Row) error { return nil }); err != nil {
+		t.Skipf("backend has no %s table with a JSON column: %v", profilesTable, err)
+	}
+	repo, err := newProfileRepository(client)
+	if err != nil {
+		t.Fatalf("build profile repository: %v", err)
+	}
+	// A NULL document is patched as an empty object, so the first nested
+	// path creates it.
+	if err := repo.Save(ctx, userProfile{UserID: "u1"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.Patch(ctx, "u1", map[string]interface{}{"profile.address.city": "Lisbon"}); err != nil {
+		t.Fatalf("Patch of a NULL document: %v", err)
+	}
+	if err := repo.Patch(ctx, "u1", map[string]interface{}{"profile.nickname": "ann"}); err != nil {
+		t.Fatalf("Patch of a second path: %v", err)
+	}
+	got, found, err := repo.FindByID(ctx, "u1", nil)
+	if err != nil || !found {
+		t.Fatalf("FindByID = (%v, %v), want found", found, err)
+	}
+	if want := `{"address":{"city":"Lisbon"},"nickname":"ann"}`; got.Profile.String() != want {
+		t.Fatalf("profile = %s, want %s", got.Profile.String(), want)
+	}
+	if err := repo.Patch(ctx, "u2", map[string]interface{}{"profile.nickname": "bob"}); spanner.ErrCode(err) != codes.NotFound {
+		t.Fatalf("Patch missing row = %v, want NotFound", err)
+	}
+}
+
+func testUpdateIf(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	paid := repokit.Where(OrderCols.Status.Eq("PAID"))
+	ship := map[string]interface{}{"status": "SHIPPED"}
+
+	applied, err := repo.UpdateIf(ctx, OrderKey{CustomerID: "alice", OrderID: 1}, paid, ship)
+	if err != nil || !applied {
+		t.Fatalf("UpdateIf PAID order = (%v, %v), want applied", applied, err)
+	}
+	// alice 2 is NEW, so the transition does not apply.
+	applied, err = repo.UpdateIf(ctx, OrderKey{CustomerID: "alice", OrderID: 2}, paid, ship)
+	if err != nil || applied {
+		t.Fatalf("UpdateIf NEW order = (%v, %v), want not applied", applied, err)
+	}
+	applied, err = repo.UpdateIf(ctx, OrderKey{CustomerID: "alice", OrderID: 99}, paid, ship)
+	if err != nil || applied {
+		t.Fatalf("UpdateIf missing order = (%v, %v), want not applied", applied, err)
+	}
+	if _, err := repo.UpdateIf(ctx, OrderKey{CustomerID: "alice", OrderID: 2}, paid, map[string]interface{}{"note": "x"}); !errors.Is(err, repokit.ErrColumnNotAllowed) {
+		t.Fatalf("UpdateIf unknown column = %v, want ErrColumnNotAllowed", err)
+	}
+
+	err = repokit.NewSpannerTransactionManager(client).RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		key := OrderKey{CustomerID: "bob", OrderID: 2}
+		applied, err := repo.UpdateIfTx(tx, key, paid.And(OrderCols.Amount.Ge(30)), map[string]interface{}{"status": "SHIPPED", "amount": int64(35)})
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return fmt.Errorf("UpdateIfTx PAID order not applied")
+		}
+		// The first transition is visible to the second one.
+		applied, err = repo.UpdateIfTx(tx, key, paid, map[string]interface{}{"status": "CANCELLED"})
+		if err == nil && applied {
+			err = fmt.Errorf("UpdateIfTx of a shipped order applied")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.FindWhere(ctx, repokit.Where(OrderCols.Status.Eq("SHIPPED")).OrderBy(OrderCols.CustomerID.Asc(), OrderCols.OrderID.Asc()), nil)
+	if err != nil {
+		t.Fatalf("FindWhere: %v", err)
+	}
+	assertOrders(t, got, []Order{
+		{CustomerID: "alice", OrderID: 1, Status: "SHIPPED", Amount: 100},
+		{CustomerID: "bob", OrderID: 1, Status: "SHIPPED", Amount: 70},
+		{CustomerID: "bob", OrderID: 2, Status: "SHIPPED", Amount: 35},
+	})
+}
+
+func testSaveReturningKey(t *testing.T, _ *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	var orderID int64
+	err := repo.SaveReturningKey(context.Background(),
+		"INSERT INTO Orders (customer_id, order_id, status, amount) VALUES (@c, @o, 'NEW', 1) THEN RETURN order_id",
+		map[string]interface{}{"c": "dave", "o": int64(42)}, &orderID)
+	if spanner.ErrCode(err) == codes.InvalidArgument {
+		t.Skipf("backend does not support THEN RETURN: %v", err)
+	}
+	if err != nil || orderID != 42 {
+		t.Fatalf("SaveReturningKey = (%d, %v), want 42", orderID, err)
+	}
+}
+
+func testInvalidIdentifiers(t *testing.T, _ *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+
+	if _, err := repo.FindAll(ctx, []string{"status FROM Orders --"}); !errors.Is(err, repokit.ErrInvalidIdentifier) {
+		t.Fatalf("FindAll with injected column = %v, want ErrInvalidIdentifier", err)
+	}
+	_, err := repo.CountWhere(ctx, repokit.Where(repokit.Eq("1=1 OR status", "x")))
+	if !errors.Is(err, repokit.ErrInvalidIdentifier) {
+		t.Fatalf("CountWhere with injected column = %v, want ErrInvalidIdentifier", err)
+	}
+	if n, err := repo.CountWhere(ctx, repokit.Where(repokit.Eq("status", "' OR 1=1 --"))); err != nil || n != 0 {
+		t.Fatalf("CountWhere with injected value = (%d, %v), want 0 rows", n, err)
+	}
+}
+
+func testStrictColumns(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	repo, err := NewOrderBuilder(client).WithStrictColumns().Build()
+	if err != nil {
+		t.Fatalf("build strict repository: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := repo.FindAll(ctx, []string{"customer_id", "status"}); err != nil {
+		t.Fatalf("FindAll with known columns: %v", err)
+	}
+	if _, err := repo.FindAll(ctx, []string{"secret"}); !errors.Is(err, repokit.ErrColumnNotAllowed) {
+		t.Fatalf("FindAll with unknown column = %v, want ErrColumnNotAllowed", err)
+	}
+}
+
+func testBuildValidation(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	type partialKey struct {
+		CustomerID string `spanner:"customer_id"`
+	}
+	_, err := repokit.NewSpannerRepositoryBuilder[Order, partialKey]().
+		WithClient(client).
+		WithTableName(OrdersTable).
+		WithPrimaryKeys(OrderPrimaryKeys).
+		WithRowMapper(orderRowMapper).
+		WithMutation(orderMutation).
+		WithKeyExtractor(func(o Order) partialKey { return partialKey{CustomerID: o.CustomerID} }).
+		Build()
+	if err == nil {
+		t.Fatal("Build accepted a key type missing a primary key column")
+	}
+
+	if _, err := NewOrderBuilder(client).WithTableName("Orders; DROP TABLE Orders").Build(); !errors.Is(err, repokit.ErrInvalidIdentifier) {
+		t.Fatalf("Build with invalid table name = %v, want ErrInvalidIdentifier", err)
+	}
+}
+
+func testTracing(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	repo, err := NewOrderBuilder(client).WithTracerProvider(tp).Build()
+	if err != nil {
+		t.Fatalf("build traced repository: %v", err)
+	}
+	tm := repokit.NewSpannerTransactionManager(client, repokit.WithTransactionTracerProvider(tp))
+	ctx := context.Background()
+
+	if _, err := repo.FindByIDs(ctx, []OrderKey{{"alice", 1}, {"bob", 1}, {"nobody", 1}}, nil); err != nil {
+		t.Fatalf("FindByIDs: %v", err)
+	}
+	if _, _, err := repo.FindPage(ctx, 2, "not-a-composite-key", nil); err == nil {
+		t.Fatal("FindPage accepted an invalid page token")
+	}
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		return repo.SaveTx(tx, Order{CustomerID: "carol", OrderID: 1, Status: "NEW", Amount: 5})
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	attr := func(name string, key attribute.Key) int64 {
+		t.Helper()
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("no span %q among %v", name, spanNames(recorder.Ended()))
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key == key {
+				return kv.Value.AsInt64()
+			}
+		}
+		t.Fatalf("span %q has no attribute %s", name, key)
+		return 0
+	}
+
+	if keys, rows := attr("FindByIDs Orders", "repokit.keys"), attr("FindByIDs Orders", "repokit.rows"); keys != 3 || rows != 2 {
+		t.Fatalf("FindByIDs span keys=%d rows=%d, want 3 and 2", keys, rows)
+	}
+	if page := spans["FindPage Orders"]; page == nil || page.Status().Code != otelcodes.Error || len(page.Events()) == 0 {
+		t.Fatal("FindPage span did not record the invalid token error")
+	}
+	if n := attr("SaveTx Orders", "repokit.mutations"); n != 1 {
+		t.Fatalf("SaveTx span mutations=%d, want 1", n)
+	}
+	if n := attr("RunInTransaction", "repokit.transaction.attempts"); n != 1 {
+		t.Fatalf("RunInTransaction span attempts=%d, want 1", n)
+	}
+	if spans["SaveTx Orders"].Parent().SpanID() != spans["RunInTransaction"].SpanContext().SpanID() {
+		t.Fatal("SaveTx span is not a child of the RunInTransaction span")
+	}
+}
+
+// spanNames lists the names of spans, for failure messages.
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+func testMetrics(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	reader := sdkmetric.NewManualReader()
+	metrics, err := repokit.NewOTelMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatalf("NewOTelMetrics: %v", err)
+	}
+	repo, err := NewOrderBuilder(client).WithMetrics(metrics).Build()
+	if err != nil {
+		t.Fatalf("build repository with metrics: %v", err)
+	}
+	tm := repokit.NewSpannerTransactionManager(client, repokit.WithTransactionMetrics(metrics))
+	ctx := context.Background()
+
+	if _, err := repo.FindAll(ctx, nil); err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if err := repo.Update(ctx, Order{CustomerID: "nobody", OrderID: 1}); err == nil {
+		t.Fatal("Update of a missing row succeeded")
+	}
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		return repo.SaveTx(tx, Order{CustomerID: "carol", OrderID: 1, Status: "NEW", Amount: 5})
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	sums := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value("db.operation.name")
+					key := m.Name + "/" + op.AsString()
+					if class, ok := dp.Attributes.Value("repokit.error.class"); ok {
+						key += "/" + class.AsString()
+					}
+					sums[key] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value("db.operation.name")
+					sums[m.Name+"/"+op.AsString()] += int64(dp.Count)
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value("db.operation.name")
+					sums[m.Name+"/"+op.AsString()] += dp.Sum
+				}
+			}
+		}
+	}
+
+	want := map[string]int64{
+		"repokit.operation.duration/FindAll":            1,
+		"repokit.operation.duration/RunInTransaction":   1,
+		"repokit.rows.read/FindAll":                     int64(len(sampleOrders())),
+		"repokit.mutations/SaveTx":                      1,
+		"repokit.errors/Update/not_found":               1,
+		"repokit.transaction.attempts/RunInTransaction": 1,
+	}
+	for key, n := range want {
+		if sums[key] != n {
+			t.Errorf("%s = %d, want %d (all: %v)", key, sums[key], n, sums)
+		}
+	}
+}
+
+func testQueryLogging(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo, err := NewOrderBuilder(client).
+		WithLogger(logger).
+		WithSlowQueryThreshold(time.Nanosecond).
+		WithParamLogMode(repokit.ParamsHashed).
+		WithMaskedColumns("customer_id").
+		Build()
+	if err != nil {
+		t.Fatalf("build repository with logger: %v", err)
+	}
+
+	if _, found, err := repo.FindByID(context.Background(), OrderKey{"alice", 1}, nil); err != nil || !found {
+		t.Fatalf("FindByID = (%v, %v), want found", found, err)
+	}
+
+	var record struct {
+		Level     string            `json:"level"`
+		Operation string            `json:"operation"`
+		SQL       string            `json:"sql"`
+		Params    map[string]string `json:"params"`
+		Rows      int               `json:"rows"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log record %q: %v", buf.String(), err)
+	}
+	if record.Level != "WARN" || record.Operation != "FindByID" || record.Rows != 1 {
+		t.Fatalf("log record = %+v, want a slow FindByID returning 1 row", record)
+	}
+	if !strings.HasPrefix(record.SQL, "SELECT ") || len(record.Params) != 2 {
+		t.Fatalf("log record sql=%q params=%v, want the generated SELECT and its 2 params", record.SQL, record.Params)
+	}
+	// key criteria bind customer_id to p0 and order_id to p1
+	if record.Params["p0"] != "[REDACTED]" || !strings.HasPrefix(record.Params["p1"], "sha256:") {
+		t.Fatalf("params %v: want customer_id masked and order_id hashed", record.Params)
+	}
+}
+
+func testCallOptions(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	ctx := context.Background()
+	low := repokit.WithPriority(sppb.RequestOptions_PRIORITY_LOW)
+
+	if _, found, err := repo.FindByID(ctx, OrderKey{"alice", 1}, nil, repokit.WithRequestTag("lookup"), low); err != nil || !found {
+		t.Fatalf("tagged FindByID = (%v, %v), want found", found, err)
+	}
+	if _, err := repo.FindByIDs(ctx, []OrderKey{{"alice", 1}}, nil, low); err != nil {
+		t.Fatalf("FindByIDs with priority: %v", err)
+	}
+	if err := repo.Save(ctx, Order{CustomerID: "carol", OrderID: 1, Status: "NEW"}, repokit.WithTransactionTag("signup"), low); err != nil {
+		t.Fatalf("tagged Save: %v", err)
+	}
+
+	tm := repokit.NewSpannerTransactionManager(client)
+	err := tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if _, err := repo.CountTx(tx, repokit.WithRequestTag("count-in-txn")); err != nil {
+			return err
+		}
+		return repo.SaveTx(tx, Order{CustomerID: "carol", OrderID: 2, Status: "NEW"})
+	}, repokit.WithTransactionTag("checkout"), repokit.WithPriority(sppb.RequestOptions_PRIORITY_HIGH))
+	if err != nil {
+		t.Fatalf("tagged RunInTransaction: %v", err)
+	}
+}
+
+func testTimeouts(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	repo, err := NewOrderBuilder(client).
+		WithDefaultTimeout(time.Nanosecond).
+		WithDefaultRetryPolicy(repokit.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}).
+		Build()
+	if err != nil {
+		t.Fatalf("build repository with timeout: %v", err)
+	}
+	ctx := context.Background()
+
+	_, err = repo.FindAll(ctx, nil)
+	if class := repokit.ClassifyError(err); class != repokit.ErrorClassDeadlineExceeded {
+		t.Fatalf("FindAll error %v classified as %q, want %q", err, class, repokit.ErrorClassDeadlineExceeded)
+	}
+	if _, err := repo.FindAll(ctx, nil, repokit.WithTimeout(time.Minute)); err != nil {
+		t.Fatalf("FindAll with a per-call timeout override: %v", err)
+	}
+
+	// A transaction that keeps aborting is retried by Spanner until its total
+	// budget runs out.
+	inj := NewInjector().FailRandomly(1, 1, []error{Aborted()}, OpCommit)
+	tm := NewFaultyTransactionManager(repokit.NewSpannerTransactionManager(client,
+		repokit.WithTransactionTimeout(time.Minute)), inj)
+	start := time.Now()
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		return repo.SaveTx(tx, sampleOrders()[0])
+	}, repokit.WithTimeout(300*time.Millisecond))
+	// Spanner returns the context error itself when the deadline expires while
+	// it waits to retry, so classify rather than compare gRPC codes.
+	if class := repokit.ClassifyError(err); class != repokit.ErrorClassDeadlineExceeded {
+		t.Fatalf("RunInTransaction error %v classified as %q, want %q", err, class, repokit.ErrorClassDeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("transaction ran for %v, want it bounded by its 300ms budget", elapsed)
+	}
+	if inj.Calls(OpCommit) < 2 {
+		t.Fatalf("transaction ran %d attempts, want Spanner to retry within the budget", inj.Calls(OpCommit))
+	}
+}
+
+func testCache(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	cache := repokit.NewLRUCache[Order](100, time.Minute, repokit.WithNegativeCaching(time.Minute))
+	repo, err := NewOrderBuilder(client).WithCache(cache).Build()
+	if err != nil {
+		t.Fatalf("build repository with cache: %v", err)
+	}
+	ctx := context.Background()
+	key := OrderKey{CustomerID: "alice", OrderID: 1}
+
+	// writeBehind changes the row without going through the repository, so
+	// only a cache hit can still return the previous value.
+	writeBehind := func(o Order) {
+		t.Helper()
+		if _, err := client.Apply(ctx, []*spanner.Mutation{orderMutation(o)}); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	status := func(key OrderKey) string {
+		t.Helper()
+		got, found, err := repo.FindByID(ctx, key, nil)
+		if err != nil {
+			t.Fatalf("FindByID(%+v): %v", key, err)
+		}
+		if !found {
+			return ""
+		}
+		return got.Status
+	}
+
+	if got := status(key); got != "PAID" {
+		t.Fatalf("status = %q, want PAID", got)
+	}
+	writeBehind(Order{CustomerID: "alice", OrderID: 1, Status: "STALE", Amount: 100})
+	if got := status(key); got != "PAID" {
+		t.Fatalf("status = %q, want the cached PAID", got)
+	}
+
+	if err := repo.Update(ctx, Order{CustomerID: "alice", OrderID: 1, Status: "SHIPPED", Amount: 100}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := status(key); got != "SHIPPED" {
+		t.Fatalf("status after Update = %q, want SHIPPED", got)
+	}
+
+	tm := repokit.NewSpannerTransactionManager(client)
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := repo.SaveTx(tx, Order{CustomerID: "alice", OrderID: 1, Status: "DELIVERED", Amount: 100}); err != nil {
+			return err
+		}
+		if got := status(key); got != "SHIPPED" {
+			t.Errorf("status before commit = %q, want the cached SHIPPED", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+	if got := status(key); got != "DELIVERED" {
+		t.Fatalf("status after commit = %q, want DELIVERED", got)
+	}
+
+	errRollback := errors.New("rollback")
+	err = tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := repo.DeleteTx(tx, key); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("RunInTransaction error = %v, want the callback error", err)
+	}
+	if exists, err := repo.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists after rollback = %v, %v, want true", exists, err)
+	}
+
+	missing := OrderKey{CustomerID: "carol", OrderID: 1}
+	if got := status(missing); got != "" {
+		t.Fatalf("status of missing order = %q, want not found", got)
+	}
+	writeBehind(Order{CustomerID: "carol", OrderID: 1, Status: "NEW"})
+	if got := status(missing); got != "" {
+		t.Fatalf("status = %q, want the cached not found", got)
+	}
+	if err := repo.Delete(ctx, missing); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// Reads of explicit columns bypass the cache.
+	if _, _, err := repo.FindByID(ctx, key, []string{"customer_id", "order_id", "status", "amount"}); err != nil {
+		t.Fatalf("FindByID with columns: %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 4 || stats.NegativeHits != 1 || stats.Misses != 4 {
+		t.Fatalf("cache stats = %+v, want 4 hits (1 negative) and 4 misses", stats)
+	}
+}
+
+func testBatching(t *testing.T, client *spanner.Client, _ *repokit.SpannerRepository[Order, OrderKey]) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reads := func() int {
+		n := 0
+		for _, s := range recorder.Ended() {
+			if s.Name() == "FindByIDs Orders" {
+				n++
+			}
+		}
+		return n
+	}
+	// The batch read is traced under the span of one of its callers.
+	ctx, request := tp.Tracer("repokittest").Start(context.Background(), "request")
+	defer request.End()
+
+	repo, err := NewOrderBuilder(client).WithTracerProvider(tp).WithBatching(200*time.Millisecond, 0).Build()
+	if err != nil {
+		t.Fatalf("build batching repository: %v", err)
+	}
+	keys := []OrderKey{{"alice", 1}, {"alice", 2}, {"bob", 1}, {"nobody", 1}}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		key := keys[i%len(keys)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, found, err := repo.FindByID(ctx, key, nil)
+			switch {
+			case err != nil:
+				t.Errorf("FindByID(%+v): %v", key, err)
+			case found != (key.CustomerID != "nobody"):
+				t.Errorf("FindByID(%+v) found = %v", key, found)
+			case found && orderKey(got) != key:
+				t.Errorf("FindByID(%+v) = %+v", key, got)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := reads(); n != 1 {
+		t.Fatalf("20 concurrent FindByID calls made %d FindByIDs reads, want 1", n)
+	}
+	for _, s := range recorder.Ended() {
+		if s.Name() == "FindByIDs Orders" && s.Parent().TraceID() != request.SpanContext().TraceID() {
+			t.Fatalf("batch read span has parent %v, want a span of the callers' trace", s.Parent())
+		}
+	}
+
+	// A full batch is read without waiting for its window.
+	recorder = tracetest.NewSpanRecorder()
+	tp = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	repo, err = NewOrderBuilder(client).WithTracerProvider(tp).WithBatching(time.Hour, 2).Build()
+	if err != nil {
+		t.Fatalf("build batching repository: %v", err)
+	}
+	for _, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := repo.FindByID(ctx, key, nil); err != nil {
+				t.Errorf("FindByID(%+v): %v", key, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := reads(); n != 2 {
+		t.Fatalf("4 keys in batches of 2 made %d FindByIDs reads, want 2", n)
+	}
+}
+
+func testOutbox(t *testing.T, client *spanner.Client, repo *repokit.SpannerRepository[Order, OrderKey]) {
+	outbox, err := repokit.NewOutbox(client, OutboxTable)
+	if err != nil {
+		t.Fatalf("NewOutbox: %v", err)
+	}
+	if _, err := repokit.OutboxSchema("Outbox; DROP TABLE Orders"); err == nil {
+		t.Fatal("OutboxSchema accepted an invalid table name")
+	}
+	tm := repokit.NewSpannerTransactionManager(client)
+	ctx := context.Background()
+
+	placeOrder := func(o Order, fail error) error {
+		return tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+			if err := repo.SaveTx(tx, o); err != nil {
+				return err
+			}
+			placed := &repokit.OutboxEvent{Topic: "orders", Key: o.CustomerID, Payload: []byte(fmt.Sprint(o.OrderID))}
+			billed := &repokit.OutboxEvent{ID: "billing-" + o.CustomerID, Topic: "billing", Key: o.CustomerID}
+			if err := outbox.EnqueueTx(tx, placed, billed); err != nil {
+				return err
+			}
+			if placed.ID == "" || billed.ID != "billing-"+o.CustomerID {
+				t.Errorf("event IDs after EnqueueTx = %q, %q, want a generated and the given ID", placed.ID, billed.ID)
+			}
+			return fail
+		})
+	}
+	if err := placeOrder(Order{CustomerID: "carol", OrderID: 1, Status: "NEW", Amount: 5}, nil); err != nil {
+		t.Fatalf("place order: %v", err)
+	}
+	errRollback := errors.New("rollback")
+	if err := placeOrder(Order{CustomerID: "dave", OrderID: 1, Status: "NEW", Amount: 5}, errRollback); !errors.Is(err, errRollback) {
+		t.Fatalf("place rolled back order: %v", err)
+	}
+
+	var (
+		published []repokit.OutboxEvent
+		fail      error
+	)
+	publisher := repokit.PublisherFunc(func(_ context.Context, events []repokit.OutboxEvent) error {
+		if fail != nil {
+			return fail
+		}
+		published = append(published, events...)
+		return nil
+	})
+	if _, err := repokit.NewOutboxRelay(outbox, publisher, repokit.WithRelayBatchSize(0)); err == nil {
+		t.Fatal("NewOutboxRelay accepted a batch size of 0")
+	}
+	if _, err := repokit.NewOutboxRelay(outbox, publisher, repokit.WithRelayPollInterval(0)); err == nil {
+		t.Fatal("NewOutboxRelay accepted a poll interval of 0")
+	}
+	relay, err := repokit.NewOutboxRelay(outbox, publisher, repokit.WithRelayCleanup(0, time.Hour))
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+
+	if n, err := relay.RelayOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("RelayOnce = %d, %v, want 2 events", n, err)
+	}
+	if len(published) != 2 || published[0].Key != "carol" || published[0].ID == "" || published[0].CreatedAt.IsZero() {
+		t.Fatalf("published %+v, want the events of the committed order", published)
+	}
+	if n, err := relay.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("RelayOnce after publishing = %d, %v, want nothing left", n, err)
+	}
+
+	// A failed publish leaves the events in the outbox for the next poll.
+	if err := placeOrder(Order{CustomerID: "erin", OrderID: 1, Status: "NEW", Amount: 5}, nil); err != nil {
+		t.Fatalf("place order: %v", err)
+	}
+	errBroker := errors.New("broker unavailable")
+	fail = errBroker
+	if _, err := relay.RelayOnce(ctx); !errors.Is(err, fail) {
+		t.Fatalf("RelayOnce error = %v, want the publisher error", err)
+	}
+	// Run reports the failed poll to its error handler and retries it.
+	var reported []error
+	runCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	relay, err = repokit.NewOutboxRelay(outbox, publisher,
+		repokit.WithRelayPollInterval(10*time.Millisecond), repokit.WithRelayCleanup(-1, 0),
+		repokit.WithRelayErrorHandler(func(err error) {
+			reported = append(reported, err)
+			fail = nil
+		}))
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	if err := relay.Run(runCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want the context error", err)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], errBroker) {
+		t.Fatalf("Run reported %v, want the publisher error once", reported)
+	}
+	if len(published) != 4 || published[2].Key != "erin" {
+		t.Fatalf("published %+v, want the retried events", published)
+	}
+
+	relay, err = repokit.NewOutboxRelay(outbox, publisher, repokit.WithRelayCleanup(0, time.Hour))
+	if err != nil {
+		t.Fatalf("NewOutboxRelay: %v", err)
+	}
+	if n, err := relay.Cleanup(ctx); err != nil || n != 4 {
+		t.Fatalf("Cleanup = %d, %v, want 4 events deleted", n, err)
+	}
+}
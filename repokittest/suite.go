@@ -0,0 +1,456 @@
+package repokittest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/Waelson/go-spanner-repo/repokit"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Factory returns an empty Order repository together with the transaction
+// manager its *Tx methods must be used with.
+type Factory func(t *testing.T) (repokit.Repository[Order, OrderKey], repokit.TransactionManager)
+
+// SpannerFactory adapts a ClientFactory into a Factory returning a
+// SpannerRepository and a SpannerTransactionManager.
+func SpannerFactory(newClient ClientFactory) Factory {
+	return func(t *testing.T) (repokit.Repository[Order, OrderKey], repokit.TransactionManager) {
+		client := newClient(t)
+		repo, err := NewOrderRepository(client)
+		if err != nil {
+			t.Fatalf("build repository: %v", err)
+		}
+		return repo, repokit.NewSpannerTransactionManager(client)
+	}
+}
+
+// MemoryFactory returns a Factory for MemoryRepository and MemoryTransactionManager.
+func MemoryFactory() Factory {
+	return func(t *testing.T) (repokit.Repository[Order, OrderKey], repokit.TransactionManager) {
+		repo, err := NewMemoryOrderRepository()
+		if err != nil {
+			t.Fatalf("build repository: %v", err)
+		}
+		return repo, repokit.NewMemoryTransactionManager()
+	}
+}
+
+// RunRepositorySuite verifies that the repositories returned by factory follow
+// the repokit.Repository contract. Every subtest gets a fresh repository.
+func RunRepositorySuite(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repokit.Repository[Order, OrderKey], tm repokit.TransactionManager)
+	}{
+		{"FindByID", testFindByID},
+		{"Exists", testExists},
+		{"SaveUpserts", testSaveUpserts},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"FindAll", testFindAll},
+		{"FindByIDs", testFindByIDs},
+		{"FindPage", testFindPage},
+		{"FindPageInvalidToken", testFindPageInvalidToken},
+		{"FindPageInvalidSize", testFindPageInvalidSize},
+		{"TransactionCommit", testTransactionCommit},
+		{"TransactionRollback", testTransactionRollback},
+		{"TransactionFailedUpdate", testTransactionFailedUpdate},
+		{"TransactionRetryOnAborted", testTransactionRetryOnAborted},
+		{"ForeignTransaction", testForeignTransaction},
+		{"CanceledContext", testCanceledContext},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, tm := factory(t)
+			tc.fn(t, repo, tm)
+		})
+	}
+}
+
+// sampleOrders returns orders spread over two customers, so pagination
+// crosses a shared first key column.
+func sampleOrders() []Order {
+	return []Order{
+		{CustomerID: "alice", OrderID: 1, Status: "PAID", Amount: 100},
+		{CustomerID: "alice", OrderID: 2, Status: "NEW", Amount: 50},
+		{CustomerID: "alice", OrderID: 3, Status: "PAID", Amount: 25},
+		{CustomerID: "bob", OrderID: 1, Status: "SHIPPED", Amount: 70},
+		{CustomerID: "bob", OrderID: 2, Status: "PAID", Amount: 30},
+	}
+}
+
+// seed saves orders into repo.
+func seed(t *testing.T, repo repokit.Repository[Order, OrderKey], orders ...Order) {
+	t.Helper()
+	for _, o := range orders {
+		if err := repo.Save(context.Background(), o); err != nil {
+			t.Fatalf("Save(%+v): %v", o, err)
+		}
+	}
+}
+
+// sortOrders sorts orders by primary key.
+func sortOrders(orders []Order) []Order {
+	sorted := append([]Order(nil), orders...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].CustomerID != sorted[j].CustomerID {
+			return sorted[i].CustomerID < sorted[j].CustomerID
+		}
+		return sorted[i].OrderID < sorted[j].OrderID
+	})
+	return sorted
+}
+
+// assertOrders fails the test if got and want differ.
+func assertOrders(t *testing.T, got, want []Order) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got orders %+v, want %+v", got, want)
+	}
+}
+
+// assertCode fails the test if err does not carry the given gRPC code.
+func assertCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if spanner.ErrCode(err) != code {
+		t.Fatalf("got error %v, want code %s", err, code)
+	}
+}
+
+// skipQuotingUnsupported skips the test if err is spannertest failing to parse
+// a quoted identifier right after "(". NewUnquotingSpannertestClient runs
+// these cases; the emulator and Cloud Spanner parse the statements.
+func skipQuotingUnsupported(t *testing.T, err error) {
+	t.Helper()
+	if spanner.ErrCode(err) == codes.InvalidArgument && strings.Contains(err.Error(), "expected identifer") {
+		t.Skipf("backend cannot parse a quoted identifier after \"(\": %v", err)
+	}
+}
+
+func testFindByID(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+	seed(t, repo, orders...)
+
+	got, found, err := repo.FindByID(ctx, OrderKey{CustomerID: "alice", OrderID: 2}, nil)
+	if err != nil || !found {
+		t.Fatalf("FindByID existing = (%v, %v), want found", found, err)
+	}
+	assertOrders(t, []Order{got}, []Order{orders[1]})
+
+	_, found, err = repo.FindByID(ctx, OrderKey{CustomerID: "alice", OrderID: 99}, nil)
+	if err != nil || found {
+		t.Fatalf("FindByID missing = (%v, %v), want not found and no error", found, err)
+	}
+}
+
+func testExists(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+	seed(t, repo, sampleOrders()...)
+
+	if ok, err := repo.Exists(ctx, OrderKey{CustomerID: "bob", OrderID: 1}); err != nil || !ok {
+		t.Fatalf("Exists existing = (%v, %v), want true", ok, err)
+	}
+	if ok, err := repo.Exists(ctx, OrderKey{CustomerID: "bob", OrderID: 9}); err != nil || ok {
+		t.Fatalf("Exists missing = (%v, %v), want false", ok, err)
+	}
+}
+
+func testSaveUpserts(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+	order := Order{CustomerID: "carol", OrderID: 1, Status: "NEW", Amount: 10}
+	seed(t, repo, order)
+
+	order.Status = "PAID"
+	seed(t, repo, order)
+
+	got, _, err := repo.FindByID(ctx, orderKey(order), nil)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	assertOrders(t, []Order{got}, []Order{order})
+}
+
+func testUpdate(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+	order := sampleOrders()[0]
+	seed(t, repo, order)
+
+	order.Amount = 999
+	if err := repo.Update(ctx, order); err != nil {
+		t.Fatalf("Update existing: %v", err)
+	}
+	got, _, err := repo.FindByID(ctx, orderKey(order), nil)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	assertOrders(t, []Order{got}, []Order{order})
+
+	missing := Order{CustomerID: "nobody", OrderID: 1, Status: "NEW", Amount: 1}
+	assertCode(t, repo.Update(ctx, missing), codes.NotFound)
+}
+
+func testDelete(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+	seed(t, repo, orders...)
+
+	if err := repo.Delete(ctx, orderKey(orders[0])); err != nil {
+		t.Fatalf("Delete existing: %v", err)
+	}
+	if ok, _ := repo.Exists(ctx, orderKey(orders[0])); ok {
+		t.Fatal("deleted order still exists")
+	}
+	if err := repo.Delete(ctx, OrderKey{CustomerID: "nobody", OrderID: 1}); err != nil {
+		t.Fatalf("Delete missing: %v, want no error", err)
+	}
+}
+
+func testFindAll(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+
+	got, err := repo.FindAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("FindAll empty: %v", err)
+	}
+	assertOrders(t, got, nil)
+
+	orders := sampleOrders()
+	seed(t, repo, orders...)
+	got, err = repo.FindAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	assertOrders(t, sortOrders(got), orders)
+}
+
+func testFindByIDs(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+	seed(t, repo, orders...)
+
+	got, err := repo.FindByIDs(ctx, []OrderKey{
+		orderKey(orders[3]),
+		{CustomerID: "nobody", OrderID: 1},
+		orderKey(orders[0]),
+	}, nil)
+	if err != nil {
+		t.Fatalf("FindByIDs: %v", err)
+	}
+	assertOrders(t, sortOrders(got), []Order{orders[0], orders[3]})
+
+	got, err = repo.FindByIDs(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("FindByIDs without keys: %v", err)
+	}
+	assertOrders(t, got, nil)
+}
+
+func testFindPage(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	ctx := context.Background()
+
+	page, token, err := repo.FindPage(ctx, 2, nil, nil)
+	if err != nil || len(page) != 0 || token != nil {
+		t.Fatalf("FindPage on empty table = (%v, %v, %v), want no rows and nil token", page, token, err)
+	}
+
+	orders := sampleOrders()
+	seed(t, repo, orders...)
+
+	var all []Order
+	token = nil
+	for pages := 0; ; pages++ {
+		if pages > len(orders) {
+			t.Fatal("FindPage did not terminate")
+		}
+		page, next, err := repo.FindPage(ctx, 2, token, nil)
+		skipQuotingUnsupported(t, err)
+		if err != nil {
+			t.Fatalf("FindPage(token=%v): %v", token, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		if len(page) > 2 {
+			t.Fatalf("FindPage returned %d rows, want at most 2", len(page))
+		}
+		all = append(all, page...)
+		token = next
+	}
+	assertOrders(t, all, orders)
+
+	page, _, err = repo.FindPage(ctx, len(orders)+10, nil, nil)
+	if err != nil {
+		t.Fatalf("FindPage with large page size: %v", err)
+	}
+	assertOrders(t, page, orders)
+}
+
+func testFindPageInvalidToken(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	if _, _, err := repo.FindPage(context.Background(), 2, "alice", nil); err == nil {
+		t.Fatal("FindPage accepted a single value token for a composite key")
+	}
+}
+
+func testFindPageInvalidSize(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	seed(t, repo, sampleOrders()...)
+	for _, size := range []int{0, -1} {
+		want := fmt.Sprintf("page size must be positive, got %d", size)
+		if page, _, err := repo.FindPage(context.Background(), size, nil, nil); err == nil || err.Error() != want {
+			t.Fatalf("FindPage(pageSize=%d) = (%v, %v), want error %q", size, page, err, want)
+		}
+	}
+}
+
+func testTransactionCommit(t *testing.T, repo repokit.Repository[Order, OrderKey], tm repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+	seed(t, repo, orders[0])
+
+	updated := orders[0]
+	updated.Status = "SHIPPED"
+	err := tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := repo.SaveTx(tx, orders[1]); err != nil {
+			return err
+		}
+		if err := repo.SaveTx(tx, orders[2]); err != nil {
+			return err
+		}
+		if err := repo.UpdateTx(tx, updated); err != nil {
+			return err
+		}
+		return repo.DeleteTx(tx, orderKey(orders[2]))
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+
+	got, err := repo.FindAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	assertOrders(t, sortOrders(got), []Order{updated, orders[1]})
+}
+
+func testTransactionRollback(t *testing.T, repo repokit.Repository[Order, OrderKey], tm repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+	seed(t, repo, orders[0])
+
+	errRollback := errors.New("rollback")
+	err := tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := repo.SaveTx(tx, orders[1]); err != nil {
+			return err
+		}
+		if err := repo.DeleteTx(tx, orderKey(orders[0])); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("RunInTransaction = %v, want %v", err, errRollback)
+	}
+
+	got, err := repo.FindAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	assertOrders(t, got, []Order{orders[0]})
+}
+
+func testTransactionFailedUpdate(t *testing.T, repo repokit.Repository[Order, OrderKey], tm repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+
+	err := tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		if err := repo.UpdateTx(tx, orders[1]); err != nil {
+			return err
+		}
+		return repo.SaveTx(tx, orders[0])
+	})
+	assertCode(t, err, codes.NotFound)
+
+	got, err := repo.FindAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	assertOrders(t, got, nil)
+}
+
+func testTransactionRetryOnAborted(t *testing.T, repo repokit.Repository[Order, OrderKey], tm repokit.TransactionManager) {
+	ctx := context.Background()
+	orders := sampleOrders()
+
+	attempts := 0
+	err := tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		attempts++
+		if err := repo.SaveTx(tx, orders[attempts-1]); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return status.Error(codes.Aborted, "injected abort")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("callback ran %d times, want 2", attempts)
+	}
+
+	got, err := repo.FindAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	assertOrders(t, got, []Order{orders[1]})
+}
+
+// foreignTransaction is a Transaction implementation unknown to repokit.
+type foreignTransaction struct{}
+
+func (foreignTransaction) Context() context.Context { return context.Background() }
+
+func testForeignTransaction(t *testing.T, repo repokit.Repository[Order, OrderKey], _ repokit.TransactionManager) {
+	order := sampleOrders()[0]
+	if err := repo.SaveTx(foreignTransaction{}, order); err == nil {
+		t.Fatal("SaveTx accepted a foreign transaction")
+	}
+	if err := repo.UpdateTx(foreignTransaction{}, order); err == nil {
+		t.Fatal("UpdateTx accepted a foreign transaction")
+	}
+	if err := repo.DeleteTx(foreignTransaction{}, orderKey(order)); err == nil {
+		t.Fatal("DeleteTx accepted a foreign transaction")
+	}
+}
+
+func testCanceledContext(t *testing.T, repo repokit.Repository[Order, OrderKey], tm repokit.TransactionManager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := repo.Save(ctx, sampleOrders()[0]); err == nil {
+		t.Fatal("Save succeeded with a canceled context")
+	}
+	if _, _, err := repo.FindByID(ctx, OrderKey{CustomerID: "alice", OrderID: 1}, nil); err == nil {
+		t.Fatal("FindByID succeeded with a canceled context")
+	}
+	called := false
+	err := tm.RunInTransaction(ctx, func(tx repokit.Transaction) error {
+		called = true
+		return nil
+	})
+	if err == nil && called {
+		t.Fatal("RunInTransaction committed with a canceled context")
+	}
+}